@@ -96,14 +96,13 @@ func (l *Logger) Trace(ctx context.Context, begin time.Time, fc func() (string,
 }
 
 func TraceId(ctx context.Context) string {
-	if traceId, ok := ctx.Value(traceIdKey{}).(string); ok {
+	if traceId := logi.TraceId(ctx); traceId != "" {
 		return traceId
 	}
 	return "unknown"
 }
 
-type traceIdKey struct{}
-
+// SetTranceId 与log.WithTrace共用trace上下文,日志及sql日志中的trace id一致
 func SetTranceId(ctx context.Context, traceId string) context.Context {
-	return context.WithValue(ctx, traceIdKey{}, traceId)
+	return logi.WithTrace(ctx, traceId, "")
 }
@@ -6,10 +6,30 @@
 
 package redis
 
-import "github.com/google/uuid"
+import (
+	"github.com/google/uuid"
+	"time"
+)
 
 func LockCmd() (uuid.UUID, string) {
 	id := uuid.New()
 	cmd := "SETNX " + id.String() + " EXPIRE 100000"
 	return id, cmd
 }
+
+// LockArgs 获取分布式锁的命令参数,SET key id NX PX ttl,id用于释放锁时校验持有者
+func LockArgs(key string, ttl time.Duration) (uuid.UUID, []any) {
+	id := uuid.New()
+	return id, []any{CommandSET, key, id.String(), "NX", "PX", ttl.Milliseconds()}
+}
+
+// UnlockScript 释放锁,只有持有者才能释放,KEYS[1]为key,ARGV[1]为LockArgs返回的id
+const UnlockScript = `if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("DEL", KEYS[1]) else return 0 end`
+
+// SetIfGreaterScript 仅当新值大于当前值(或key不存在)时设置,KEYS[1]为key,ARGV[1]为数值,设置成功返回1
+const SetIfGreaterScript = `local v = redis.call("GET", KEYS[1])
+if v == false or tonumber(v) < tonumber(ARGV[1]) then
+	redis.call("SET", KEYS[1], ARGV[1])
+	return 1
+end
+return 0`
@@ -7,6 +7,7 @@
 package log
 
 import (
+	"github.com/hopeio/gox/log/output/rotate"
 	neti "github.com/hopeio/gox/net"
 	"github.com/hopeio/gox/slices"
 	"go.uber.org/zap"
@@ -103,11 +104,19 @@ type Config struct {
 	Sampling          *zap.SamplingConfig `json:"sampling" yaml:"sampling"`
 	OutputPaths       OutPutPaths         `json:"outputPaths"`
 	ErrorOutputPaths  []string
+	// 模块级别,key为Logger.Named的名称,如{"gorm":"warn","engine":"debug"}
+	ModuleLevels map[string]zapcore.Level `json:"moduleLevels,omitempty" yaml:"moduleLevels"`
 	// InitialFields is a collection of fields to add to the root logger.
 	InitialFields map[string]interface{} `json:"initialFields" yaml:"initialFields"`
 	zapcore.EncoderConfig
 	EncodeLevelType string `json:"encodeLevelType,omitempty" comment:"capital;capitalColor;color"`
 	TimeLayout      string
+	levels          *Levels
+}
+
+// Levels 获取NewLogger创建的logger的级别控制
+func (lc *Config) Levels() *Levels {
+	return lc.levels
 }
 
 func (lc *Config) Init() {
@@ -127,7 +136,7 @@ func (lc *Config) Init() {
 		}
 	}
 
-	if len(lc.OutputPaths.Console) == 0 && len(lc.OutputPaths.Json) == 0 {
+	if len(lc.OutputPaths.Console) == 0 && len(lc.OutputPaths.Json) == 0 && lc.OutputPaths.File == nil {
 		lc.OutputPaths.Console = []string{stdout}
 	}
 
@@ -203,6 +212,18 @@ func (lc *Config) Init() {
 type OutPutPaths struct {
 	Console []string `json:"console,omitempty"`
 	Json    []string `json:"json,omitempty"`
+	// 滚动文件输出,也可以在Console或Json中使用rotate:///path?maxSize=100的sink url
+	File *FileOutput `json:"file,omitempty"`
+}
+
+type FileOutput struct {
+	rotate.Config `yaml:",inline"`
+	// 以console格式输出,默认json
+	Console bool `json:"console,omitempty"`
+}
+
+func init() {
+	rotate.RegisterSink()
 }
 
 // 初始化日志对象
@@ -223,6 +244,10 @@ func (lc *Config) NewLogger(cores ...zapcore.Core) *Logger {
 // 构建日志对象基本信息
 func (lc *Config) initLogger(cores ...zapcore.Core) *zap.Logger {
 	lc.Init()
+	lc.levels = NewLevels(lc.Level, lc.ModuleLevels)
+	lc.levels.prefix = lc.Name
+	// 级别由levelCore统一过滤
+	level := zapcore.DebugLevel
 
 	var consoleEncoder, jsonEncoder zapcore.Encoder
 
@@ -241,8 +266,8 @@ func (lc *Config) initLogger(cores ...zapcore.Core) *zap.Logger {
 			}
 		})
 		if ustdout && ustderr {
-			cores = append(cores, zapcore.NewCore(consoleEncoder, zapcore.AddSync(os.Stdout), StdOutLevel(lc.Level)),
-				zapcore.NewCore(consoleEncoder, zapcore.AddSync(os.Stderr), StdErrLevel(lc.Level)))
+			cores = append(cores, zapcore.NewCore(consoleEncoder, zapcore.AddSync(os.Stdout), StdOutLevel(level)),
+				zapcore.NewCore(consoleEncoder, zapcore.AddSync(os.Stderr), StdErrLevel(level)))
 		} else {
 			if ustdout {
 				consolePaths = append(consolePaths, stdout)
@@ -255,7 +280,7 @@ func (lc *Config) initLogger(cores ...zapcore.Core) *zap.Logger {
 		if err != nil {
 			log.Fatal(err)
 		}
-		cores = append(cores, zapcore.NewCore(consoleEncoder, sink, lc.Level))
+		cores = append(cores, zapcore.NewCore(consoleEncoder, sink, level))
 	}
 
 	if len(lc.OutputPaths.Json) > 0 {
@@ -265,15 +290,33 @@ func (lc *Config) initLogger(cores ...zapcore.Core) *zap.Logger {
 		if err != nil {
 			log.Fatal(err)
 		}
-		cores = append(cores, zapcore.NewCore(jsonEncoder, sink, lc.Level))
+		cores = append(cores, zapcore.NewCore(jsonEncoder, sink, level))
+	}
+	if file := lc.OutputPaths.File; file != nil && file.Filename != "" {
+		sink, err := rotate.New(&file.Config)
+		if err != nil {
+			log.Fatal(err)
+		}
+		var encoder zapcore.Encoder
+		if file.Console {
+			encoderConfig := lc.EncoderConfig
+			// 文件中不需要颜色
+			encoderConfig.EncodeLevel = zapcore.CapitalLevelEncoder
+			encoder = zapcore.NewConsoleEncoder(encoderConfig)
+		} else {
+			encoderConfig := lc.EncoderConfig
+			encoderConfig.EncodeLevel = zapcore.LowercaseLevelEncoder
+			encoder = zapcore.NewJSONEncoder(encoderConfig)
+		}
+		cores = append(cores, zapcore.NewCore(encoder, sink, level))
 	}
 	//如果没有设置输出，默认控制台
 	if len(cores) == 0 {
 		consoleEncoder = zapcore.NewConsoleEncoder(lc.EncoderConfig)
-		cores = append(cores, zapcore.NewCore(consoleEncoder, zapcore.AddSync(os.Stdout), lc.Level))
+		cores = append(cores, zapcore.NewCore(consoleEncoder, zapcore.AddSync(os.Stdout), level))
 	}
 
-	core := zapcore.NewTee(cores...)
+	core := &levelCore{Core: zapcore.NewTee(cores...), levels: lc.levels}
 
 	logger := zap.New(core, lc.hook()...)
 	if lc.Name != "" {
@@ -0,0 +1,124 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package log
+
+import (
+	"context"
+	"github.com/hopeio/gox/datastructure/idgen/id"
+	"github.com/hopeio/gox/net/http/consts"
+	"go.uber.org/zap"
+	"strings"
+)
+
+type loggerKey struct{}
+
+type traceKey struct{}
+
+type trace struct {
+	traceId string
+	spanId  string
+}
+
+// WithContext 将追加了fields的logger放入ctx,ctx中没有logger时基于默认logger并带上ctx中的trace id
+func WithContext(ctx context.Context, fields ...zap.Field) context.Context {
+	return ContextWithLogger(ctx, FromContext(ctx).With(fields...))
+}
+
+// ContextWithLogger 将logger放入ctx
+func ContextWithLogger(ctx context.Context, logger *Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// FromContext 获取ctx中的logger,没有时返回带有ctx中trace id的默认logger
+func FromContext(ctx context.Context) *Logger {
+	if ctx == nil {
+		return defaultLogger
+	}
+	if logger, ok := ctx.Value(loggerKey{}).(*Logger); ok {
+		return logger
+	}
+	if t, ok := ctx.Value(traceKey{}).(*trace); ok {
+		return defaultLogger.With(t.fields()...)
+	}
+	return defaultLogger
+}
+
+func (t *trace) fields() []zap.Field {
+	fields := []zap.Field{zap.String(FieldTraceId, t.traceId)}
+	if t.spanId != "" {
+		fields = append(fields, zap.String(FieldSpanId, t.spanId))
+	}
+	return fields
+}
+
+// WithTrace 在ctx中记录trace id及span id,ctx中已有logger时同时为其追加字段
+func WithTrace(ctx context.Context, traceId, spanId string) context.Context {
+	t := &trace{traceId: traceId, spanId: spanId}
+	ctx = context.WithValue(ctx, traceKey{}, t)
+	if logger, ok := ctx.Value(loggerKey{}).(*Logger); ok {
+		ctx = ContextWithLogger(ctx, logger.With(t.fields()...))
+	}
+	return ctx
+}
+
+// TraceFromContext 获取ctx中的trace id及span id
+func TraceFromContext(ctx context.Context) (traceId, spanId string) {
+	if ctx == nil {
+		return "", ""
+	}
+	if t, ok := ctx.Value(traceKey{}).(*trace); ok {
+		return t.traceId, t.spanId
+	}
+	return "", ""
+}
+
+// TraceId 获取ctx中的trace id
+func TraceId(ctx context.Context) string {
+	traceId, _ := TraceFromContext(ctx)
+	return traceId
+}
+
+// NewTraceId 生成32位16进制的trace id,与W3C traceparent兼容
+func NewTraceId() string {
+	return id.NewRandomID().String()
+}
+
+// ParseTraceparent 解析W3C traceparent: version-traceId-parentId-flags
+func ParseTraceparent(traceparent string) (traceId, spanId string, ok bool) {
+	parts := strings.Split(strings.TrimSpace(traceparent), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return "", "", false
+	}
+	if !isHex(parts[1]) || !isHex(parts[2]) || parts[1] == strings.Repeat("0", 32) || parts[2] == strings.Repeat("0", 16) {
+		return "", "", false
+	}
+	return parts[1], parts[2], true
+}
+
+func isHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// TraceFromHeader 从traceparent或自定义trace id header中获取trace,都没有时生成新的trace id
+// get一般为http.Header.Get或grpc metadata的取值函数
+func TraceFromHeader(get func(key string) string, traceIdHeader string) (traceId, spanId string) {
+	if traceId, spanId, ok := ParseTraceparent(get(consts.HeaderTraceparent)); ok {
+		return traceId, spanId
+	}
+	if traceIdHeader != "" {
+		if traceId = get(traceIdHeader); traceId != "" {
+			return traceId, ""
+		}
+	}
+	return NewTraceId(), ""
+}
@@ -0,0 +1,217 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package log
+
+import (
+	"encoding/json"
+	"github.com/hopeio/gox/net/http/consts"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"maps"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Levels 运行时可修改的日志级别,支持按Logger.Named的模块单独设置
+// 模块名不包含Config.Name前缀,如gorm匹配app.gorm及app.gorm.xxx,最长匹配生效
+type Levels struct {
+	global zap.AtomicLevel
+	prefix string
+	mu     sync.Mutex
+	// 只读快照,修改时整体替换
+	modules atomic.Pointer[map[string]zapcore.Level]
+	// 全局及所有模块中的最低级别
+	min atomic.Int32
+}
+
+func NewLevels(level zapcore.Level, modules map[string]zapcore.Level) *Levels {
+	l := &Levels{global: zap.NewAtomicLevelAt(level)}
+	l.storeModules(maps.Clone(modules))
+	return l
+}
+
+func (l *Levels) storeModules(modules map[string]zapcore.Level) {
+	if modules == nil {
+		modules = make(map[string]zapcore.Level)
+	}
+	l.modules.Store(&modules)
+	min := l.global.Level()
+	for _, lvl := range modules {
+		if lvl < min {
+			min = lvl
+		}
+	}
+	l.min.Store(int32(min))
+}
+
+func (l *Levels) Level() zapcore.Level {
+	return l.global.Level()
+}
+
+func (l *Levels) SetLevel(level zapcore.Level) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.global.SetLevel(level)
+	l.storeModules(*l.modules.Load())
+}
+
+// SetModuleLevel 设置模块的级别
+func (l *Levels) SetModuleLevel(module string, level zapcore.Level) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	modules := maps.Clone(*l.modules.Load())
+	modules[module] = level
+	l.storeModules(modules)
+}
+
+// RemoveModuleLevel 模块恢复使用全局级别
+func (l *Levels) RemoveModuleLevel(module string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	modules := maps.Clone(*l.modules.Load())
+	delete(modules, module)
+	l.storeModules(modules)
+}
+
+func (l *Levels) ModuleLevels() map[string]zapcore.Level {
+	return maps.Clone(*l.modules.Load())
+}
+
+// LevelOf 获取logger名称对应的级别
+func (l *Levels) LevelOf(name string) zapcore.Level {
+	modules := *l.modules.Load()
+	if len(modules) == 0 || name == "" {
+		return l.global.Level()
+	}
+	if l.prefix != "" {
+		if name == l.prefix {
+			return l.global.Level()
+		}
+		name = strings.TrimPrefix(name, l.prefix+".")
+	}
+	for {
+		if lvl, ok := modules[name]; ok {
+			return lvl
+		}
+		i := strings.LastIndexByte(name, '.')
+		if i < 0 {
+			return l.global.Level()
+		}
+		name = name[:i]
+	}
+}
+
+// Enabled 是否有任一模块启用了该级别
+func (l *Levels) Enabled(lvl zapcore.Level) bool {
+	return lvl >= zapcore.Level(l.min.Load())
+}
+
+type levelsState struct {
+	Level   string            `json:"level,omitempty"`
+	Modules map[string]string `json:"modules,omitempty"`
+}
+
+// ServeHTTP GET获取当前级别,PUT修改级别
+// {"level":"info","modules":{"gorm":"warn","engine":"debug"}},PUT时模块级别为空表示删除
+func (l *Levels) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var req levelsState
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var level zapcore.Level
+		if req.Level != "" {
+			if err := level.UnmarshalText([]byte(req.Level)); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		modules := make(map[string]*zapcore.Level, len(req.Modules))
+		for module, text := range req.Modules {
+			if text == "" {
+				modules[module] = nil
+				continue
+			}
+			var lvl zapcore.Level
+			if err := lvl.UnmarshalText([]byte(text)); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			modules[module] = &lvl
+		}
+		if req.Level != "" {
+			l.SetLevel(level)
+		}
+		for module, lvl := range modules {
+			if lvl == nil {
+				l.RemoveModuleLevel(module)
+			} else {
+				l.SetModuleLevel(module, *lvl)
+			}
+		}
+	default:
+		w.Header().Set(consts.HeaderAllow, "GET, PUT")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	state := levelsState{Level: l.Level().String(), Modules: make(map[string]string)}
+	for module, lvl := range l.ModuleLevels() {
+		state.Modules[module] = lvl.String()
+	}
+	w.Header().Set(consts.HeaderContentType, consts.ContentTypeJsonUtf8)
+	json.NewEncoder(w).Encode(&state)
+}
+
+// levelCore 按logger名称过滤级别
+type levelCore struct {
+	zapcore.Core
+	levels *Levels
+}
+
+func (c *levelCore) Enabled(lvl zapcore.Level) bool {
+	return c.levels.Enabled(lvl)
+}
+
+func (c *levelCore) With(fields []zapcore.Field) zapcore.Core {
+	return &levelCore{Core: c.Core.With(fields), levels: c.levels}
+}
+
+func (c *levelCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if ent.Level < c.levels.LevelOf(ent.LoggerName) {
+		return ce
+	}
+	return c.Core.Check(ent, ce)
+}
+
+var defaultLevels atomic.Pointer[Levels]
+
+// DefaultLevels 默认logger的级别控制
+func DefaultLevels() *Levels {
+	return defaultLevels.Load()
+}
+
+// SetLevel 修改默认logger的全局级别
+func SetLevel(level zapcore.Level) {
+	DefaultLevels().SetLevel(level)
+}
+
+// SetModuleLevel 修改默认logger中某个模块的级别
+func SetModuleLevel(module string, level zapcore.Level) {
+	DefaultLevels().SetModuleLevel(module, level)
+}
+
+// LevelHandler 查看及修改默认logger级别的http handler
+func LevelHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		DefaultLevels().ServeHTTP(w, r)
+	})
+}
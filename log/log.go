@@ -39,6 +39,7 @@ func SetDefaultLogger(lf *Config, cores ...zapcore.Core) {
 	defer mu.Unlock()
 
 	defaultLogger = lf.NewLogger(cores...)
+	defaultLevels.Store(lf.levels)
 	stackLogger = defaultLogger.WithOptions(zap.WithCaller(true), zap.AddStacktrace(zapcore.ErrorLevel))
 	noCallerLogger = defaultLogger.WithOptions(zap.WithCaller(false))
 	clf := *lf
@@ -6,7 +6,16 @@
 
 package log
 
-import "testing"
+import (
+	"context"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
 
 func TestLog(t *testing.T) {
 	Info("test")
@@ -19,3 +28,53 @@ func TestLogStack(t *testing.T) {
 func TestLogNoCaller(t *testing.T) {
 	noCallerLogger.Debug("test")
 }
+
+func TestLevels(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	conf := &Config{Name: "app", Level: zapcore.InfoLevel, ModuleLevels: map[string]zapcore.Level{"gorm": zapcore.WarnLevel}}
+	logger := conf.NewLogger(core)
+	gorm := logger.Named("gorm").Named("sql")
+	engine := logger.Named("engine")
+	gorm.Info("gorm info")
+	engine.Debug("engine debug")
+	engine.Info("engine info")
+	if logs.Len() != 1 || logs.All()[0].Message != "engine info" {
+		t.Fatalf("unexpected logs: %v", logs.All())
+	}
+
+	recorder := httptest.NewRecorder()
+	conf.Levels().ServeHTTP(recorder, httptest.NewRequest(http.MethodPut, "/debug/log/level", strings.NewReader(`{"modules":{"engine":"debug","gorm":""}}`)))
+	if recorder.Code != http.StatusOK || !strings.Contains(recorder.Body.String(), `"engine":"debug"`) {
+		t.Fatalf("unexpected response: %d %s", recorder.Code, recorder.Body.String())
+	}
+	engine.Debug("engine debug")
+	gorm.Info("gorm info")
+	logger.Debug("app debug")
+	if logs.Len() != 3 {
+		t.Fatalf("unexpected logs: %v", logs.All())
+	}
+}
+
+func TestContext(t *testing.T) {
+	traceId, spanId, ok := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	if !ok || traceId != "4bf92f3577b34da6a3ce929d0e0e4736" || spanId != "00f067aa0ba902b7" {
+		t.Fatalf("unexpected traceparent: %s %s %v", traceId, spanId, ok)
+	}
+	if _, _, ok = ParseTraceparent("00-00000000000000000000000000000000-00f067aa0ba902b7-01"); ok {
+		t.Fatal("zero trace id should be invalid")
+	}
+
+	core, logs := observer.New(zapcore.DebugLevel)
+	logger := (&Config{Level: zapcore.DebugLevel}).NewLogger(core)
+	ctx := ContextWithLogger(context.Background(), logger)
+	ctx = WithTrace(ctx, traceId, spanId)
+	ctx = WithContext(ctx, zap.String("user", "jyb"))
+	FromContext(ctx).Info("hello")
+	if TraceId(ctx) != traceId || logs.Len() != 1 {
+		t.Fatalf("unexpected logs: %v", logs.All())
+	}
+	fields := logs.All()[0].ContextMap()
+	if fields[FieldTraceId] != traceId || fields[FieldSpanId] != spanId || fields["user"] != "jyb" {
+		t.Fatalf("unexpected fields: %v", fields)
+	}
+}
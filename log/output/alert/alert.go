@@ -0,0 +1,332 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package alert
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+	"golang.org/x/time/rate"
+)
+
+// Message 一个窗口内合并后的告警,级别、调用位置及消息相同的日志视为重复
+type Message struct {
+	Level   zapcore.Level
+	Content string
+	// 窗口内重复的次数
+	Count int
+	First time.Time
+	Last  time.Time
+}
+
+// Transport 告警的发送方式,content为合并后的整批告警
+type Transport interface {
+	Send(ctx context.Context, title, content string) error
+}
+
+type TransportFunc func(ctx context.Context, title, content string) error
+
+func (f TransportFunc) Send(ctx context.Context, title, content string) error {
+	return f(ctx, title, content)
+}
+
+type Config struct {
+	Level zapcore.Level
+	// 告警标题
+	Title string
+	// 缓冲的日志数,满时丢弃,默认1024
+	BufferSize int
+	// 合并窗口,窗口内的日志合并为一条告警发送,默认10s
+	Window time.Duration
+	// 一批最多包含的不同告警数,超过时丢弃,默认20
+	MaxBatch int
+	// 每秒发送次数及突发数,默认3s一次(钉钉限制每分钟20条),突发1
+	Rate  rate.Limit
+	Burst int
+	// 单次发送超时,默认10s
+	Timeout time.Duration
+	// 格式化一批告警,默认markdown
+	Format func(messages []*Message) string
+	// 发送失败时回调,默认输出到stderr
+	OnError func(err error)
+}
+
+func (c *Config) init() {
+	if c.Title == "" {
+		c.Title = "日志告警"
+	}
+	if c.BufferSize <= 0 {
+		c.BufferSize = 1024
+	}
+	if c.Window <= 0 {
+		c.Window = 10 * time.Second
+	}
+	if c.MaxBatch <= 0 {
+		c.MaxBatch = 20
+	}
+	if c.Rate <= 0 {
+		c.Rate = rate.Every(3 * time.Second)
+	}
+	if c.Burst <= 0 {
+		c.Burst = 1
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = 10 * time.Second
+	}
+	if c.Format == nil {
+		c.Format = Markdown
+	}
+	if c.OnError == nil {
+		c.OnError = func(err error) {
+			fmt.Fprintf(os.Stderr, "alert: %v\n", err)
+		}
+	}
+}
+
+// Stats 告警计数
+type Stats struct {
+	// 写入的日志数
+	Received uint64
+	// 合并到已有告警的日志数
+	Merged uint64
+	// 缓冲已满或批次已满丢弃的日志数
+	Dropped uint64
+	// 发送成功及失败的批次数
+	Sent   uint64
+	Failed uint64
+}
+
+type stats struct {
+	received, merged, dropped, sent, failed atomic.Uint64
+}
+
+type entry struct {
+	key     string
+	level   zapcore.Level
+	content string
+	time    time.Time
+}
+
+type shared struct {
+	conf      Config
+	transport Transport
+	limiter   *rate.Limiter
+	ch        chan *entry
+	flushCh   chan chan struct{}
+	closeCh   chan struct{}
+	closeOnce sync.Once
+	done      chan struct{}
+	stats     stats
+}
+
+// Core 异步告警的zapcore.Core,Write只写入缓冲不会阻塞
+// 后台按窗口合并发送,被限流时继续合并直到可发送
+type Core struct {
+	*shared
+	encoder zapcore.Encoder
+}
+
+func NewCore(transport Transport, encoder zapcore.Encoder, conf *Config) *Core {
+	c := &shared{transport: transport, flushCh: make(chan chan struct{}), closeCh: make(chan struct{}), done: make(chan struct{})}
+	if conf != nil {
+		c.conf = *conf
+	}
+	c.conf.init()
+	c.limiter = rate.NewLimiter(c.conf.Rate, c.conf.Burst)
+	c.ch = make(chan *entry, c.conf.BufferSize)
+	go c.run()
+	return &Core{shared: c, encoder: encoder}
+}
+
+func (c *Core) Enabled(lvl zapcore.Level) bool {
+	return lvl >= c.conf.Level
+}
+
+func (c *Core) With(fields []zapcore.Field) zapcore.Core {
+	encoder := c.encoder.Clone()
+	for i := range fields {
+		fields[i].AddTo(encoder)
+	}
+	return &Core{shared: c.shared, encoder: encoder}
+}
+
+func (c *Core) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *Core) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	c.stats.received.Add(1)
+	buf, err := c.encoder.EncodeEntry(ent, fields)
+	if err != nil {
+		return err
+	}
+	e := &entry{
+		key:     ent.Level.String() + "|" + ent.Caller.TrimmedPath() + "|" + ent.Message,
+		level:   ent.Level,
+		content: strings.TrimRight(buf.String(), "\n"),
+		time:    ent.Time,
+	}
+	buf.Free()
+	select {
+	case <-c.closeCh:
+		c.stats.dropped.Add(1)
+		return nil
+	default:
+	}
+	select {
+	case c.ch <- e:
+	default:
+		c.stats.dropped.Add(1)
+	}
+	return nil
+}
+
+// Sync 立即发送已缓冲的告警(不受限流),等待发送完成
+func (c *Core) Sync() error {
+	done := make(chan struct{})
+	select {
+	case c.flushCh <- done:
+		<-done
+	case <-c.done:
+	}
+	return nil
+}
+
+// Close 发送剩余的告警并停止后台goroutine
+func (c *Core) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.closeCh)
+	})
+	<-c.done
+	return nil
+}
+
+func (c *Core) Stats() Stats {
+	return Stats{
+		Received: c.stats.received.Load(),
+		Merged:   c.stats.merged.Load(),
+		Dropped:  c.stats.dropped.Load(),
+		Sent:     c.stats.sent.Load(),
+		Failed:   c.stats.failed.Load(),
+	}
+}
+
+func (c *shared) run() {
+	defer close(c.done)
+	var pending []*Message
+	index := make(map[string]*Message)
+	timer := time.NewTimer(c.conf.Window)
+	timer.Stop()
+	var timing bool
+
+	add := func(e *entry) {
+		if m, ok := index[e.key]; ok {
+			m.Count++
+			m.Last = e.time
+			c.stats.merged.Add(1)
+			return
+		}
+		if len(pending) >= c.conf.MaxBatch {
+			c.stats.dropped.Add(1)
+			return
+		}
+		m := &Message{Level: e.level, Content: e.content, Count: 1, First: e.time, Last: e.time}
+		pending = append(pending, m)
+		index[e.key] = m
+		if !timing {
+			timer.Reset(c.conf.Window)
+			timing = true
+		}
+	}
+	send := func() {
+		if len(pending) == 0 {
+			return
+		}
+		c.send(pending)
+		pending = nil
+		clear(index)
+	}
+	drain := func() {
+		for {
+			select {
+			case e := <-c.ch:
+				add(e)
+			default:
+				return
+			}
+		}
+	}
+
+	for {
+		select {
+		case e := <-c.ch:
+			add(e)
+		case <-timer.C:
+			timing = false
+			if len(pending) == 0 {
+				continue
+			}
+			r := c.limiter.Reserve()
+			if delay := r.Delay(); delay > 0 {
+				// 被限流,继续合并,到可发送时再发
+				r.Cancel()
+				timer.Reset(delay)
+				timing = true
+				continue
+			}
+			send()
+		case done := <-c.flushCh:
+			drain()
+			send()
+			close(done)
+		case <-c.closeCh:
+			timer.Stop()
+			drain()
+			send()
+			return
+		}
+	}
+}
+
+func (c *shared) send(messages []*Message) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.conf.Timeout)
+	defer cancel()
+	if err := c.transport.Send(ctx, c.conf.Title, c.conf.Format(messages)); err != nil {
+		c.stats.failed.Add(1)
+		c.conf.OnError(err)
+		return
+	}
+	c.stats.sent.Add(1)
+}
+
+// Markdown 默认的格式化,重复的告警标注次数及时间范围
+func Markdown(messages []*Message) string {
+	var b strings.Builder
+	for i, m := range messages {
+		if i > 0 {
+			b.WriteString("\n\n---\n\n")
+		}
+		b.WriteString("**")
+		b.WriteString(m.Level.CapitalString())
+		b.WriteString("**")
+		if m.Count > 1 {
+			fmt.Fprintf(&b, " ×%d (%s ~ %s)", m.Count, m.First.Format(time.DateTime), m.Last.Format(time.DateTime))
+		}
+		b.WriteString("\n\n")
+		b.WriteString(m.Content)
+	}
+	return b.String()
+}
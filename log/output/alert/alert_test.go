@@ -0,0 +1,113 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package alert
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hopeio/gox/sdk/dingtalk"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"golang.org/x/time/rate"
+)
+
+type webhook struct {
+	*httptest.Server
+	mu       sync.Mutex
+	messages []string
+	times    []time.Time
+}
+
+func newWebhook() *webhook {
+	w := &webhook{}
+	w.Server = httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Markdown dingtalk.Markdown `json:"markdown"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		w.mu.Lock()
+		w.messages = append(w.messages, body.Markdown.Text)
+		w.times = append(w.times, time.Now())
+		w.mu.Unlock()
+		rw.Write([]byte(`{"errcode":0,"errmsg":"ok"}`))
+	}))
+	return w
+}
+
+func (w *webhook) received() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]string(nil), w.messages...)
+}
+
+func newTestCore(w *webhook, conf *Config) *Core {
+	transport := &DingTalk{Endpoint: w.URL + "/", RobotConfig: dingtalk.RobotConfig{Token: "token", Secret: "secret"}}
+	return NewCore(transport, zapcore.NewConsoleEncoder(zap.NewProductionEncoderConfig()), conf)
+}
+
+func TestCoreMerge(t *testing.T) {
+	w := newWebhook()
+	defer w.Close()
+	core := newTestCore(w, &Config{Level: zapcore.WarnLevel, Window: 50 * time.Millisecond, MaxBatch: 2})
+	logger := zap.New(core)
+	for range 5 {
+		logger.Error("db down")
+	}
+	logger.Warn("slow")
+	logger.Warn("dropped")
+	logger.Info("ignored")
+	time.Sleep(200 * time.Millisecond)
+
+	messages := w.received()
+	if len(messages) != 1 || !strings.Contains(messages[0], "×5") || !strings.Contains(messages[0], "slow") || strings.Contains(messages[0], "dropped") {
+		t.Fatalf("unexpected messages: %q", messages)
+	}
+	stats := core.Stats()
+	if stats != (Stats{Received: 7, Merged: 4, Dropped: 1, Sent: 1}) {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+	core.Close()
+}
+
+func TestCoreRateLimit(t *testing.T) {
+	w := newWebhook()
+	defer w.Close()
+	core := newTestCore(w, &Config{Window: 10 * time.Millisecond, Rate: rate.Every(300 * time.Millisecond)})
+	logger := zap.New(core)
+	logger.Error("first")
+	time.Sleep(50 * time.Millisecond)
+	// 限流期间的日志合并为一条
+	for range 3 {
+		logger.Error("second")
+		time.Sleep(20 * time.Millisecond)
+	}
+	time.Sleep(400 * time.Millisecond)
+	messages := w.received()
+	if len(messages) != 2 || !strings.Contains(messages[1], "×3") {
+		t.Fatalf("unexpected messages: %q", messages)
+	}
+	if d := w.times[1].Sub(w.times[0]); d < 250*time.Millisecond {
+		t.Fatalf("rate limit not applied: %v", d)
+	}
+
+	// Close时不受限流发送剩余告警
+	logger.Error("last")
+	core.Close()
+	if messages = w.received(); len(messages) != 3 {
+		t.Fatalf("unexpected messages after close: %q", messages)
+	}
+	logger.Error("after close")
+	if stats := core.Stats(); stats.Dropped != 1 || stats.Sent != 3 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+}
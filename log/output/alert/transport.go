@@ -0,0 +1,111 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package alert
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hopeio/gox/net/http/client"
+	"github.com/hopeio/gox/net/mail"
+	"github.com/hopeio/gox/sdk/dingtalk"
+	"github.com/hopeio/gox/sdk/qyweixin"
+)
+
+// 告警发送不记录访问日志,避免失败日志再次触发告警
+var defaultClient = client.New().LogLevel(client.LogLevelSilent)
+
+// webhookResponse 钉钉及企业微信机器人的响应
+type webhookResponse struct {
+	ErrCode int    `json:"errcode"`
+	ErrMsg  string `json:"errmsg"`
+}
+
+func (r *webhookResponse) CheckError() error {
+	if r.ErrCode != 0 {
+		return fmt.Errorf("webhook error: %d %s", r.ErrCode, r.ErrMsg)
+	}
+	return nil
+}
+
+func post(ctx context.Context, c *client.Client, url, body string) error {
+	if c == nil {
+		c = defaultClient
+	}
+	return c.PostRequest(url).Context(ctx).Do(body, &webhookResponse{})
+}
+
+// DingTalk 钉钉机器人
+type DingTalk struct {
+	dingtalk.RobotConfig
+	// 默认dingtalk.ROOT,测试时可替换
+	Endpoint string
+	At       *dingtalk.At
+	Client   *client.Client
+}
+
+func (d *DingTalk) Send(ctx context.Context, title, content string) error {
+	signUrl, err := dingtalk.RobotUrl(d.Token, d.Secret)
+	if err != nil {
+		return err
+	}
+	endpoint := d.Endpoint
+	if endpoint == "" {
+		endpoint = dingtalk.ROOT
+	}
+	body := dingtalk.Format(&dingtalk.Markdown{Title: title, Text: "### " + title + "\n\n" + content, At: d.At})
+	return post(ctx, d.Client, endpoint+signUrl, body)
+}
+
+// QyWeixin 企业微信机器人
+type QyWeixin struct {
+	Key string
+	// 默认qyweixin.ROOT,测试时可替换
+	Endpoint string
+	Client   *client.Client
+}
+
+func (q *QyWeixin) Send(ctx context.Context, title, content string) error {
+	signUrl, err := qyweixin.RobotUrl(q.Key)
+	if err != nil {
+		return err
+	}
+	endpoint := q.Endpoint
+	if endpoint == "" {
+		endpoint = qyweixin.ROOT
+	}
+	return post(ctx, q.Client, endpoint+signUrl, qyweixin.MarkdownMessage("### "+title+"\n\n"+content))
+}
+
+// Mail 邮件告警,Subject及Content由告警填充
+type Mail struct {
+	mail.Mail
+	TLS bool
+}
+
+func (m *Mail) Send(ctx context.Context, title, content string) error {
+	msg := m.Mail
+	msg.Subject = title
+	msg.Content = content
+	if msg.ContentType == "" {
+		msg.ContentType = "text/plain; charset=UTF-8"
+	}
+	errCh := make(chan error, 1)
+	go func() {
+		if m.TLS {
+			errCh <- msg.SendMailTLS()
+		} else {
+			errCh <- msg.SendMail()
+		}
+	}()
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
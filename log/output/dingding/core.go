@@ -7,44 +7,17 @@
 package dingding
 
 import (
+	"github.com/hopeio/gox/log/output/alert"
 	"github.com/hopeio/gox/sdk/dingtalk"
 	"go.uber.org/zap/zapcore"
 )
 
+// NewCore 异步发送的钉钉告警core,按窗口合并重复日志并限流,需要更多配置时使用NewCoreWithConfig
 func NewCore(token, secret string, level zapcore.Level, encoderConfig *zapcore.EncoderConfig) zapcore.Core {
-	return &core{
-		RobotConfig: dingtalk.RobotConfig{
-			Token:  token,
-			Secret: secret,
-		},
-		encoder: newDingEncoder(encoderConfig),
-		Level:   level,
-	}
+	return NewCoreWithConfig(token, secret, encoderConfig, &alert.Config{Level: level})
 }
 
-type core struct {
-	dingtalk.RobotConfig
-	zapcore.Level
-	encoder zapcore.Encoder
+func NewCoreWithConfig(token, secret string, encoderConfig *zapcore.EncoderConfig, conf *alert.Config) *alert.Core {
+	transport := &alert.DingTalk{RobotConfig: dingtalk.RobotConfig{Token: token, Secret: secret}}
+	return alert.NewCore(transport, NewDingEncoder(encoderConfig), conf)
 }
-
-func (c *core) With(fields []zapcore.Field) zapcore.Core {
-	for i := range fields {
-		fields[i].AddTo(c.encoder)
-	}
-	return c
-}
-func (c *core) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
-	if c.Enabled(ent.Level) {
-		return ce.AddCore(ent, c)
-	}
-	return ce
-}
-func (c *core) Write(e zapcore.Entry, fields []zapcore.Field) error {
-	buf, err := c.encoder.EncodeEntry(e, fields)
-	if err != nil {
-		return err
-	}
-	return dingtalk.RobotSendMarkDownMessageWithSecret(c.Token, c.Secret, "日志", buf.String(), nil)
-}
-func (*core) Sync() error { return nil }
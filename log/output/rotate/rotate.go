@@ -0,0 +1,371 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package rotate
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	backupTimeFormat = "2006-01-02T15-04-05.000"
+	compressSuffix   = ".gz"
+	megabyte         = 1024 * 1024
+)
+
+// Interval 按时间滚动的周期
+type Interval string
+
+const (
+	IntervalNone   Interval = ""
+	IntervalHourly Interval = "hourly"
+	IntervalDaily  Interval = "daily"
+)
+
+// 测试时替换
+var now = time.Now
+
+type Config struct {
+	// 日志文件路径,滚动后的文件与其在同一目录,名称为name-时间.ext
+	Filename string `json:"filename"`
+	// 单个文件最大大小,单位MB,为0不按大小滚动
+	MaxSize int64 `json:"maxSize,omitempty"`
+	// 按时间滚动,hourly或daily
+	Interval Interval `json:"interval,omitempty"`
+	// 保留的滚动文件数,为0不限制
+	MaxBackups int `json:"maxBackups,omitempty"`
+	// 滚动文件保留的天数,为0不限制
+	MaxAge int `json:"maxAge,omitempty"`
+	// 是否gzip压缩滚动后的文件
+	Compress bool `json:"compress,omitempty"`
+	// 滚动文件名使用本地时间,默认UTC
+	LocalTime bool `json:"localTime,omitempty"`
+}
+
+// Writer 滚动写入文件,并发安全,实现了zap.Sink
+type Writer struct {
+	conf     Config
+	mu       sync.Mutex
+	file     *os.File
+	size     int64
+	rotateAt time.Time
+	millCh   chan struct{}
+	millOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+// New 创建Writer并打开文件,收到SIGHUP时会重新打开文件
+func New(conf *Config) (*Writer, error) {
+	if conf.Filename == "" {
+		return nil, errors.New("rotate: filename is empty")
+	}
+	switch conf.Interval {
+	case IntervalNone, IntervalHourly, IntervalDaily:
+	default:
+		return nil, fmt.Errorf("rotate: unknown interval %q", conf.Interval)
+	}
+	w := &Writer{conf: *conf}
+	w.mu.Lock()
+	err := w.openExistingOrNew()
+	w.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	watchReopen(w)
+	return w, nil
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		if err := w.openExistingOrNew(); err != nil {
+			return 0, err
+		}
+	}
+	if w.shouldRotate(int64(len(p))) {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+func (w *Writer) shouldRotate(size int64) bool {
+	if w.conf.MaxSize > 0 && w.size > 0 && w.size+size > w.conf.MaxSize*megabyte {
+		return true
+	}
+	return !w.rotateAt.IsZero() && !now().Before(w.rotateAt)
+}
+
+func (w *Writer) Sync() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return nil
+	}
+	return w.file.Sync()
+}
+
+// Close 关闭文件并等待压缩及清理完成
+func (w *Writer) Close() error {
+	unwatchReopen(w)
+	w.mu.Lock()
+	err := w.close()
+	if w.millCh != nil {
+		close(w.millCh)
+		w.millCh = nil
+	}
+	w.mu.Unlock()
+	w.wg.Wait()
+	return err
+}
+
+func (w *Writer) close() error {
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
+
+// Reopen 重新打开文件,用于文件被外部工具(如logrotate)移动后
+func (w *Writer) Reopen() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.close(); err != nil {
+		return err
+	}
+	return w.openExistingOrNew()
+}
+
+// Rotate 立即滚动
+func (w *Writer) Rotate() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.rotate()
+}
+
+func (w *Writer) openExistingOrNew() error {
+	if err := os.MkdirAll(filepath.Dir(w.conf.Filename), 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(w.conf.Filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	w.file = file
+	w.size = info.Size()
+	// 已存在的文件从修改时间开始计算周期
+	w.rotateAt = w.nextRotateAt(info.ModTime())
+	return nil
+}
+
+func (w *Writer) nextRotateAt(t time.Time) time.Time {
+	switch w.conf.Interval {
+	case IntervalHourly:
+		return t.Truncate(time.Hour).Add(time.Hour)
+	case IntervalDaily:
+		t = t.Local()
+		return time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+	}
+	return time.Time{}
+}
+
+func (w *Writer) rotate() error {
+	if err := w.close(); err != nil {
+		return err
+	}
+	if _, err := os.Stat(w.conf.Filename); err == nil {
+		if err = os.Rename(w.conf.Filename, w.backupName(now())); err != nil {
+			return err
+		}
+	}
+	file, err := os.OpenFile(w.conf.Filename, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	w.file = file
+	w.size = 0
+	w.rotateAt = w.nextRotateAt(now())
+	w.mill()
+	return nil
+}
+
+func (w *Writer) prefixAndExt() (string, string) {
+	name := filepath.Base(w.conf.Filename)
+	ext := filepath.Ext(name)
+	return name[:len(name)-len(ext)] + "-", ext
+}
+
+func (w *Writer) backupName(t time.Time) string {
+	if !w.conf.LocalTime {
+		t = t.UTC()
+	}
+	prefix, ext := w.prefixAndExt()
+	return filepath.Join(filepath.Dir(w.conf.Filename), prefix+t.Format(backupTimeFormat)+ext)
+}
+
+// mill 异步压缩及清理滚动文件,调用方需持有w.mu
+func (w *Writer) mill() {
+	if w.conf.MaxBackups == 0 && w.conf.MaxAge == 0 && !w.conf.Compress {
+		return
+	}
+	w.millOnce.Do(func() {
+		w.millCh = make(chan struct{}, 1)
+		w.wg.Add(1)
+		go func(ch chan struct{}) {
+			defer w.wg.Done()
+			for range ch {
+				if err := w.millRun(); err != nil {
+					fmt.Fprintf(os.Stderr, "rotate: %v\n", err)
+				}
+			}
+		}(w.millCh)
+	})
+	if w.millCh == nil {
+		return
+	}
+	select {
+	case w.millCh <- struct{}{}:
+	default:
+	}
+}
+
+type backup struct {
+	path string
+	t    time.Time
+}
+
+// Backups 按时间从新到旧返回所有滚动文件
+func (w *Writer) Backups() ([]string, error) {
+	backups, err := w.backups()
+	if err != nil {
+		return nil, err
+	}
+	paths := make([]string, len(backups))
+	for i, b := range backups {
+		paths[i] = b.path
+	}
+	return paths, nil
+}
+
+func (w *Writer) backups() ([]backup, error) {
+	dir := filepath.Dir(w.conf.Filename)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	prefix, ext := w.prefixAndExt()
+	var backups []backup
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		name := strings.TrimSuffix(entry.Name(), compressSuffix)
+		if !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ext) {
+			continue
+		}
+		layout := name[len(prefix) : len(name)-len(ext)]
+		loc := time.UTC
+		if w.conf.LocalTime {
+			loc = time.Local
+		}
+		t, err := time.ParseInLocation(backupTimeFormat, layout, loc)
+		if err != nil {
+			continue
+		}
+		backups = append(backups, backup{path: filepath.Join(dir, entry.Name()), t: t})
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].t.After(backups[j].t)
+	})
+	return backups, nil
+}
+
+func (w *Writer) millRun() error {
+	backups, err := w.backups()
+	if err != nil {
+		return err
+	}
+	var remove []backup
+	if w.conf.MaxBackups > 0 && len(backups) > w.conf.MaxBackups {
+		remove = append(remove, backups[w.conf.MaxBackups:]...)
+		backups = backups[:w.conf.MaxBackups]
+	}
+	if w.conf.MaxAge > 0 {
+		cutoff := now().Add(-time.Duration(w.conf.MaxAge) * 24 * time.Hour)
+		kept := backups[:0]
+		for _, b := range backups {
+			if b.t.Before(cutoff) {
+				remove = append(remove, b)
+			} else {
+				kept = append(kept, b)
+			}
+		}
+		backups = kept
+	}
+	var errs []error
+	for _, b := range remove {
+		if err = os.Remove(b.path); err != nil && !os.IsNotExist(err) {
+			errs = append(errs, err)
+		}
+	}
+	if w.conf.Compress {
+		for _, b := range backups {
+			if strings.HasSuffix(b.path, compressSuffix) {
+				continue
+			}
+			if err = compressFile(b.path); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func compressFile(src string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	dst := src + compressSuffix
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(out)
+	if _, err = io.Copy(gz, in); err == nil {
+		err = gz.Close()
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(dst)
+		return err
+	}
+	in.Close()
+	return os.Remove(src)
+}
@@ -0,0 +1,109 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package rotate
+
+import (
+	"bytes"
+	"go.uber.org/zap"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriterSize(t *testing.T) {
+	dir := t.TempDir()
+	current := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	now = func() time.Time {
+		current = current.Add(time.Second)
+		return current
+	}
+	defer func() { now = time.Now }()
+
+	w, err := New(&Config{Filename: filepath.Join(dir, "app.log"), MaxSize: 1, MaxBackups: 2, Compress: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	line := bytes.Repeat([]byte("a"), megabyte/2)
+	for range 8 {
+		if _, err = w.Write(line); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	backups, err := w.Backups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("unexpected backups: %v", backups)
+	}
+	for _, backup := range backups {
+		if !strings.HasSuffix(backup, compressSuffix) {
+			t.Fatalf("backup not compressed: %s", backup)
+		}
+	}
+	info, err := os.Stat(filepath.Join(dir, "app.log"))
+	if err != nil || info.Size() != megabyte {
+		t.Fatalf("unexpected current file: %v %v", info, err)
+	}
+}
+
+func TestWriterInterval(t *testing.T) {
+	dir := t.TempDir()
+	current := time.Now()
+	now = func() time.Time { return current }
+	defer func() { now = time.Now }()
+
+	w, err := New(&Config{Filename: filepath.Join(dir, "app.log"), Interval: IntervalHourly})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	w.Write([]byte("first\n"))
+	current = current.Add(time.Hour)
+	w.Write([]byte("second\n"))
+	backups, _ := w.Backups()
+	if len(backups) != 1 {
+		t.Fatalf("unexpected backups: %v", backups)
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, "app.log"))
+	if string(data) != "second\n" {
+		t.Fatalf("unexpected content: %q", data)
+	}
+
+	// 模拟logrotate移走文件后重新打开
+	os.Rename(filepath.Join(dir, "app.log"), filepath.Join(dir, "moved.log"))
+	if err = w.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("third\n"))
+	data, _ = os.ReadFile(filepath.Join(dir, "app.log"))
+	if string(data) != "third\n" {
+		t.Fatalf("unexpected content after reopen: %q", data)
+	}
+}
+
+func TestSink(t *testing.T) {
+	RegisterSink()
+	dir := t.TempDir()
+	u := &url.URL{Scheme: "rotate", Path: filepath.ToSlash(filepath.Join(dir, "sink.log")), RawQuery: "maxSize=10&interval=daily"}
+	sink, _, err := zap.Open(u.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink.Write([]byte("hello\n"))
+	sink.Sync()
+	data, _ := os.ReadFile(filepath.Join(dir, "sink.log"))
+	if string(data) != "hello\n" {
+		t.Fatalf("unexpected content: %q", data)
+	}
+}
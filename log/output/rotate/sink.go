@@ -0,0 +1,105 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package rotate
+
+import (
+	"fmt"
+	"go.uber.org/zap"
+	"net/url"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"syscall"
+)
+
+var (
+	writersMu    sync.Mutex
+	writers      = make(map[*Writer]struct{})
+	signalOnce   sync.Once
+	registerOnce sync.Once
+)
+
+// watchReopen 收到SIGHUP时重新打开所有Writer的文件
+func watchReopen(w *Writer) {
+	writersMu.Lock()
+	writers[w] = struct{}{}
+	writersMu.Unlock()
+	signalOnce.Do(func() {
+		ch := make(chan os.Signal, 1)
+		signal.Notify(ch, syscall.SIGHUP)
+		go func() {
+			for range ch {
+				writersMu.Lock()
+				for w := range writers {
+					if err := w.Reopen(); err != nil {
+						fmt.Fprintf(os.Stderr, "rotate: reopen %s error: %v\n", w.conf.Filename, err)
+					}
+				}
+				writersMu.Unlock()
+			}
+		}()
+	})
+}
+
+func unwatchReopen(w *Writer) {
+	writersMu.Lock()
+	delete(writers, w)
+	writersMu.Unlock()
+}
+
+// RegisterSink 注册zap sink,可重复调用
+// rotate:///var/log/app.log?maxSize=100&interval=daily&maxBackups=7&maxAge=30&compress=true&localTime=true
+func RegisterSink() {
+	registerOnce.Do(func() {
+		_ = zap.RegisterSink("rotate", func(u *url.URL) (zap.Sink, error) {
+			conf, err := ParseURL(u)
+			if err != nil {
+				return nil, err
+			}
+			return New(conf)
+		})
+	})
+}
+
+// ParseURL 从sink url解析配置
+func ParseURL(u *url.URL) (*Config, error) {
+	conf := &Config{Filename: u.Path}
+	if u.Host != "" && u.Host != "localhost" {
+		// rotate://logs/app.log 视为相对路径
+		conf.Filename = u.Host + u.Path
+	}
+	query := u.Query()
+	var err error
+	if v := query.Get("maxSize"); v != "" {
+		if conf.MaxSize, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return nil, fmt.Errorf("rotate: invalid maxSize: %w", err)
+		}
+	}
+	if v := query.Get("maxBackups"); v != "" {
+		if conf.MaxBackups, err = strconv.Atoi(v); err != nil {
+			return nil, fmt.Errorf("rotate: invalid maxBackups: %w", err)
+		}
+	}
+	if v := query.Get("maxAge"); v != "" {
+		if conf.MaxAge, err = strconv.Atoi(v); err != nil {
+			return nil, fmt.Errorf("rotate: invalid maxAge: %w", err)
+		}
+	}
+	if v := query.Get("compress"); v != "" {
+		if conf.Compress, err = strconv.ParseBool(v); err != nil {
+			return nil, fmt.Errorf("rotate: invalid compress: %w", err)
+		}
+	}
+	if v := query.Get("localTime"); v != "" {
+		if conf.LocalTime, err = strconv.ParseBool(v); err != nil {
+			return nil, fmt.Errorf("rotate: invalid localTime: %w", err)
+		}
+	}
+	conf.Interval = Interval(query.Get("interval"))
+	return conf, nil
+}
@@ -0,0 +1,89 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package apidoc
+
+import (
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/hopeio/gox/net/http/router"
+)
+
+// AddRoutes 将路由列表加入文档,已存在的operation不覆盖
+// :name、*name及{name:constraint}参数转换为openapi的{name}路径参数,内置类型约束转换为对应的schema,MethodAny的路由忽略
+func AddRoutes(routes []router.Route) {
+	if Doc == nil {
+		generate()
+	}
+	if Doc.Paths == nil {
+		Doc.Paths = openapi3.NewPaths()
+	}
+	for _, route := range routes {
+		if route.Method == router.MethodAny {
+			continue
+		}
+		path, params := openapiPath(route.Path)
+		item := Doc.Paths.Value(path)
+		if item == nil {
+			item = &openapi3.PathItem{}
+			Doc.Paths.Set(path, item)
+		}
+		if item.GetOperation(route.Method) != nil {
+			continue
+		}
+		op := openapi3.NewOperation()
+		op.OperationID = route.Name
+		for _, param := range params {
+			op.AddParameter(openapi3.NewPathParameter(param.name).WithSchema(param.schema))
+		}
+		op.Responses = openapi3.NewResponses()
+		item.SetOperation(route.Method, op)
+	}
+}
+
+type pathParam struct {
+	name   string
+	schema *openapi3.Schema
+}
+
+func openapiPath(path string) (string, []pathParam) {
+	segments := strings.Split(path, "/")
+	var params []pathParam
+	for i, seg := range segments {
+		if len(seg) < 2 {
+			continue
+		}
+		switch seg[0] {
+		case ':', '*':
+			params = append(params, pathParam{seg[1:], openapi3.NewStringSchema()})
+			segments[i] = "{" + seg[1:] + "}"
+		case '{':
+			name, constraint, ok := strings.Cut(seg[1:len(seg)-1], ":")
+			if !ok {
+				constraint = name
+			}
+			param := pathParam{name, openapi3.NewStringSchema()}
+			switch constraint {
+			case "int", "uint":
+				param.schema = openapi3.NewIntegerSchema()
+			case "float":
+				param.schema = openapi3.NewFloat64Schema()
+			case "bool":
+				param.schema = openapi3.NewBoolSchema()
+			case "uuid":
+				param.schema = openapi3.NewUUIDSchema()
+			default:
+				if ok {
+					param.schema.Pattern = "^(?:" + constraint + ")$"
+				}
+			}
+			params = append(params, param)
+			segments[i] = "{" + name + "}"
+		}
+	}
+	return strings.Join(segments, "/"), params
+}
@@ -0,0 +1,162 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package client
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+type BreakerState uint8
+
+const (
+	BreakerClosed BreakerState = iota
+	BreakerOpen
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// CircuitBreaker 按host熔断,连续失败FailureThreshold次后打开,OpenTimeout后半开放行一个探测请求,探测成功则关闭
+// 非探测请求的结果只在关闭状态下计入,打开前发出的请求在打开后才返回时不影响熔断状态
+type CircuitBreaker struct {
+	// 默认5
+	FailureThreshold int
+	// 默认30s
+	OpenTimeout time.Duration
+	// 判断是否失败,默认出错或5xx
+	IsFailure func(resp *http.Response, err error) bool
+
+	mu    sync.Mutex
+	hosts map[string]*breakerHost
+}
+
+type breakerHost struct {
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+func (b *CircuitBreaker) State(host string) BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if h, ok := b.hosts[host]; ok {
+		if h.state == BreakerOpen && time.Since(h.openedAt) >= b.openTimeout() {
+			return BreakerHalfOpen
+		}
+		return h.state
+	}
+	return BreakerClosed
+}
+
+func (b *CircuitBreaker) openTimeout() time.Duration {
+	if b.OpenTimeout <= 0 {
+		return 30 * time.Second
+	}
+	return b.OpenTimeout
+}
+
+// allow 返回是否放行,probe为半开状态下的探测请求
+func (b *CircuitBreaker) allow(host string) (ok, probe bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.hosts == nil {
+		b.hosts = make(map[string]*breakerHost)
+	}
+	h, ok := b.hosts[host]
+	if !ok {
+		h = &breakerHost{}
+		b.hosts[host] = h
+	}
+	switch h.state {
+	case BreakerOpen:
+		if time.Since(h.openedAt) < b.openTimeout() {
+			return false, false
+		}
+		h.state = BreakerHalfOpen
+		fallthrough
+	case BreakerHalfOpen:
+		if h.probing {
+			return false, false
+		}
+		h.probing = true
+		return true, true
+	}
+	return true, false
+}
+
+func (b *CircuitBreaker) done(host string, probe, failed bool) {
+	threshold := b.FailureThreshold
+	if threshold <= 0 {
+		threshold = 5
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	h := b.hosts[host]
+	if probe {
+		h.probing = false
+		if failed {
+			h.state = BreakerOpen
+			h.openedAt = time.Now()
+			return
+		}
+		h.state = BreakerClosed
+		h.failures = 0
+		return
+	}
+	// 打开或半开期间返回的非探测请求由探测结果决定状态
+	if h.state != BreakerClosed {
+		return
+	}
+	if !failed {
+		h.failures = 0
+		return
+	}
+	h.failures++
+	if h.failures >= threshold {
+		h.state = BreakerOpen
+		h.openedAt = time.Now()
+	}
+}
+
+func (b *CircuitBreaker) isFailure(resp *http.Response, err error) bool {
+	if b.IsFailure != nil {
+		return b.IsFailure(resp, err)
+	}
+	return err != nil || resp.StatusCode >= http.StatusInternalServerError
+}
+
+// Interceptor 熔断打开时直接返回ErrCircuitOpen
+func (b *CircuitBreaker) Interceptor() Interceptor {
+	return func(next RoundTrip) RoundTrip {
+		return func(req *http.Request) (*http.Response, error) {
+			host := req.URL.Host
+			ok, probe := b.allow(host)
+			if !ok {
+				return nil, ErrCircuitOpen
+			}
+			resp, err := next(req)
+			b.done(host, probe, b.isFailure(resp, err))
+			return resp, err
+		}
+	}
+}
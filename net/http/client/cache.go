@@ -0,0 +1,455 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/hopeio/gox/datastructure/cache/gcache"
+	"github.com/hopeio/gox/net/http/consts"
+)
+
+// 从缓存返回的响应会带上consts.HeaderXCache,值为CacheHit或CacheRevalidated
+const (
+	CacheHit         = "HIT"
+	CacheRevalidated = "REVALIDATED"
+)
+
+// CachedResponse 缓存的响应
+type CachedResponse struct {
+	StatusCode int         `json:"statusCode"`
+	Header     http.Header `json:"header"`
+	Body       []byte      `json:"body"`
+	// Vary中的请求头的值
+	Vary         map[string]string `json:"vary,omitempty"`
+	RequestTime  time.Time         `json:"requestTime"`
+	ResponseTime time.Time         `json:"responseTime"`
+}
+
+// CacheStore 缓存存储,key为请求的method及url
+type CacheStore interface {
+	Get(key string) (*CachedResponse, bool)
+	Set(key string, resp *CachedResponse) error
+	Delete(key string) error
+}
+
+type memoryCacheStore struct {
+	cache gcache.Cache
+}
+
+// NewMemoryCacheStore 基于gcache LRU的内存缓存,size为缓存的响应数
+func NewMemoryCacheStore(size int) CacheStore {
+	return &memoryCacheStore{cache: gcache.New(size).LRU().Build()}
+}
+
+func (s *memoryCacheStore) Get(key string) (*CachedResponse, bool) {
+	v, err := s.cache.GetIFPresent(key)
+	if err != nil {
+		return nil, false
+	}
+	return v.(*CachedResponse), true
+}
+
+func (s *memoryCacheStore) Set(key string, resp *CachedResponse) error {
+	return s.cache.Set(key, resp)
+}
+
+func (s *memoryCacheStore) Delete(key string) error {
+	s.cache.Remove(key)
+	return nil
+}
+
+type diskCacheStore struct {
+	dir string
+}
+
+// NewDiskCacheStore 磁盘缓存,每个响应一个文件,文件名为key的sha256
+func NewDiskCacheStore(dir string) (CacheStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &diskCacheStore{dir: dir}, nil
+}
+
+func (s *diskCacheStore) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(s.dir, hex.EncodeToString(sum[:]))
+}
+
+func (s *diskCacheStore) Get(key string) (*CachedResponse, bool) {
+	data, err := os.ReadFile(s.path(key))
+	if err != nil {
+		return nil, false
+	}
+	var resp CachedResponse
+	if err = json.Unmarshal(data, &resp); err != nil {
+		return nil, false
+	}
+	return &resp, true
+}
+
+func (s *diskCacheStore) Set(key string, resp *CachedResponse) error {
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return err
+	}
+	path := s.path(key)
+	tmp := path + DownloadKey
+	if err = os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (s *diskCacheStore) Delete(key string) error {
+	err := os.Remove(s.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// CacheStats 缓存统计
+type CacheStats struct {
+	// 直接从缓存返回
+	Hits uint64
+	// 条件请求返回304后使用缓存
+	Revalidations uint64
+	// 缓存中没有或已过期需要完整请求
+	Misses uint64
+	// 不走缓存的请求(非GET、no-store或指定跳过)
+	Bypasses uint64
+	// 写入缓存的响应数
+	Stores uint64
+	// 从缓存返回(包括304)节省的响应体字节数
+	SavedBytes uint64
+}
+
+// Cache 遵循RFC 9111的私有缓存,作为拦截器使用:
+// 新鲜的响应直接返回,过期的带ETag/Last-Modified发送条件请求,非安全方法会使该url的缓存失效
+// 带Range/If-Range的请求不走缓存,只缓存有明确新鲜度或校验器且长度已知的完整响应,流式响应不会被读入内存
+type Cache struct {
+	store CacheStore
+	// 可缓存的最大响应体,默认10MB
+	MaxBodySize int64
+
+	hits, revalidations, misses, bypasses, stores, savedBytes atomic.Uint64
+}
+
+func NewCache(store CacheStore) *Cache {
+	return &Cache{store: store, MaxBodySize: 10 << 20}
+}
+
+func (c *Cache) Stats() CacheStats {
+	return CacheStats{
+		Hits:          c.hits.Load(),
+		Revalidations: c.revalidations.Load(),
+		Misses:        c.misses.Load(),
+		Bypasses:      c.bypasses.Load(),
+		Stores:        c.stores.Load(),
+		SavedBytes:    c.savedBytes.Load(),
+	}
+}
+
+// Cache 为Client添加响应缓存
+func (d *Client) Cache(cache *Cache) *Client {
+	return d.Use(cache.Interceptor())
+}
+
+type bypassCacheKey struct{}
+
+// WithBypassCache 该ctx的请求不读写缓存
+func WithBypassCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, bypassCacheKey{}, true)
+}
+
+// BypassCache 该请求不读写缓存
+func (req *Request) BypassCache() *Request {
+	if req.ctx == nil {
+		req.ctx = context.Background()
+	}
+	req.ctx = WithBypassCache(req.ctx)
+	return req
+}
+
+func cacheKey(req *http.Request) string {
+	return http.MethodGet + " " + req.URL.String()
+}
+
+func (c *Cache) Interceptor() Interceptor {
+	return func(next RoundTrip) RoundTrip {
+		return func(req *http.Request) (*http.Response, error) {
+			if bypass, _ := req.Context().Value(bypassCacheKey{}).(bool); bypass {
+				c.bypasses.Add(1)
+				return next(req)
+			}
+			// 分段请求(如分段下载)的响应只是资源的一部分
+			if req.Header.Get(consts.HeaderRange) != "" || req.Header.Get(consts.HeaderIfRange) != "" {
+				c.bypasses.Add(1)
+				return next(req)
+			}
+			if req.Method != http.MethodGet {
+				c.bypasses.Add(1)
+				resp, err := next(req)
+				// 非安全方法成功后使缓存失效
+				if err == nil && req.Method != http.MethodHead && req.Method != http.MethodOptions && resp.StatusCode < 400 {
+					c.store.Delete(cacheKey(req))
+				}
+				return resp, err
+			}
+			reqCC := parseCacheControl(req.Header)
+			if _, ok := reqCC["no-store"]; ok {
+				c.bypasses.Add(1)
+				return next(req)
+			}
+			key := cacheKey(req)
+			cached, ok := c.store.Get(key)
+			if ok && !cached.varyMatch(req) {
+				ok = false
+			}
+			if !ok {
+				c.misses.Add(1)
+				return c.fetch(next, req, key, nil)
+			}
+			if cached.fresh(reqCC, time.Now()) {
+				c.hits.Add(1)
+				c.savedBytes.Add(uint64(len(cached.Body)))
+				return cached.response(req, CacheHit), nil
+			}
+			etag, lastModified := cached.Header.Get(consts.HeaderETag), cached.Header.Get(consts.HeaderLastModified)
+			if etag == "" && lastModified == "" {
+				c.misses.Add(1)
+				return c.fetch(next, req, key, nil)
+			}
+			condReq := req.Clone(req.Context())
+			if etag != "" {
+				condReq.Header.Set(consts.HeaderIfNoneMatch, etag)
+			}
+			if lastModified != "" {
+				condReq.Header.Set(consts.HeaderIfModifiedSince, lastModified)
+			}
+			return c.fetch(next, condReq, key, cached)
+		}
+	}
+}
+
+func (c *Cache) fetch(next RoundTrip, req *http.Request, key string, cached *CachedResponse) (*http.Response, error) {
+	requestTime := time.Now()
+	resp, err := next(req)
+	if err != nil {
+		return resp, err
+	}
+	responseTime := time.Now()
+	if cached != nil {
+		if resp.StatusCode == http.StatusNotModified {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+			c.revalidations.Add(1)
+			c.savedBytes.Add(uint64(len(cached.Body)))
+			// 用304的header更新缓存
+			updated := *cached
+			updated.Header = cached.Header.Clone()
+			for k, v := range resp.Header {
+				updated.Header[k] = v
+			}
+			updated.RequestTime, updated.ResponseTime = requestTime, responseTime
+			c.store.Set(key, &updated)
+			return updated.response(req, CacheRevalidated), nil
+		}
+		c.misses.Add(1)
+	}
+	if !storable(resp) {
+		return resp, nil
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, c.MaxBodySize+1))
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	if int64(len(body)) > c.MaxBodySize {
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+		return resp, nil
+	}
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	entry := &CachedResponse{
+		StatusCode:   resp.StatusCode,
+		Header:       resp.Header.Clone(),
+		Body:         body,
+		RequestTime:  requestTime,
+		ResponseTime: responseTime,
+	}
+	if vary := resp.Header.Values(consts.HeaderVary); len(vary) > 0 {
+		entry.Vary = make(map[string]string)
+		for _, v := range vary {
+			for _, name := range strings.Split(v, ",") {
+				name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+				if name == "*" {
+					return resp, nil
+				}
+				entry.Vary[name] = req.Header.Get(name)
+			}
+		}
+	}
+	if err = c.store.Set(key, entry); err == nil {
+		c.stores.Add(1)
+	}
+	return resp, nil
+}
+
+// 默认可缓存(可启发式计算新鲜度)的状态码
+var heuristicStatus = map[int]bool{
+	http.StatusOK: true, http.StatusNonAuthoritativeInfo: true, http.StatusNoContent: true,
+	http.StatusMultipleChoices: true, http.StatusMovedPermanently: true, http.StatusNotFound: true, http.StatusMethodNotAllowed: true,
+	http.StatusGone: true, http.StatusRequestURITooLong: true, http.StatusNotImplemented: true, http.StatusPermanentRedirect: true,
+}
+
+// 流式的内容类型,响应可能长时间不结束
+var streamingContentTypes = map[string]bool{
+	consts.ContentTypeEventStream: true,
+	consts.ContentTypeNDJson:      true,
+}
+
+// storable 是否读取响应体并写入缓存,没有新鲜度也没有校验器的响应缓存后无法使用,不读取
+func storable(resp *http.Response) bool {
+	cc := parseCacheControl(resp.Header)
+	if _, ok := cc["no-store"]; ok {
+		return false
+	}
+	if resp.StatusCode == http.StatusPartialContent || resp.ContentLength < 0 {
+		return false
+	}
+	mediaType, _, _ := strings.Cut(resp.Header.Get(consts.HeaderContentType), ";")
+	if streamingContentTypes[strings.ToLower(strings.TrimSpace(mediaType))] {
+		return false
+	}
+	_, maxAge := cc["max-age"]
+	if !maxAge && resp.Header.Get(consts.HeaderExpires) == "" &&
+		resp.Header.Get(consts.HeaderETag) == "" && resp.Header.Get(consts.HeaderLastModified) == "" {
+		return false
+	}
+	if heuristicStatus[resp.StatusCode] {
+		return true
+	}
+	// 其他状态码需要明确的新鲜度
+	if maxAge {
+		return resp.StatusCode < 500
+	}
+	return false
+}
+
+func (r *CachedResponse) varyMatch(req *http.Request) bool {
+	for name, value := range r.Vary {
+		if req.Header.Get(name) != value {
+			return false
+		}
+	}
+	return true
+}
+
+// freshnessLifetime max-age > Expires > 10%的Last-Modified启发式
+func (r *CachedResponse) freshnessLifetime() time.Duration {
+	cc := parseCacheControl(r.Header)
+	if _, ok := cc["no-cache"]; ok {
+		return 0
+	}
+	if maxAge, ok := cc["max-age"]; ok {
+		if seconds, err := strconv.ParseInt(maxAge, 10, 64); err == nil {
+			return time.Duration(seconds) * time.Second
+		}
+		return 0
+	}
+	date, err := http.ParseTime(r.Header.Get(consts.HeaderDate))
+	if err != nil {
+		date = r.ResponseTime
+	}
+	if expires := r.Header.Get(consts.HeaderExpires); expires != "" {
+		t, err := http.ParseTime(expires)
+		if err != nil {
+			return 0
+		}
+		return t.Sub(date)
+	}
+	if lastModified, err := http.ParseTime(r.Header.Get(consts.HeaderLastModified)); err == nil && heuristicStatus[r.StatusCode] {
+		if d := date.Sub(lastModified); d > 0 {
+			return d / 10
+		}
+	}
+	return 0
+}
+
+// currentAge RFC 9111 4.2.3
+func (r *CachedResponse) currentAge(now time.Time) time.Duration {
+	var apparentAge time.Duration
+	if date, err := http.ParseTime(r.Header.Get(consts.HeaderDate)); err == nil {
+		apparentAge = max(0, r.ResponseTime.Sub(date))
+	}
+	ageValue, _ := strconv.ParseInt(r.Header.Get(consts.HeaderAge), 10, 64)
+	correctedAge := time.Duration(ageValue)*time.Second + r.ResponseTime.Sub(r.RequestTime)
+	return max(apparentAge, correctedAge) + now.Sub(r.ResponseTime)
+}
+
+func (r *CachedResponse) fresh(reqCC map[string]string, now time.Time) bool {
+	if _, ok := reqCC["no-cache"]; ok {
+		return false
+	}
+	lifetime := r.freshnessLifetime()
+	if maxAge, ok := reqCC["max-age"]; ok {
+		if seconds, err := strconv.ParseInt(maxAge, 10, 64); err == nil {
+			lifetime = min(lifetime, time.Duration(seconds)*time.Second)
+		}
+	}
+	return lifetime > r.currentAge(now)
+}
+
+func (r *CachedResponse) response(req *http.Request, status string) *http.Response {
+	header := r.Header.Clone()
+	header.Set(consts.HeaderXCache, status)
+	header.Set(consts.HeaderAge, strconv.FormatInt(int64(r.currentAge(time.Now())/time.Second), 10))
+	return &http.Response{
+		Status:        strconv.Itoa(r.StatusCode) + " " + http.StatusText(r.StatusCode),
+		StatusCode:    r.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(r.Body)),
+		ContentLength: int64(len(r.Body)),
+		Request:       req,
+	}
+}
+
+func parseCacheControl(header http.Header) map[string]string {
+	cc := make(map[string]string)
+	for _, v := range header.Values(consts.HeaderCacheControl) {
+		for _, directive := range strings.Split(v, ",") {
+			directive = strings.TrimSpace(directive)
+			if directive == "" {
+				continue
+			}
+			name, value, _ := strings.Cut(directive, "=")
+			cc[strings.ToLower(strings.TrimSpace(name))] = strings.Trim(strings.TrimSpace(value), `"`)
+		}
+	}
+	return cc
+}
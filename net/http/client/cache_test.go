@@ -0,0 +1,172 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package client
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hopeio/gox/net/http/consts"
+)
+
+func TestCache(t *testing.T) {
+	var requests, notModified atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		switch r.URL.Path {
+		case "/fresh":
+			w.Header().Set(consts.HeaderCacheControl, "max-age=60")
+		case "/etag":
+			w.Header().Set(consts.HeaderCacheControl, "no-cache")
+			w.Header().Set(consts.HeaderETag, `"v1"`)
+			if r.Header.Get(consts.HeaderIfNoneMatch) == `"v1"` {
+				notModified.Add(1)
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		case "/nostore":
+			w.Header().Set(consts.HeaderCacheControl, "no-store")
+		}
+		w.Write([]byte(`{"path":"` + r.URL.Path + `"}`))
+	}))
+	defer srv.Close()
+
+	for name, newStore := range map[string]func() CacheStore{
+		"memory": func() CacheStore { return NewMemoryCacheStore(16) },
+		"disk": func() CacheStore {
+			store, err := NewDiskCacheStore(t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			return store
+		},
+	} {
+		requests.Store(0)
+		notModified.Store(0)
+		cache := NewCache(newStore())
+		c := New().DisableLog().Cache(cache)
+		get := func(path string) string {
+			var resp struct {
+				Path string `json:"path"`
+			}
+			if err := c.Get(srv.URL+path, nil, &resp); err != nil {
+				t.Fatal(err)
+			}
+			return resp.Path
+		}
+		for range 3 {
+			for _, path := range []string{"/fresh", "/etag", "/nostore"} {
+				if p := get(path); p != path {
+					t.Fatalf("%s: unexpected response %s", name, p)
+				}
+			}
+		}
+		// /fresh只请求一次,/etag三次(两次304),/nostore三次
+		if requests.Load() != 7 || notModified.Load() != 2 {
+			t.Fatalf("%s: unexpected requests: %d %d", name, requests.Load(), notModified.Load())
+		}
+		if err := c.GetRequest(srv.URL+"/fresh").BypassCache().Do(nil, nil); err != nil || requests.Load() != 8 {
+			t.Fatalf("%s: bypass failed: %v", name, err)
+		}
+		// 非安全方法使缓存失效
+		c.Post(srv.URL+"/fresh", nil, nil)
+		get("/fresh")
+		stats := cache.Stats()
+		if stats.Hits != 2 || stats.Revalidations != 2 || stats.Misses != 6 || stats.Bypasses != 2 || stats.SavedBytes == 0 {
+			t.Fatalf("%s: unexpected stats: %+v", name, stats)
+		}
+	}
+}
+
+func TestCacheUncacheable(t *testing.T) {
+	content := []byte("0123456789abcdefghij")
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		switch r.URL.Path {
+		case "/range":
+			w.Header().Set(consts.HeaderCacheControl, "max-age=60")
+			http.ServeContent(w, r, "data", time.Time{}, bytes.NewReader(content))
+		case "/partial":
+			w.Header().Set(consts.HeaderCacheControl, "max-age=60")
+			w.Header().Set(consts.HeaderContentRange, fmt.Sprintf("bytes 0-0/%d", len(content)))
+			w.WriteHeader(http.StatusPartialContent)
+			w.Write(content[:1])
+		case "/plain":
+			w.Write(content)
+		case "/stream":
+			w.Header().Set(consts.HeaderCacheControl, "max-age=60")
+			w.Header().Set(consts.HeaderContentType, consts.ContentTypeEventStream)
+			fmt.Fprint(w, "data: first\n\n")
+			w.(http.Flusher).Flush()
+			time.Sleep(500 * time.Millisecond)
+		}
+	}))
+	defer srv.Close()
+
+	cache := NewCache(NewMemoryCacheStore(16))
+	c := New().DisableLog().Cache(cache)
+	do := func(path, rangeHeader string) (int, string) {
+		req := c.GetRequest(srv.URL + path)
+		if rangeHeader != "" {
+			req.AddHeader(consts.HeaderRange, rangeHeader)
+		}
+		var resp *http.Response
+		if err := req.Do(nil, &resp); err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+
+	// 分段请求不读写缓存,之后的完整请求得到完整内容
+	if code, body := do("/range", "bytes=0-0"); code != http.StatusPartialContent || body != "0" {
+		t.Fatalf("unexpected probe: %d %s", code, body)
+	}
+	if code, body := do("/range", "bytes=10-19"); code != http.StatusPartialContent || body != string(content[10:]) {
+		t.Fatalf("unexpected range: %d %s", code, body)
+	}
+	for range 2 {
+		if code, body := do("/range", ""); code != http.StatusOK || body != string(content) {
+			t.Fatalf("unexpected full response: %d %s", code, body)
+		}
+	}
+	if requests.Load() != 3 {
+		t.Fatalf("unexpected requests: %d", requests.Load())
+	}
+	// 206及没有缓存头的200不缓存
+	for range 2 {
+		do("/partial", "")
+		do("/plain", "")
+	}
+	if requests.Load() != 7 || cache.Stats().Stores != 1 {
+		t.Fatalf("unexpected requests: %d %+v", requests.Load(), cache.Stats())
+	}
+
+	// 流式响应不等待结束才返回
+	req := c.GetRequest(srv.URL + "/stream")
+	begin := time.Now()
+	for event, err := range req.DoSSE(nil) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if event.Data != "first" || time.Since(begin) > 300*time.Millisecond {
+			t.Fatalf("stream buffered: %s %s", event.Data, time.Since(begin))
+		}
+		break
+	}
+	if cache.Stats().Stores != 1 {
+		t.Fatalf("stream stored: %+v", cache.Stats())
+	}
+}
@@ -8,6 +8,7 @@ package client
 
 import (
 	httpi "github.com/hopeio/gox/net/http"
+	"github.com/hopeio/gox/scheduler/retry"
 	"io"
 	"net"
 	"net/http"
@@ -73,9 +74,13 @@ type Client struct {
 	logLevel LogLevel
 
 	// retry
-	retryTimes    int
+	// RetryTimes使用的重试间隔
 	retryInterval time.Duration
 	retryHandler  func(*http.Request)
+	// 为nil不重试
+	retryPolicy *retry.Policy
+
+	interceptors []Interceptor
 }
 
 func New() *Client {
@@ -167,17 +172,51 @@ func (d *Client) SetHttpClient(opt HttpClientOption) *Client {
 	return d
 }
 
+// RetryTimes 最多请求retryTimes次,间隔为当前的重试间隔,等同于固定间隔的RetryPolicy
 func (d *Client) RetryTimes(retryTimes int) *Client {
-	d.retryTimes = retryTimes
-	return d
+	return d.RetryTimesWithInterval(retryTimes, d.retryInterval)
 }
 
+// RetryTimesWithInterval 最多请求retryTimes次,每次间隔retryInterval,等同于固定间隔的RetryPolicy
 func (d *Client) RetryTimesWithInterval(retryTimes int, retryInterval time.Duration) *Client {
-	d.retryTimes = retryTimes
 	d.retryInterval = retryInterval
+	d.retryPolicy = newRetryPolicy(retryTimes, retryInterval)
+	return d
+}
+
+// RetryPolicy 设置重试策略,覆盖RetryTimes及RetryTimesWithInterval的设置,为nil不重试
+func (d *Client) RetryPolicy(policy *retry.Policy) *Client {
+	d.retryPolicy = policy
 	return d
 }
 
+// newRetryPolicy 最多尝试times次的固定间隔策略,times为0时不重试
+func newRetryPolicy(times int, interval time.Duration) *retry.Policy {
+	if times <= 0 {
+		return nil
+	}
+	return &retry.Policy{MaxAttempts: times, Backoff: retry.Constant(interval)}
+}
+
+// responseRetryPolicy 未设置重试时,responseHandler要求的重试不限次数,与原有行为一致
+var responseRetryPolicy = &retry.Policy{Backoff: retry.Constant(200 * time.Millisecond)}
+
+// nextRetry 第reqTimes次请求返回err后是否重试,以及重试前等待的时间,所有重试都经由此处
+func (d *Client) nextRetry(begin time.Time, reqTimes int, err error, last time.Duration) (time.Duration, bool) {
+	return nextRetry(d.retryPolicy, begin, reqTimes, err, last)
+}
+
+func nextRetry(policy *retry.Policy, begin time.Time, reqTimes int, err error, last time.Duration) (time.Duration, bool) {
+	if policy == nil {
+		return 0, false
+	}
+	delay, ok := policy.Next(begin, reqTimes, err, last)
+	if ok && policy.OnRetry != nil {
+		policy.OnRetry(reqTimes, err, delay)
+	}
+	return delay, ok
+}
+
 func (d *Client) RetryHandler(handle func(r *http.Request)) *Client {
 	d.retryHandler = handle
 	return d
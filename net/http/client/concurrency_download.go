@@ -0,0 +1,404 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package client
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	httpi "github.com/hopeio/gox/net/http"
+	"github.com/hopeio/gox/net/http/consts"
+	"github.com/hopeio/gox/os/fs"
+	"github.com/hopeio/gox/scheduler/retry"
+	"github.com/hopeio/gox/terminal"
+)
+
+const downloadStateSuffix = ".state"
+
+// 探测Range支持及文件大小,只请求第一个字节
+const probeRange = "bytes=0-0"
+
+var (
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+	errRangeIgnored     = errors.New("server ignored range")
+)
+
+// 进度回调的间隔
+var progressInterval = 200 * time.Millisecond
+
+type downloadPart struct {
+	Start int64 `json:"start"`
+	// 包含end
+	End     int64 `json:"end"`
+	Written int64 `json:"written"`
+}
+
+func (p *downloadPart) written() int64 {
+	return atomic.LoadInt64(&p.Written)
+}
+
+// downloadState 分段下载的状态,保存在filepath+DownloadKey+".state",中断后从每段已写入的位置继续
+type downloadState struct {
+	Url          string          `json:"url"`
+	Size         int64           `json:"size"`
+	ETag         string          `json:"etag,omitempty"`
+	LastModified string          `json:"lastModified,omitempty"`
+	Parts        []*downloadPart `json:"parts"`
+}
+
+func (s *downloadState) written() int64 {
+	var written int64
+	for _, part := range s.Parts {
+		written += part.written()
+	}
+	return written
+}
+
+func (s *downloadState) save(path string) error {
+	snapshot := *s
+	snapshot.Parts = make([]*downloadPart, len(s.Parts))
+	for i, part := range s.Parts {
+		snapshot.Parts[i] = &downloadPart{Start: part.Start, End: part.End, Written: part.written()}
+	}
+	data, err := json.Marshal(&snapshot)
+	if err != nil {
+		return err
+	}
+	tmp := path + DownloadKey
+	if err = os.WriteFile(tmp, data, 0666); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func loadDownloadState(path string) *downloadState {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var state downloadState
+	if err = json.Unmarshal(data, &state); err != nil {
+		return nil
+	}
+	return &state
+}
+
+func newDownloadState(url string, size int64, header http.Header, concurrency int) *downloadState {
+	state := &downloadState{Url: url, Size: size, ETag: header.Get(consts.HeaderETag), LastModified: header.Get(consts.HeaderLastModified)}
+	partSize := size / int64(concurrency)
+	if partSize == 0 {
+		partSize = size
+	}
+	for start := int64(0); start < size; start += partSize {
+		end := start + partSize - 1
+		// 余数并入最后一段
+		if end >= size-1 || size-1-end < partSize {
+			end = size - 1
+		}
+		state.Parts = append(state.Parts, &downloadPart{Start: start, End: end})
+		if end == size-1 {
+			break
+		}
+	}
+	return state
+}
+
+// ifRange 分段请求的If-Range,资源在下载过程中变化时服务端返回完整内容而不是拼接不同版本,弱ETag不能用于If-Range
+func (s *downloadState) ifRange() string {
+	if s.ETag != "" && !strings.HasPrefix(s.ETag, "W/") {
+		return s.ETag
+	}
+	return s.LastModified
+}
+
+func (s *downloadState) match(url string, size int64, header http.Header) bool {
+	return s.Url == url && s.Size == size && s.ETag == header.Get(consts.HeaderETag) && s.LastModified == header.Get(consts.HeaderLastModified)
+}
+
+// ConcurrencyDownload 分段并发下载,每段单独重试,中断后再次调用从每段已下载的位置继续
+// 服务端不支持Range或资源在下载过程中变化时,丢弃已下载的分段并退化为单连接下载
+func (dReq *DownloadReq) ConcurrencyDownload(filepath string, concurrency int) error {
+	if dReq.mode&DModeOverwrite == 0 && fs.Exist(filepath) {
+		return nil
+	}
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	// 探测是否支持Range及文件大小
+	resp, err := dReq.GetResponse(func(req *http.Request) {
+		req.Header.Set(consts.HeaderRange, probeRange)
+	})
+	if err != nil {
+		return err
+	}
+	var size int64
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		_, _, size, err = httpi.ParseContentRange(resp.Header.Get(consts.HeaderContentRange))
+		resp.Body.Close()
+		if err != nil {
+			size = 0
+		}
+	case http.StatusOK:
+		return dReq.singleDownload(filepath, resp)
+	default:
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusNotFound {
+			return ErrNotFound
+		}
+		return fmt.Errorf("请求错误,status code:%d,url:%s", resp.StatusCode, dReq.Url)
+	}
+	if size <= 0 {
+		// 大小未知,无法分段
+		resp, err = dReq.GetResponse()
+		if err != nil {
+			return err
+		}
+		return dReq.singleDownload(filepath, resp)
+	}
+
+	statePath := filepath + DownloadKey + downloadStateSuffix
+	state := loadDownloadState(statePath)
+	if state == nil || !state.match(dReq.Url, size, resp.Header) {
+		state = newDownloadState(dReq.Url, size, resp.Header, concurrency)
+	}
+	f, err := fs.OpenFile(filepath+DownloadKey, os.O_RDWR|os.O_CREATE, 0666)
+	if err != nil {
+		return err
+	}
+	if err = f.Truncate(size); err != nil {
+		f.Close()
+		return err
+	}
+	if err = state.save(statePath); err != nil {
+		f.Close()
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(dReq.ctx)
+	stop := dReq.watchProgress(state.written, size, func() {
+		state.save(statePath)
+	})
+	var wg sync.WaitGroup
+	var errOnce sync.Once
+	var partErr error
+	ifRange := state.ifRange()
+	for _, part := range state.Parts {
+		if part.Start+part.written() > part.End {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := dReq.downloadPart(ctx, f, part, ifRange); err != nil {
+				errOnce.Do(func() {
+					partErr = err
+					cancel()
+				})
+			}
+		}()
+	}
+	wg.Wait()
+	cancel()
+	stop()
+	saveErr := state.save(statePath)
+	if err = f.Close(); partErr == nil {
+		partErr = err
+	}
+	if errors.Is(partErr, errRangeIgnored) {
+		os.Remove(filepath + DownloadKey)
+		os.Remove(statePath)
+		resp, err = dReq.GetResponse()
+		if err != nil {
+			return err
+		}
+		return dReq.singleDownload(filepath, resp)
+	}
+	if partErr != nil {
+		return partErr
+	}
+	if saveErr != nil {
+		return saveErr
+	}
+	if err = dReq.verify(filepath + DownloadKey); err != nil {
+		os.Remove(filepath + DownloadKey)
+		os.Remove(statePath)
+		return err
+	}
+	if err = os.Rename(filepath+DownloadKey, filepath); err != nil {
+		return err
+	}
+	return os.Remove(statePath)
+}
+
+func (dReq *DownloadReq) downloadPart(ctx context.Context, f *os.File, part *downloadPart, ifRange string) error {
+	var delay time.Duration
+	begin := time.Now()
+	for reqTimes := 1; ; reqTimes++ {
+		err := dReq.fetchPart(ctx, f, part, ifRange)
+		if err == nil || errors.Is(err, errRangeIgnored) || ctx.Err() != nil {
+			return err
+		}
+		var ok bool
+		if delay, ok = dReq.downloader.nextRetry(begin, reqTimes, err, delay); !ok {
+			return err
+		}
+		if err = retry.Sleep(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
+func (dReq *DownloadReq) fetchPart(ctx context.Context, f *os.File, part *downloadPart, ifRange string) error {
+	offset := part.Start + part.written()
+	if offset > part.End {
+		return nil
+	}
+	req := *dReq
+	req.ctx = ctx
+	resp, err := req.GetResponse(func(r *http.Request) {
+		r.Header.Set(consts.HeaderRange, httpi.FormatRange(offset, part.End))
+		if ifRange != "" {
+			r.Header.Set(consts.HeaderIfRange, ifRange)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusPartialContent {
+		if resp.StatusCode == http.StatusOK {
+			return errRangeIgnored
+		}
+		return fmt.Errorf("请求错误,status code:%d,url:%s", resp.StatusCode, dReq.Url)
+	}
+	w := &partWriter{w: io.NewOffsetWriter(f, offset), part: part}
+	n, err := io.Copy(w, io.LimitReader(resp.Body, part.End-offset+1))
+	if err != nil {
+		return err
+	}
+	if n < part.End-offset+1 {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+
+// partWriter 写入文件后再记录进度,保存的状态不会超过实际写入的位置
+type partWriter struct {
+	w    io.Writer
+	part *downloadPart
+}
+
+func (w *partWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	atomic.AddInt64(&w.part.Written, int64(n))
+	return n, err
+}
+
+type countWriter struct {
+	written atomic.Int64
+}
+
+func (w *countWriter) Write(p []byte) (int, error) {
+	w.written.Add(int64(len(p)))
+	return len(p), nil
+}
+
+// singleDownload 单连接下载,用于服务端不支持Range时
+func (dReq *DownloadReq) singleDownload(filepath string, resp *http.Response) error {
+	defer resp.Body.Close()
+	if dReq.mode&DModeOverwrite == 0 && fs.Exist(filepath) {
+		return nil
+	}
+	counter := &countWriter{}
+	stop := dReq.watchProgress(counter.written.Load, httpi.GetContentLength(resp.Header), nil)
+	err := fs.Download(filepath+DownloadKey, io.TeeReader(resp.Body, counter))
+	stop()
+	if err != nil {
+		return err
+	}
+	if err = dReq.verify(filepath + DownloadKey); err != nil {
+		os.Remove(filepath + DownloadKey)
+		return err
+	}
+	return os.Rename(filepath+DownloadKey, filepath)
+}
+
+// watchProgress 定时回调进度,tick在每次回调时调用(如保存状态),返回的函数停止并做最后一次回调
+func (dReq *DownloadReq) watchProgress(written func() int64, total int64, tick func()) func() {
+	if dReq.progress == nil && tick == nil {
+		return func() {}
+	}
+	report := func() {
+		if dReq.progress != nil {
+			dReq.progress(written(), total)
+		}
+	}
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(progressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				report()
+				if tick != nil {
+					tick()
+				}
+			case <-done:
+				report()
+				return
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+func (dReq *DownloadReq) verify(path string) error {
+	if dReq.newHash == nil {
+		return nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	h := dReq.newHash()
+	if _, err = io.Copy(h, f); err != nil {
+		return err
+	}
+	if sum := hex.EncodeToString(h.Sum(nil)); sum != dReq.checksum {
+		return fmt.Errorf("%w: expected %s, got %s", ErrChecksumMismatch, dReq.checksum, sum)
+	}
+	return nil
+}
+
+// TerminalProgress 在终端绘制进度条的进度回调
+func TerminalProgress(prefix string) func(written, total int64) {
+	return func(written, total int64) {
+		if total <= 0 {
+			terminal.DrawProgressBar(prefix, 0, 50, fmt.Sprintf("%d bytes", written))
+			return
+		}
+		terminal.DrawProgressBar(prefix, float32(written)/float32(total), 50)
+	}
+}
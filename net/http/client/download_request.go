@@ -16,6 +16,8 @@ import (
 	"github.com/hopeio/gox/net/http/consts"
 	urli "github.com/hopeio/gox/net/url"
 	"github.com/hopeio/gox/os/fs"
+	"github.com/hopeio/gox/scheduler/retry"
+	"hash"
 	"io"
 	"net/http"
 	"os"
@@ -43,6 +45,9 @@ type DownloadReq struct {
 	header     http.Header  //请求级请求头
 	mode       DownloadMode // 模式，0-强制覆盖，1-不存在下载，2-断续下载
 	rangeSize  int64
+	newHash    func() hash.Hash
+	checksum   string
+	progress   func(written, total int64)
 }
 
 func NewDownloadReq(url string) *DownloadReq {
@@ -94,6 +99,19 @@ func (dReq *DownloadReq) OverwriteMode() *DownloadReq {
 	return dReq
 }
 
+// Checksum 下载完成后校验,如md5.New或sha256.New,sum为16进制
+func (dReq *DownloadReq) Checksum(newHash func() hash.Hash, sum string) *DownloadReq {
+	dReq.newHash = newHash
+	dReq.checksum = strings.ToLower(sum)
+	return dReq
+}
+
+// Progress 下载进度回调,由单个goroutine定时调用,total未知时为0
+func (dReq *DownloadReq) Progress(progress func(written, total int64)) *DownloadReq {
+	dReq.progress = progress
+	return dReq
+}
+
 func (dReq *DownloadReq) GetResponse(options ...func(*http.Request)) (*http.Response, error) {
 	d := dReq.downloader
 	req, err := http.NewRequestWithContext(dReq.ctx, http.MethodGet, dReq.Url, nil)
@@ -104,7 +122,8 @@ func (dReq *DownloadReq) GetResponse(options ...func(*http.Request)) (*http.Resp
 	// 如果自己设置了接受编码，http库不会自动gzip解压，需要自己处理，不加Accept-Encoding和Range头会自动设置gzip
 	//req.Header.Set("Accept-Encoding", "gzip, deflate")
 	if dReq.header != nil {
-		req.Header = dReq.header
+		// 并发下载时每个分段设置各自的Range
+		req.Header = dReq.header.Clone()
 	}
 	if _, ok := req.Header[consts.HeaderAcceptLanguage]; !ok {
 		req.Header.Set(consts.HeaderAcceptLanguage, "zh-CN,zh;q=0.9;charset=utf-8")
@@ -124,22 +143,25 @@ func (dReq *DownloadReq) GetResponse(options ...func(*http.Request)) (*http.Resp
 	}
 
 	var resp *http.Response
-	for i := range d.retryTimes {
-		if i > 0 {
-			time.Sleep(d.retryInterval)
-		}
-		resp, err = d.httpClient.Do(req)
-		if err != nil {
-			log.Warn(err, "url:", req.URL.Path)
-			if strings.HasPrefix(err.Error(), "dial tcp: lookup") {
-				return nil, err
-			}
-			continue
-		} else {
+	var delay time.Duration
+	begin := time.Now()
+	for reqTimes := 1; ; reqTimes++ {
+		resp, err = d.do(req)
+		if err == nil {
 			return resp, nil
 		}
+		log.Warn(err, "url:", req.URL.Path)
+		if strings.HasPrefix(err.Error(), "dial tcp: lookup") {
+			return nil, err
+		}
+		var ok bool
+		if delay, ok = d.nextRetry(begin, reqTimes, err, delay); !ok {
+			return nil, err
+		}
+		if err = retry.Sleep(dReq.ctx, delay); err != nil {
+			return nil, err
+		}
 	}
-	return nil, err
 }
 
 func (dReq *DownloadReq) GetReader() (io.ReadCloser, error) {
@@ -195,9 +217,6 @@ func (dReq *DownloadReq) Download(filepath string) error {
 	if dReq.mode&DModeOverwrite == 0 && fs.Exist(filepath) {
 		return nil
 	}
-	if dReq.downloader.retryTimes == 0 {
-		dReq.downloader.retryTimes = 1
-	}
 	if dReq.mode&DModeForceContinue != 0 {
 		return dReq.continuationDownload(filepath)
 	}
@@ -205,7 +224,9 @@ func (dReq *DownloadReq) Download(filepath string) error {
 	var err error
 	var resp *http.Response
 	var notContinuation bool
-	for range dReq.downloader.retryTimes {
+	var delay time.Duration
+	begin := time.Now()
+	for reqTimes := 1; ; reqTimes++ {
 		resp, reader, err = dReq.getReader()
 		if err != nil {
 			return err
@@ -224,21 +245,21 @@ func (dReq *DownloadReq) Download(filepath string) error {
 			return nil
 		}
 		log.Warn(err, dReq.Url, filepath)
+		if err = dReq.waitRetry(begin, reqTimes, err, &delay); err != nil {
+			return err
+		}
 	}
-	return err
 }
 
 func (dReq *DownloadReq) DownloadAttachment(dir string) error {
-
-	if dReq.downloader.retryTimes == 0 {
-		dReq.downloader.retryTimes = 1
-	}
 	var reader io.ReadCloser
 	var err error
 	var resp *http.Response
 	filepath := dir + fs.PathSeparator + path.Base(dReq.Url)
 	first := true
-	for range dReq.downloader.retryTimes {
+	var delay time.Duration
+	begin := time.Now()
+	for reqTimes := 1; ; reqTimes++ {
 		resp, reader, err = dReq.getReader()
 		if err != nil {
 			return err
@@ -272,8 +293,10 @@ func (dReq *DownloadReq) DownloadAttachment(dir string) error {
 			return nil
 		}
 		log.Warn(err, dReq.Url, filepath)
+		if err = dReq.waitRetry(begin, reqTimes, err, &delay); err != nil {
+			return err
+		}
 	}
-	return err
 }
 
 func (dReq *DownloadReq) continuationDownload(filepath string) error {
@@ -289,7 +312,9 @@ func (dReq *DownloadReq) continuationDownload(filepath string) error {
 
 	offset := fileinfo.Size()
 	var reader io.ReadCloser
-	for range dReq.downloader.retryTimes {
+	var delay time.Duration
+	begin := time.Now()
+	for reqTimes := 1; ; reqTimes++ {
 		dReq.header.Set(consts.HeaderRange, httpi.FormatRange(offset, 0))
 
 		reader, err = dReq.GetReader()
@@ -298,36 +323,37 @@ func (dReq *DownloadReq) continuationDownload(filepath string) error {
 				f.Close()
 				return os.Rename(filepath+DownloadKey, filepath)
 			}
-			continue
-		}
+		} else {
+			var written int64
+			written, err = io.Copy(f, reader)
+			reader.Close()
 
-		var written int64
-		written, err = io.Copy(f, reader)
-		reader.Close()
+			if err == nil || err == io.EOF {
+				f.Close()
+				return os.Rename(filepath+DownloadKey, filepath)
+			}
 
-		if err == nil || err == io.EOF {
+			offset += written
+		}
+		if err = dReq.waitRetry(begin, reqTimes, err, &delay); err != nil {
 			f.Close()
-			return os.Rename(filepath+DownloadKey, filepath)
+			return err
 		}
+	}
+}
 
-		offset += written
+// waitRetry 按Downloader的重试策略等待下一次尝试,不再重试时返回err
+func (dReq *DownloadReq) waitRetry(begin time.Time, reqTimes int, err error, delay *time.Duration) error {
+	var ok bool
+	if *delay, ok = dReq.downloader.nextRetry(begin, reqTimes, err, *delay); !ok {
+		return err
 	}
-	f.Close()
-	return err
+	return retry.Sleep(dReq.ctx, *delay)
 }
 
 const defaultRange = "bytes=0-"
 const defaultSize = 30 * 1024 * 1024
 
-// TODO: 利用简单任务调度实现
-func (dReq *DownloadReq) ConcurrencyDownload(filepath string, url string, concurrencyNum int) error {
-	if dReq.mode&DModeOverwrite == 0 && fs.Exist(filepath) {
-		return nil
-	}
-	panic("TODO")
-	return nil
-}
-
 func GetReader(url string) (io.ReadCloser, error) {
 	return GetReaderWithHttpRequestOptions(url)
 }
@@ -25,8 +25,8 @@ func NewDownloader(options ...Option) *Downloader {
 	downloader := &Downloader{
 		typ:           ClientTypeDownload,
 		httpClient:    DefaultDownloadHttpClient,
-		retryTimes:    3,
 		retryInterval: time.Second,
+		retryPolicy:   newRetryPolicy(3, time.Second),
 		logger:        nil,
 		logLevel:      LogLevelSilent,
 	}
@@ -6,7 +6,23 @@
 
 package client
 
-import "testing"
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/hopeio/gox/net/http/consts"
+	"github.com/hopeio/gox/os/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
 
 func TestFetch(t *testing.T) {
 	_, err := GetReader("")
@@ -14,3 +30,127 @@ func TestFetch(t *testing.T) {
 		t.Log(err)
 	}
 }
+
+func TestConcurrencyDownload(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789abcdef"), 4096)
+	sum := sha256.Sum256(content)
+	var mu sync.Mutex
+	var ranges []string
+	var failed bool
+	ignoreRange := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		ranges = append(ranges, r.Header.Get(consts.HeaderRange))
+		// 第一个非探测的分段中途断开,验证分段重试
+		fail := !failed && r.Header.Get(consts.HeaderRange) != "bytes=0-0" && !ignoreRange
+		if fail {
+			failed = true
+		}
+		mu.Unlock()
+		if ignoreRange {
+			w.Write(content)
+			return
+		}
+		if fail {
+			w.Header().Set(consts.HeaderContentLength, "1024")
+			w.WriteHeader(http.StatusPartialContent)
+			w.Write(content[:100])
+			return
+		}
+		http.ServeContent(w, r, "data", time.Time{}, bytes.NewReader(content))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	newReq := func() *DownloadReq {
+		return NewDownloadReq(srv.URL).SetDownloader(func(d *Downloader) {
+			d.RetryTimesWithInterval(3, 10*time.Millisecond)
+		}).Checksum(sha256.New, hex.EncodeToString(sum[:]))
+	}
+	var lastWritten, lastTotal int64
+	err := newReq().Progress(func(written, total int64) {
+		lastWritten, lastTotal = written, total
+	}).ConcurrencyDownload(filepath.Join(dir, "a"), 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "a")); !bytes.Equal(data, content) {
+		t.Fatal("content mismatch")
+	}
+	if lastWritten != int64(len(content)) || lastTotal != int64(len(content)) {
+		t.Fatalf("unexpected progress: %d/%d", lastWritten, lastTotal)
+	}
+	if len(ranges) != 6 {
+		t.Fatalf("unexpected requests: %v", ranges)
+	}
+
+	// 模拟中断:第一段已下载完成,只请求剩余部分
+	half := int64(len(content) / 2)
+	target := filepath.Join(dir, "b")
+	os.WriteFile(target+DownloadKey, content[:half], 0666)
+	state := &downloadState{Url: srv.URL, Size: int64(len(content)), Parts: []*downloadPart{{Start: 0, End: half - 1, Written: half}, {Start: half, End: int64(len(content)) - 1, Written: 10}}}
+	f, _ := os.OpenFile(target+DownloadKey, os.O_RDWR, 0666)
+	f.WriteAt(content[half:half+10], half)
+	f.Close()
+	state.save(target + DownloadKey + downloadStateSuffix)
+	ranges = nil
+	if err = newReq().ConcurrencyDownload(target, 2); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(target); !bytes.Equal(data, content) {
+		t.Fatal("resumed content mismatch")
+	}
+	if len(ranges) != 2 || ranges[1] != fmt.Sprintf("bytes=%d-%d", half+10, len(content)-1) {
+		t.Fatalf("unexpected resume requests: %v", ranges)
+	}
+	if fs.Exist(target + DownloadKey + downloadStateSuffix) {
+		t.Fatal("state file not removed")
+	}
+
+	// 不支持Range时单连接下载
+	ignoreRange = true
+	if err = newReq().ConcurrencyDownload(filepath.Join(dir, "c"), 4); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "c")); !bytes.Equal(data, content) {
+		t.Fatal("single stream content mismatch")
+	}
+	err = NewDownloadReq(srv.URL).Checksum(md5.New, "00").ConcurrencyDownload(filepath.Join(dir, "d"), 4)
+	if !errors.Is(err, ErrChecksumMismatch) || fs.Exist(filepath.Join(dir, "d")) {
+		t.Fatalf("checksum should mismatch: %v", err)
+	}
+
+	// 探测后资源变化,分段请求带If-Range得到完整内容,丢弃分段改为单连接下载
+	changed := bytes.Repeat([]byte("fedcba9876543210"), 4096)
+	var ifRanges []string
+	version := "\"v1\""
+	srv2 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		data := content
+		if version != "\"v1\"" {
+			data = changed
+		}
+		w.Header().Set(consts.HeaderETag, version)
+		if r.Header.Get(consts.HeaderRange) == probeRange {
+			version = "\"v2\""
+		} else if r.Header.Get(consts.HeaderRange) != "" {
+			ifRanges = append(ifRanges, r.Header.Get(consts.HeaderIfRange))
+		}
+		mu.Unlock()
+		http.ServeContent(w, r, "data", time.Time{}, bytes.NewReader(data))
+	}))
+	defer srv2.Close()
+	target = filepath.Join(dir, "e")
+	if err = NewDownloadReq(srv2.URL).ConcurrencyDownload(target, 4); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(target); !bytes.Equal(data, changed) {
+		t.Fatal("changed content mismatch")
+	}
+	if len(ifRanges) == 0 || ifRanges[0] != "\"v1\"" {
+		t.Fatalf("unexpected If-Range: %v", ifRanges)
+	}
+	if fs.Exist(target+DownloadKey+downloadStateSuffix) || fs.Exist(target+DownloadKey) {
+		t.Fatal("state or temp file not removed")
+	}
+}
@@ -0,0 +1,248 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hopeio/gox/net/http/consts"
+	"github.com/hopeio/gox/scheduler/retry"
+	"github.com/hopeio/gox/time/ratelimit"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// RoundTrip 发送请求并返回响应
+type RoundTrip func(req *http.Request) (*http.Response, error)
+
+// Interceptor 拦截器,包装next实现鉴权、签名、熔断、限流等通用逻辑
+// 每次发送(包括重试)都会经过拦截器,拦截器需要修改请求时应先req.Clone
+type Interceptor func(next RoundTrip) RoundTrip
+
+// Use 添加Client级拦截器,先添加的在外层
+func (d *Client) Use(interceptors ...Interceptor) *Client {
+	// Clone后的Client共享底层数组,追加时复制避免互相影响
+	d.interceptors = append(slices.Clip(d.interceptors), interceptors...)
+	return d
+}
+
+// Use 添加请求级拦截器,在Client级拦截器内层
+func (req *Request) Use(interceptors ...Interceptor) *Request {
+	req.interceptors = append(req.interceptors, interceptors...)
+	return req
+}
+
+func (d *Client) roundTrip(interceptors ...Interceptor) RoundTrip {
+	rt := RoundTrip(d.httpClient.Do)
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		rt = interceptors[i](rt)
+	}
+	for i := len(d.interceptors) - 1; i >= 0; i-- {
+		rt = d.interceptors[i](rt)
+	}
+	return rt
+}
+
+func (d *Client) do(req *http.Request) (*http.Response, error) {
+	return d.roundTrip()(req)
+}
+
+// 拦截器重新发送请求时重置body
+func resetBody(req *http.Request) error {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil
+	}
+	if req.GetBody == nil {
+		return errors.New("request body can not be reset")
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return err
+	}
+	req.Body = body
+	return nil
+}
+
+// TokenSource 获取访问令牌,refresh为true时需要重新获取(如令牌已失效)
+type TokenSource func(ctx context.Context, refresh bool) (token string, expiry time.Time, err error)
+
+// AuthTokenInterceptor 在Authorization中携带Bearer令牌,过期前及响应401时刷新令牌并重试一次
+func AuthTokenInterceptor(source TokenSource) Interceptor {
+	var mu sync.Mutex
+	var token string
+	var expiry time.Time
+	get := func(ctx context.Context, refresh bool, old string) (string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		// 其他请求已经刷新过
+		if refresh && token != old {
+			return token, nil
+		}
+		if !refresh && token != "" && (expiry.IsZero() || time.Now().Before(expiry)) {
+			return token, nil
+		}
+		t, e, err := source(ctx, refresh || token != "")
+		if err != nil {
+			return "", err
+		}
+		token, expiry = t, e
+		return token, nil
+	}
+	return func(next RoundTrip) RoundTrip {
+		return func(req *http.Request) (*http.Response, error) {
+			t, err := get(req.Context(), false, "")
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Header.Set(consts.HeaderAuthorization, "Bearer "+t)
+			resp, err := next(req)
+			if err != nil || resp.StatusCode != http.StatusUnauthorized {
+				return resp, err
+			}
+			if err = resetBody(req); err != nil {
+				return resp, nil
+			}
+			resp.Body.Close()
+			if t, err = get(req.Context(), true, t); err != nil {
+				return nil, err
+			}
+			req.Header.Set(consts.HeaderAuthorization, "Bearer "+t)
+			return next(req)
+		}
+	}
+}
+
+// Signer 对请求签名,一般是设置签名相关的header
+type Signer func(req *http.Request) error
+
+// SignInterceptor 发送前对请求签名,重试时会重新签名
+func SignInterceptor(sign Signer) Interceptor {
+	return func(next RoundTrip) RoundTrip {
+		return func(req *http.Request) (*http.Response, error) {
+			req = req.Clone(req.Context())
+			if err := sign(req); err != nil {
+				return nil, err
+			}
+			return next(req)
+		}
+	}
+}
+
+const (
+	HeaderSignKey       = "X-Sign-Key"
+	HeaderSignTimestamp = "X-Sign-Timestamp"
+	HeaderSignature     = "X-Signature"
+)
+
+// HMACSigner HMAC-SHA256签名,签名内容为 method\nrequestURI\ntimestamp\nhex(sha256(body))
+func HMACSigner(key string, secret []byte) Signer {
+	return func(req *http.Request) error {
+		bodyHash := sha256.New()
+		if req.Body != nil && req.Body != http.NoBody {
+			data, err := io.ReadAll(req.Body)
+			req.Body.Close()
+			if err != nil {
+				return err
+			}
+			bodyHash.Write(data)
+			req.Body = io.NopCloser(bytes.NewReader(data))
+		}
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		mac := hmac.New(sha256.New, secret)
+		mac.Write([]byte(req.Method + "\n" + req.URL.RequestURI() + "\n" + timestamp + "\n" + hex.EncodeToString(bodyHash.Sum(nil))))
+		req.Header.Set(HeaderSignKey, key)
+		req.Header.Set(HeaderSignTimestamp, timestamp)
+		req.Header.Set(HeaderSignature, hex.EncodeToString(mac.Sum(nil)))
+		return nil
+	}
+}
+
+// RateLimitInterceptor 令牌桶限流,等待令牌时响应ctx取消
+func RateLimitInterceptor(bucket *ratelimit.Bucket) Interceptor {
+	return func(next RoundTrip) RoundTrip {
+		return func(req *http.Request) (*http.Response, error) {
+			if err := retry.Sleep(req.Context(), bucket.Take(1)); err != nil {
+				return nil, err
+			}
+			return next(req)
+		}
+	}
+}
+
+// DumpInterceptor 将请求及响应以HTTP报文格式写入w,body为false时不包含body
+func DumpInterceptor(w io.Writer, body bool) Interceptor {
+	var mu sync.Mutex
+	return func(next RoundTrip) RoundTrip {
+		return func(req *http.Request) (*http.Response, error) {
+			reqDump, err := httputil.DumpRequestOut(req, body)
+			if err != nil {
+				return nil, err
+			}
+			resp, err := next(req)
+			var respDump []byte
+			if err == nil {
+				if respDump, err = httputil.DumpResponse(resp, body); err != nil {
+					resp.Body.Close()
+					return nil, err
+				}
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			w.Write(reqDump)
+			if err != nil {
+				fmt.Fprintf(w, "\n\nerror: %v\n\n", err)
+			} else {
+				w.Write(respDump)
+				io.WriteString(w, "\n\n")
+			}
+			return resp, err
+		}
+	}
+}
+
+// MetricsInterceptor 记录请求数及耗时的prometheus指标,按method、host及状态码区分,出错时code为error
+// 已注册过时复用已有的指标,其他注册错误会panic
+func MetricsInterceptor(registerer prometheus.Registerer) Interceptor {
+	duration := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "http_client",
+		Name:      "request_duration_seconds",
+		Help:      "HTTP client request latency by method, host and status code.",
+		Buckets:   prometheus.ExponentialBuckets(0.005, 2, 15),
+	}, []string{"method", "host", "code"})
+	if err := registerer.Register(duration); err != nil {
+		var are prometheus.AlreadyRegisteredError
+		if !errors.As(err, &are) {
+			panic(err)
+		}
+		duration = are.ExistingCollector.(*prometheus.HistogramVec)
+	}
+	return func(next RoundTrip) RoundTrip {
+		return func(req *http.Request) (*http.Response, error) {
+			begin := time.Now()
+			resp, err := next(req)
+			code := "error"
+			if err == nil {
+				code = strconv.Itoa(resp.StatusCode)
+			}
+			duration.WithLabelValues(req.Method, req.URL.Host, code).Observe(time.Since(begin).Seconds())
+			return resp, err
+		}
+	}
+}
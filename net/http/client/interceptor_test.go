@@ -0,0 +1,107 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hopeio/gox/net/http/consts"
+)
+
+func TestInterceptors(t *testing.T) {
+	var unauthorized atomic.Bool
+	unauthorized.Store(true)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/auth":
+			if r.Header.Get(consts.HeaderAuthorization) == "Bearer token-1" && unauthorized.Load() {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte(`{"auth":"` + r.Header.Get(consts.HeaderAuthorization) + `","sign":"` + r.Header.Get(HeaderSignature) + `"}`))
+		case "/fail":
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+
+	var order []string
+	trace := func(name string) Interceptor {
+		return func(next RoundTrip) RoundTrip {
+			return func(req *http.Request) (*http.Response, error) {
+				order = append(order, name)
+				return next(req)
+			}
+		}
+	}
+	var refreshes int
+	source := func(ctx context.Context, refresh bool) (string, time.Time, error) {
+		refreshes++
+		if refresh {
+			return "token-2", time.Time{}, nil
+		}
+		return "token-1", time.Time{}, nil
+	}
+	var dump bytes.Buffer
+	c := New().DisableLog().Use(trace("client"), AuthTokenInterceptor(source), SignInterceptor(HMACSigner("key", []byte("secret"))), DumpInterceptor(&dump, true))
+	var resp struct {
+		Auth string `json:"auth"`
+		Sign string `json:"sign"`
+	}
+	if err := c.PostRequest(srv.URL+"/auth").Use(trace("request")).Do(map[string]string{"a": "b"}, &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Auth != "Bearer token-2" || resp.Sign == "" || refreshes != 2 {
+		t.Fatalf("unexpected response: %+v %d", resp, refreshes)
+	}
+	// 401后刷新令牌重试,内层的拦截器会再执行一次
+	if strings.Join(order, ",") != "client,request,request" {
+		t.Fatalf("unexpected order: %v", order)
+	}
+	if !strings.Contains(dump.String(), "401 Unauthorized") || !strings.Contains(dump.String(), `{"a":"b"}`) {
+		t.Fatalf("unexpected dump: %s", dump.String())
+	}
+
+	breaker := &CircuitBreaker{FailureThreshold: 2, OpenTimeout: 50 * time.Millisecond}
+	c = New().DisableLog().Use(breaker.Interceptor())
+	for range 2 {
+		if err := c.Get(srv.URL+"/fail", nil, nil); err == nil || errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if err := c.Get(srv.URL+"/fail", nil, nil); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("breaker should be open: %v", err)
+	}
+	time.Sleep(60 * time.Millisecond)
+	unauthorized.Store(false)
+	if err := c.Get(srv.URL+"/auth", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if state := breaker.State(strings.TrimPrefix(srv.URL, "http://")); state != BreakerClosed {
+		t.Fatalf("unexpected state: %s", state)
+	}
+
+	// 打开前放行的慢请求在打开后成功返回,不关闭熔断
+	breaker = &CircuitBreaker{FailureThreshold: 1, OpenTimeout: time.Minute}
+	if ok, probe := breaker.allow("slow"); !ok || probe {
+		t.Fatal("closed breaker should allow")
+	}
+	breaker.allow("slow")
+	breaker.done("slow", false, true)
+	breaker.done("slow", false, false)
+	if state := breaker.State("slow"); state != BreakerOpen {
+		t.Fatalf("late success should not close breaker: %s", state)
+	}
+}
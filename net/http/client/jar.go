@@ -0,0 +1,375 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package client
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// JarFormat cookie jar持久化的格式
+type JarFormat uint8
+
+const (
+	JarFormatJSON JarFormat = iota
+	// Netscape cookies.txt格式,可与curl、wget及浏览器插件互通
+	JarFormatNetscape
+)
+
+// Cookie jar中保存的cookie
+type Cookie struct {
+	Name     string    `json:"name"`
+	Value    string    `json:"value"`
+	Domain   string    `json:"domain"`
+	Path     string    `json:"path"`
+	Expires  time.Time `json:"expires,omitempty"`
+	Secure   bool      `json:"secure,omitempty"`
+	HttpOnly bool      `json:"httpOnly,omitempty"`
+	// 只发送给设置该cookie的host,不包括子域名
+	HostOnly bool      `json:"hostOnly,omitempty"`
+	Creation time.Time `json:"creation"`
+}
+
+func (c *Cookie) key() string {
+	return c.Domain + ";" + c.Path + ";" + c.Name
+}
+
+func (c *Cookie) expired(now time.Time) bool {
+	return !c.Expires.IsZero() && !c.Expires.After(now)
+}
+
+func (c *Cookie) match(host, path string, https bool) bool {
+	if c.Secure && !https {
+		return false
+	}
+	if c.HostOnly {
+		if host != c.Domain {
+			return false
+		}
+	} else if !domainMatch(host, c.Domain) {
+		return false
+	}
+	return pathMatch(path, c.Path)
+}
+
+// Jar 可持久化的cookie jar,实现了http.CookieJar,并发安全
+// 按RFC 6265的domain及path规则匹配,按可注册域名(eTLD+1)隔离存储,拒绝为公共后缀设置cookie
+type Jar struct {
+	mu      sync.Mutex
+	entries map[string]map[string]*Cookie
+}
+
+func NewJar() *Jar {
+	return &Jar{entries: make(map[string]map[string]*Cookie)}
+}
+
+func jarKey(host string) string {
+	if net.ParseIP(host) != nil {
+		return host
+	}
+	key, err := publicsuffix.EffectiveTLDPlusOne(host)
+	if err != nil {
+		return host
+	}
+	return key
+}
+
+func canonicalHost(u *url.URL) string {
+	return strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+}
+
+func domainMatch(host, domain string) bool {
+	if host == domain {
+		return true
+	}
+	return net.ParseIP(host) == nil && strings.HasSuffix(host, "."+domain)
+}
+
+func pathMatch(path, cookiePath string) bool {
+	if path == cookiePath {
+		return true
+	}
+	if strings.HasPrefix(path, cookiePath) {
+		return strings.HasSuffix(cookiePath, "/") || path[len(cookiePath)] == '/'
+	}
+	return false
+}
+
+func defaultPath(path string) string {
+	if path == "" || path[0] != '/' {
+		return "/"
+	}
+	i := strings.LastIndexByte(path, '/')
+	if i == 0 {
+		return "/"
+	}
+	return path[:i]
+}
+
+func (j *Jar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	host := canonicalHost(u)
+	if host == "" {
+		return
+	}
+	now := time.Now()
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for _, hc := range cookies {
+		c := &Cookie{Name: hc.Name, Value: hc.Value, Path: hc.Path, Secure: hc.Secure, HttpOnly: hc.HttpOnly, Creation: now}
+		if c.Path == "" || c.Path[0] != '/' {
+			c.Path = defaultPath(u.Path)
+		}
+		domain := strings.ToLower(strings.TrimPrefix(hc.Domain, "."))
+		if domain == "" || domain == host {
+			c.Domain = host
+			c.HostOnly = domain == ""
+		} else {
+			if !domainMatch(host, domain) {
+				continue
+			}
+			if suffix, _ := publicsuffix.PublicSuffix(domain); suffix == domain {
+				continue
+			}
+			c.Domain = domain
+		}
+		switch {
+		case hc.MaxAge < 0:
+			c.Expires = now
+		case hc.MaxAge > 0:
+			c.Expires = now.Add(time.Duration(hc.MaxAge) * time.Second)
+		case !hc.Expires.IsZero():
+			c.Expires = hc.Expires
+		}
+		j.set(c, now)
+	}
+}
+
+func (j *Jar) set(c *Cookie, now time.Time) {
+	key := jarKey(c.Domain)
+	entries := j.entries[key]
+	if c.expired(now) {
+		delete(entries, c.key())
+		return
+	}
+	if entries == nil {
+		entries = make(map[string]*Cookie)
+		j.entries[key] = entries
+	}
+	// 更新时保留创建时间,影响发送的顺序
+	if old, ok := entries[c.key()]; ok {
+		c.Creation = old.Creation
+	}
+	entries[c.key()] = c
+}
+
+func (j *Jar) Cookies(u *url.URL) []*http.Cookie {
+	host := canonicalHost(u)
+	if host == "" {
+		return nil
+	}
+	path := u.Path
+	if path == "" {
+		path = "/"
+	}
+	https := u.Scheme == "https" || u.Scheme == "wss"
+	now := time.Now()
+	j.mu.Lock()
+	entries := j.entries[jarKey(host)]
+	var matched []*Cookie
+	for k, c := range entries {
+		if c.expired(now) {
+			delete(entries, k)
+			continue
+		}
+		if c.match(host, path, https) {
+			matched = append(matched, c)
+		}
+	}
+	j.mu.Unlock()
+	// 路径长的在前,相同时先创建的在前
+	sort.Slice(matched, func(a, b int) bool {
+		if len(matched[a].Path) != len(matched[b].Path) {
+			return len(matched[a].Path) > len(matched[b].Path)
+		}
+		return matched[a].Creation.Before(matched[b].Creation)
+	})
+	cookies := make([]*http.Cookie, len(matched))
+	for i, c := range matched {
+		cookies[i] = &http.Cookie{Name: c.Name, Value: c.Value}
+	}
+	return cookies
+}
+
+// All 所有未过期的cookie
+func (j *Jar) All() []*Cookie {
+	now := time.Now()
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	var cookies []*Cookie
+	for _, entries := range j.entries {
+		for _, c := range entries {
+			if !c.expired(now) {
+				cc := *c
+				cookies = append(cookies, &cc)
+			}
+		}
+	}
+	sort.Slice(cookies, func(a, b int) bool {
+		return cookies[a].key() < cookies[b].key()
+	})
+	return cookies
+}
+
+// Add 直接添加cookie,用于导入
+func (j *Jar) Add(cookies ...*Cookie) {
+	now := time.Now()
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for _, c := range cookies {
+		cc := *c
+		cc.Domain = strings.ToLower(strings.TrimPrefix(cc.Domain, "."))
+		if cc.Path == "" {
+			cc.Path = "/"
+		}
+		if cc.Creation.IsZero() {
+			cc.Creation = now
+		}
+		j.set(&cc, now)
+	}
+}
+
+// Clear 清除domain(eTLD+1)下的cookie,domain为空时清除全部
+func (j *Jar) Clear(domain string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if domain == "" {
+		j.entries = make(map[string]map[string]*Cookie)
+		return
+	}
+	delete(j.entries, jarKey(strings.ToLower(domain)))
+}
+
+func (j *Jar) Encode(w io.Writer, format JarFormat) error {
+	cookies := j.All()
+	if format == JarFormatJSON {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(cookies)
+	}
+	bw := bufio.NewWriter(w)
+	bw.WriteString("# Netscape HTTP Cookie File\n")
+	for _, c := range cookies {
+		domain := c.Domain
+		if !c.HostOnly {
+			domain = "." + domain
+		}
+		if c.HttpOnly {
+			domain = "#HttpOnly_" + domain
+		}
+		var expires int64
+		if !c.Expires.IsZero() {
+			expires = c.Expires.Unix()
+		}
+		fmt.Fprintf(bw, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n", domain, netscapeBool(!c.HostOnly), c.Path, netscapeBool(c.Secure), expires, c.Name, c.Value)
+	}
+	return bw.Flush()
+}
+
+func netscapeBool(b bool) string {
+	if b {
+		return "TRUE"
+	}
+	return "FALSE"
+}
+
+func (j *Jar) Decode(r io.Reader, format JarFormat) error {
+	if format == JarFormatJSON {
+		var cookies []*Cookie
+		if err := json.NewDecoder(r).Decode(&cookies); err != nil {
+			return err
+		}
+		j.Add(cookies...)
+		return nil
+	}
+	scanner := bufio.NewScanner(r)
+	var cookies []*Cookie
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		httpOnly := strings.HasPrefix(text, "#HttpOnly_")
+		if httpOnly {
+			text = text[len("#HttpOnly_"):]
+		} else if text == "" || text[0] == '#' {
+			continue
+		}
+		fields := strings.Split(text, "\t")
+		if len(fields) != 7 {
+			return fmt.Errorf("netscape cookie line %d: expected 7 fields, got %d", line, len(fields))
+		}
+		expires, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			return fmt.Errorf("netscape cookie line %d: %w", line, err)
+		}
+		c := &Cookie{Domain: fields[0], HostOnly: fields[1] != "TRUE", Path: fields[2], Secure: fields[3] == "TRUE", Name: fields[5], Value: fields[6], HttpOnly: httpOnly}
+		if expires > 0 {
+			c.Expires = time.Unix(expires, 0)
+		}
+		cookies = append(cookies, c)
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	j.Add(cookies...)
+	return nil
+}
+
+// Save 保存到文件
+func (j *Jar) Save(path string, format JarFormat) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + DownloadKey
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	err = j.Encode(f, format)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Load 从文件加载,文件不存在时不报错
+func (j *Jar) Load(path string, format JarFormat) error {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+	return j.Decode(f, format)
+}
@@ -18,6 +18,12 @@ import (
 
 var reqClient = client.DefaultHeaderClient().RetryTimes(20).DisableLog()
 
+// SetClient 替换请求m3u8及ts使用的Client,需要登录时可传入client.Session的Client
+// 非并发安全,只能在开始下载前(如init中)调用
+func SetClient(c *client.Client) {
+	reqClient = c
+}
+
 type Result struct {
 	URL  *url.URL
 	M3u8 *em3u8.M3u8
@@ -18,6 +18,7 @@ import (
 	httpi "github.com/hopeio/gox/net/http"
 	"github.com/hopeio/gox/net/http/consts"
 	url2 "github.com/hopeio/gox/net/url"
+	"github.com/hopeio/gox/scheduler/retry"
 	stringsi "github.com/hopeio/gox/strings"
 	"github.com/hopeio/gox/strings/unicode"
 	"github.com/klauspost/compress/zstd"
@@ -39,6 +40,8 @@ type Request struct {
 	contentType ContentType
 	header      http.Header //请求级请求头
 	client      *Client
+
+	interceptors []Interceptor
 }
 
 func NewRequest(method, url string, opts ...RequestOption) *Request {
@@ -138,6 +141,7 @@ func (req *Request) Do(param, response any) error {
 	var reqTimes int
 	var err error
 	reqTime := time.Now()
+	reqBegin := reqTime
 	var request *http.Request
 	var resp *http.Response
 	// 日志记录
@@ -211,10 +215,12 @@ func (req *Request) Do(param, response any) error {
 	request.Header.Set(consts.HeaderContentType, req.contentType.String())
 	httpi.CopyHttpHeader(request.Header, c.header)
 
+	var retryDelay time.Duration
+	roundTrip := c.roundTrip(req.interceptors...)
 Retry:
 	if reqTimes > 0 {
-		if c.retryInterval != 0 {
-			time.Sleep(c.retryInterval)
+		if err = retry.Sleep(req.ctx, retryDelay); err != nil {
+			return err
 		}
 		reqTime = time.Now()
 		if reqBody != nil {
@@ -224,10 +230,11 @@ Retry:
 			c.retryHandler(request)
 		}
 	}
-	resp, err = c.httpClient.Do(request)
+	resp, err = roundTrip(request)
 	reqTimes++
 	if err != nil {
-		if c.retryTimes == 0 || reqTimes == c.retryTimes {
+		var ok bool
+		if retryDelay, ok = c.nextRetry(reqBegin, reqTimes, err, retryDelay); !ok {
 			return err
 		} else {
 			if c.logLevel > LogLevelSilent {
@@ -315,9 +322,21 @@ Retry:
 	}
 
 	if c.responseHandler != nil {
-		var retry bool
-		retry, reader, err = c.responseHandler(resp)
-		if retry {
+		var needRetry bool
+		needRetry, reader, err = c.responseHandler(resp)
+		if needRetry {
+			retryErr := err
+			if retryErr == nil {
+				retryErr = errors.New("response handler require retry")
+			}
+			policy := c.retryPolicy
+			if policy == nil {
+				policy = responseRetryPolicy
+			}
+			var ok bool
+			if retryDelay, ok = nextRetry(policy, reqBegin, reqTimes, retryErr, retryDelay); !ok {
+				return retryErr
+			}
 			if c.logLevel > LogLevelSilent {
 				c.logger(&AccessLogParam{
 					Method:   req.Method,
@@ -0,0 +1,95 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package client
+
+import (
+	"net/http"
+)
+
+// CookieJar 设置cookie jar,使用共享的默认http.Client时会新建http.Client,不影响默认的http.Client
+func (d *Client) CookieJar(jar http.CookieJar) *Client {
+	if !d.newHttpClient {
+		d.httpClient = newHttpClient(d.typ)
+		d.newHttpClient = true
+	}
+	d.httpClient.Jar = jar
+	return d
+}
+
+// Session 共享同一个cookie jar的Client及Downloader,用于登录后的请求及下载
+// 设置了持久化文件时,创建时加载,登录及Save时保存
+type Session struct {
+	jar        *Jar
+	client     *Client
+	downloader *Downloader
+	path       string
+	format     JarFormat
+}
+
+// NewSession jar为nil时新建
+func NewSession(jar *Jar) *Session {
+	if jar == nil {
+		jar = NewJar()
+	}
+	return &Session{
+		jar:        jar,
+		client:     New().CookieJar(jar),
+		downloader: NewDownloader().CookieJar(jar),
+	}
+}
+
+// NewPersistentSession 从文件加载cookie,登录及Save时写回
+func NewPersistentSession(path string, format JarFormat) (*Session, error) {
+	jar := NewJar()
+	if err := jar.Load(path, format); err != nil {
+		return nil, err
+	}
+	s := NewSession(jar)
+	s.path = path
+	s.format = format
+	return s, nil
+}
+
+func (s *Session) Jar() *Jar {
+	return s.jar
+}
+
+// Client 使用该会话cookie的Client,可继续设置其他选项
+func (s *Session) Client() *Client {
+	return s.client
+}
+
+// Downloader 使用该会话cookie的Downloader
+func (s *Session) Downloader() *Downloader {
+	return s.downloader
+}
+
+// Request 使用会话Client的请求
+func (s *Session) Request(method, url string) *Request {
+	return s.client.Request(method, url)
+}
+
+// DownloadReq 使用会话Downloader的下载请求
+func (s *Session) DownloadReq(url string) *DownloadReq {
+	return s.downloader.DownloadReq(url)
+}
+
+// Login 用会话Client执行登录请求,成功后保存cookie
+func (s *Session) Login(req *Request, param, response any) error {
+	if err := req.Client(s.client).Do(param, response); err != nil {
+		return err
+	}
+	return s.Save()
+}
+
+// Save 保存cookie到持久化文件,未设置文件时不做任何事
+func (s *Session) Save() error {
+	if s.path == "" {
+		return nil
+	}
+	return s.jar.Save(s.path, s.format)
+}
@@ -0,0 +1,117 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package client
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestJar(t *testing.T) {
+	jar := NewJar()
+	u, _ := url.Parse("https://www.example.com/a/b")
+	jar.SetCookies(u, []*http.Cookie{
+		{Name: "host", Value: "1"},
+		{Name: "domain", Value: "2", Domain: ".example.com", Path: "/", Secure: true},
+		{Name: "suffix", Value: "3", Domain: "com"},
+		{Name: "other", Value: "4", Domain: "other.com"},
+	})
+	cookies := func(raw string) string {
+		u, _ := url.Parse(raw)
+		var names []string
+		for _, c := range jar.Cookies(u) {
+			names = append(names, c.Name)
+		}
+		return strings.Join(names, ",")
+	}
+	for raw, expected := range map[string]string{
+		"https://www.example.com/a/c": "host,domain",
+		"https://api.example.com/":    "domain",
+		"http://api.example.com/":     "",
+		"https://www.example.com/":    "domain",
+		"https://other.com/":          "",
+	} {
+		if got := cookies(raw); got != expected {
+			t.Errorf("%s: expected %q, got %q", raw, expected, got)
+		}
+	}
+
+	dir := t.TempDir()
+	for _, format := range []JarFormat{JarFormatJSON, JarFormatNetscape} {
+		path := filepath.Join(dir, "cookies")
+		if err := jar.Save(path, format); err != nil {
+			t.Fatal(err)
+		}
+		loaded := NewJar()
+		if err := loaded.Load(path, format); err != nil {
+			t.Fatal(err)
+		}
+		if len(loaded.All()) != 2 || len(loaded.Cookies(u)) != 2 || !loaded.All()[1].HostOnly {
+			t.Fatalf("unexpected loaded cookies: %+v", loaded.All())
+		}
+	}
+}
+
+func TestSession(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc", Path: "/"})
+		case "/me", "/file":
+			if c, err := r.Cookie("session"); err != nil || c.Value != "abc" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte("ok"))
+		}
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "cookies.txt")
+	session, err := NewPersistentSession(path, JarFormatNetscape)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = session.Login(NewRequest(http.MethodPost, srv.URL+"/login"), nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if raw, err := session.Request(http.MethodGet, srv.URL+"/me").DoRaw(nil); err != nil || string(raw) != "ok" {
+		t.Fatalf("unexpected response: %s %v", raw, err)
+	}
+
+	// 重新加载的会话复用登录的cookie
+	session, err = NewPersistentSession(path, JarFormatNetscape)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(t.TempDir(), "file")
+	if err = session.DownloadReq(srv.URL + "/file").Download(file); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(file); string(data) != "ok" {
+		t.Fatalf("unexpected file: %q", data)
+	}
+	// 不同host的cookie隔离
+	other := strings.Replace(srv.URL, "127.0.0.1", "localhost", 1)
+	if err = session.Request(http.MethodGet, other+"/me").DoEmpty(); err == nil {
+		t.Fatal("cookie should not be sent to other host")
+	}
+	// 默认Client不受影响
+	if err = New().DisableLog().Get(srv.URL+"/me", nil, nil); err == nil {
+		t.Fatal("default client should not share session cookies")
+	}
+	// 会话Client设置代理不修改默认Transport
+	session.Client().Proxy("http://127.0.0.1:1")
+	if session.Client().httpClient.Transport == DefaultHttpClient.Transport {
+		t.Fatal("session client should not share default transport")
+	}
+}
@@ -0,0 +1,267 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hopeio/gox/net/http/consts"
+	"github.com/hopeio/gox/scheduler/retry"
+)
+
+// Event Server-Sent Event
+type Event struct {
+	// 最近一次收到的id,重连时作为Last-Event-ID
+	ID string
+	// 默认message
+	Event string
+	Data  string
+	// 服务端指定的重连间隔
+	Retry time.Duration
+}
+
+// SSEDecoder 按WHATWG规范解析text/event-stream
+type SSEDecoder struct {
+	r           *bufio.Reader
+	lastEventID string
+	retry       time.Duration
+}
+
+func NewSSEDecoder(r io.Reader) *SSEDecoder {
+	return &SSEDecoder{r: bufio.NewReader(r)}
+}
+
+// Next 返回下一个事件,流结束时返回io.EOF,结束时未以空行结尾的事件丢弃
+func (d *SSEDecoder) Next() (*Event, error) {
+	var event string
+	var data strings.Builder
+	var hasData bool
+	for {
+		line, err := d.r.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			return nil, err
+		}
+		line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+		if line == "" {
+			if err == io.EOF {
+				return nil, io.EOF
+			}
+			if !hasData {
+				event = ""
+				continue
+			}
+			if event == "" {
+				event = "message"
+			}
+			return &Event{ID: d.lastEventID, Event: event, Data: strings.TrimSuffix(data.String(), "\n"), Retry: d.retry}, nil
+		}
+		if line[0] == ':' {
+			continue
+		}
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "event":
+			event = value
+		case "data":
+			data.WriteString(value)
+			data.WriteByte('\n')
+			hasData = true
+		case "id":
+			if !strings.ContainsRune(value, 0) {
+				d.lastEventID = value
+			}
+		case "retry":
+			if ms, err := strconv.ParseUint(value, 10, 64); err == nil {
+				d.retry = time.Duration(ms) * time.Millisecond
+			}
+		}
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+	}
+}
+
+// stream 发送请求并返回未读取的响应
+func (req *Request) stream(param any, accept string) (*http.Response, error) {
+	if req.header == nil {
+		req.header = make(http.Header)
+	}
+	if req.header.Get(consts.HeaderAccept) == "" {
+		req.header.Set(consts.HeaderAccept, accept)
+	}
+	var resp *http.Response
+	if err := req.Do(param, &resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// 默认的SSE重连间隔
+const defaultSSERetry = 3 * time.Second
+
+// DoSSE 逐个返回Server-Sent Events,ctx取消或停止迭代时关闭连接
+// 连接断开后按Client的重试设置(RetryTimes/RetryPolicy)带Last-Event-ID重连,间隔优先使用服务端的retry,未设置重试时不重连
+// 服务端返回204时结束
+func (req *Request) DoSSE(param any) iter.Seq2[*Event, error] {
+	return func(yield func(*Event, error) bool) {
+		var lastEventID string
+		delay := defaultSSERetry
+		var serverRetry bool
+		begin := time.Now()
+		var reconnects int
+		for {
+			reconnects++
+			if lastEventID != "" {
+				if req.header == nil {
+					req.header = make(http.Header)
+				}
+				req.header.Set(consts.HeaderLastEventID, lastEventID)
+			}
+			resp, err := req.stream(param, consts.ContentTypeEventStream)
+			// GET请求参数已拼接到Url,重连时不再重复拼接
+			if req.Method == http.MethodGet {
+				param = nil
+			}
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			if resp.StatusCode == http.StatusNoContent {
+				resp.Body.Close()
+				return
+			}
+			decoder := NewSSEDecoder(resp.Body)
+			for {
+				var event *Event
+				event, err = decoder.Next()
+				if err != nil {
+					break
+				}
+				lastEventID = event.ID
+				// 收到事件说明连接已恢复,重试次数和时间从下次断开重新计算
+				reconnects = 1
+				begin = time.Now()
+				if event.Retry > 0 {
+					delay = event.Retry
+					serverRetry = true
+				}
+				if !yield(event, nil) {
+					resp.Body.Close()
+					return
+				}
+			}
+			resp.Body.Close()
+			if req.ctx.Err() != nil {
+				yield(nil, req.ctx.Err())
+				return
+			}
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			c := req.client
+			next, ok := c.nextRetry(begin, reconnects, err, delay)
+			if !ok {
+				// 未设置重试时连接断开视为正常结束
+				if c.retryPolicy != nil || !errors.Is(err, io.ErrUnexpectedEOF) {
+					yield(nil, err)
+				}
+				return
+			}
+			// 服务端指定了retry时优先使用
+			if !serverRetry {
+				delay = next
+			}
+			if err = retry.Sleep(req.ctx, delay); err != nil {
+				yield(nil, err)
+				return
+			}
+		}
+	}
+}
+
+// DoNDJSON 逐行解码换行分隔的JSON(NDJSON/JSON Lines),空行跳过
+func DoNDJSON[T any](req *Request, param any) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+		resp, err := req.stream(param, consts.ContentTypeNDJson)
+		if err != nil {
+			yield(zero, err)
+			return
+		}
+		defer resp.Body.Close()
+		r := bufio.NewReader(resp.Body)
+		for {
+			line, err := r.ReadBytes('\n')
+			if len(bytes.TrimSpace(line)) > 0 {
+				var v T
+				if err := json.Unmarshal(line, &v); err != nil {
+					yield(zero, err)
+					return
+				}
+				if !yield(v, nil) {
+					return
+				}
+			}
+			if err != nil {
+				if err != io.EOF {
+					yield(zero, err)
+				}
+				return
+			}
+		}
+	}
+}
+
+// DoJSONArray 增量解码顶层为JSON数组的响应,不需要将整个响应读入内存
+func DoJSONArray[T any](req *Request, param any) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+		resp, err := req.stream(param, consts.ContentTypeJson)
+		if err != nil {
+			yield(zero, err)
+			return
+		}
+		defer resp.Body.Close()
+		if err = DecodeJSONArray(resp.Body, yield); err != nil {
+			yield(zero, err)
+		}
+	}
+}
+
+// DecodeJSONArray 逐个解码JSON数组的元素,yield返回false时停止
+func DecodeJSONArray[T any](r io.Reader, yield func(T, error) bool) error {
+	decoder := json.NewDecoder(r)
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '[' {
+		return fmt.Errorf("expected json array, got %v", token)
+	}
+	for decoder.More() {
+		var v T
+		if err = decoder.Decode(&v); err != nil {
+			return err
+		}
+		if !yield(v, nil) {
+			return nil
+		}
+	}
+	_, err = decoder.Token()
+	return err
+}
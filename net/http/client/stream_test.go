@@ -0,0 +1,134 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/hopeio/gox/net/http/consts"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDoSSE(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Header.Get(consts.HeaderLastEventID) {
+		case "":
+			w.Header().Set(consts.HeaderContentType, consts.ContentTypeEventStream)
+			fmt.Fprint(w, "retry: 10\n: comment\n\nid: 1\ndata: hello\ndata: world\n\nevent: ping\nid: 2\ndata: {}\n\n")
+		case "2":
+			fmt.Fprint(w, "id: 3\ndata: again\n\n")
+		case "3":
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer srv.Close()
+
+	var events []*Event
+	for event, err := range New().DisableLog().RetryTimes(5).Request(http.MethodGet, srv.URL).DoSSE(nil) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, event)
+	}
+	if len(events) != 3 || events[0].Data != "hello\nworld" || events[0].Event != "message" || events[1].Event != "ping" || events[2].ID != "3" || events[0].Retry != 10*time.Millisecond {
+		t.Fatalf("unexpected events: %+v", events)
+	}
+
+	// ctx取消时结束
+	block := make(chan struct{})
+	srv2 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "data: first\n\n")
+		w.(http.Flusher).Flush()
+		<-block
+	}))
+	defer srv2.Close()
+	defer close(block)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var count int
+	var lastErr error
+	for event, err := range New().DisableLog().Request(http.MethodGet, srv2.URL).Context(ctx).DoSSE(nil) {
+		if err != nil {
+			lastErr = err
+			break
+		}
+		count++
+		if event.Data == "first" {
+			cancel()
+		}
+	}
+	if count != 1 || !errors.Is(lastErr, context.Canceled) {
+		t.Fatalf("unexpected result: %d %v", count, lastErr)
+	}
+}
+
+func TestDoSSEReconnect(t *testing.T) {
+	var connects int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if q := r.URL.Query()["a"]; len(q) != 1 {
+			t.Errorf("unexpected query: %s", r.URL.RawQuery)
+		}
+		connects++
+		if connects > 3 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		// 每次连接收到一个事件后断开
+		fmt.Fprintf(w, "retry: 10\nid: %d\ndata: %d\n\n", connects, connects)
+	}))
+	defer srv.Close()
+
+	var events []*Event
+	for event, err := range New().DisableLog().RetryTimes(2).Request(http.MethodGet, srv.URL).DoSSE(map[string]string{"a": "1"}) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, event)
+	}
+	if len(events) != 3 || events[2].ID != "3" {
+		t.Fatalf("unexpected events: %+v", events)
+	}
+}
+
+func TestDoNDJSON(t *testing.T) {
+	type item struct {
+		N int `json:"n"`
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/array" {
+			fmt.Fprint(w, `[{"n":1}, {"n":2}, {"n":3}]`)
+			return
+		}
+		fmt.Fprint(w, "{\"n\":1}\n\n{\"n\":2}\n{\"n\":3}")
+	}))
+	defer srv.Close()
+
+	c := New().DisableLog()
+	var sum int
+	for v, err := range DoNDJSON[item](c.Request(http.MethodGet, srv.URL), nil) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		sum += v.N
+	}
+	for v, err := range DoJSONArray[item](c.Request(http.MethodGet, srv.URL+"/array"), nil) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		sum += v.N
+		if v.N == 2 {
+			break
+		}
+	}
+	if sum != 9 {
+		t.Fatalf("unexpected sum: %d", sum)
+	}
+}
@@ -113,6 +113,11 @@ func (r *UploadReq) Uploader(u *Uploader) *UploadReq {
 	return r
 }
 
+func (r *UploadReq) Context(ctx context.Context) *UploadReq {
+	r.ctx = ctx
+	return r
+}
+
 func (r *UploadReq) Boundary(boundary string) *UploadReq {
 	r.boundary = boundary
 	return r
@@ -205,7 +210,7 @@ func (r *UploadReq) UploadMultipart(formData map[string]string, files ...*Multip
 	for _, opt := range d.httpRequestOptions {
 		opt(req)
 	}
-	_, err = d.httpClient.Do(req)
+	_, err = d.do(req)
 	if err != nil {
 		return err
 	}
@@ -272,7 +277,7 @@ func (r *UploadReq) UploadMultipartChunked(formData map[string]string, file Mult
 			}
 			req.Body = io.NopCloser(bytes.NewReader(buf[0:size]))
 			req.Header.Set(consts.HeaderContentRange, httpi.FormatContentRange(start, end, total))
-			resp, err := u.httpClient.Do(req)
+			resp, err := u.do(req)
 			if err != nil {
 				return err
 			}
@@ -310,7 +315,7 @@ func (r *UploadReq) UploadStream(oReader io.Reader) error {
 	req.Body = reader
 
 	// 发送请求
-	resp, err := u.httpClient.Do(req)
+	resp, err := u.do(req)
 	if err != nil {
 		return err
 	}
@@ -339,7 +344,7 @@ func (r *UploadReq) UploadRaw(reader io.Reader, name string) error {
 	req.Header.Set(consts.HeaderContentType, consts.ContentTypeOctetStream)
 	req.Header.Set(consts.HeaderContentDisposition, fmt.Sprintf(consts.FormDataFileTmpl,
 		name, name))
-	resp, err := u.httpClient.Do(req)
+	resp, err := u.do(req)
 	if err != nil {
 		return err
 	}
@@ -374,7 +379,7 @@ func (r *UploadReq) UploadRawChunked(reader io.Reader, name string) error {
 			}
 			req.Body = io.NopCloser(bytes.NewReader(buf[0:nr]))
 			req.Header.Set(consts.HeaderContentRange, httpi.FormatContentRange(start, end, total))
-			resp, err := u.httpClient.Do(req)
+			resp, err := u.do(req)
 			if err != nil {
 				return err
 			}
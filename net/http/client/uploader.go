@@ -9,14 +9,23 @@ package client
 import (
 	"bytes"
 	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
 	"fmt"
 	"io"
 	"mime/multipart"
 	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
+
+	httpi "github.com/hopeio/gox/net/http"
+	"github.com/hopeio/gox/net/http/consts"
+	"github.com/hopeio/gox/scheduler/retry"
 )
 
 var DefaultUploader = NewUploader()
@@ -27,8 +36,8 @@ func NewUploader() *Uploader {
 	return &Uploader{
 		typ:           ClientTypeUpload,
 		httpClient:    DefaultDownloadHttpClient,
-		retryTimes:    3,
 		retryInterval: time.Second,
+		retryPolicy:   newRetryPolicy(3, time.Second),
 		logger:        nil,
 		logLevel:      LogLevelSilent,
 	}
@@ -181,3 +190,220 @@ func uploadStream(url string, filePath string) error {
 	fmt.Println("File uploaded successfully.")
 	return nil
 }
+
+// tus上传状态文件的后缀,保存上传地址
+const tusStateSuffix = ".tus"
+
+var (
+	ErrTusOffsetMismatch = errors.New("tus upload offset mismatch")
+	// ErrTusUploadGone 上传地址不存在,服务端已过期清理或被终止
+	ErrTusUploadGone = errors.New("tus upload not found")
+)
+
+func (r *UploadReq) tusRequest(method, url string, body io.Reader, setHeader func(http.Header)) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(r.ctx, method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	u := r.uploader
+	httpi.CopyHttpHeader(req.Header, u.header)
+	httpi.CopyHttpHeader(req.Header, r.header)
+	for _, opt := range u.httpRequestOptions {
+		opt(req)
+	}
+	req.Header.Set(consts.HeaderTusResumable, consts.TusVersion)
+	if setHeader != nil {
+		setHeader(req.Header)
+	}
+	return u.do(req)
+}
+
+func tusStatusError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone {
+		return fmt.Errorf("%w: %s %s", ErrTusUploadGone, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return fmt.Errorf("tus upload: %s %s", resp.Status, strings.TrimSpace(string(body)))
+}
+
+// TusCreate 以r.Url为创建地址创建tus上传,返回上传地址
+func (r *UploadReq) TusCreate(size int64, metadata map[string]string) (string, error) {
+	resp, err := r.tusRequest(http.MethodPost, r.Url, nil, func(h http.Header) {
+		h.Set(consts.HeaderUploadLength, strconv.FormatInt(size, 10))
+		if len(metadata) > 0 {
+			h.Set(consts.HeaderUploadMetadata, tusMetadata(metadata))
+		}
+	})
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusCreated {
+		return "", tusStatusError(resp)
+	}
+	resp.Body.Close()
+	location, err := resp.Request.URL.Parse(resp.Header.Get(consts.HeaderLocation))
+	if err != nil {
+		return "", err
+	}
+	return location.String(), nil
+}
+
+// TusOffset 查询服务端已接收的偏移
+func (r *UploadReq) TusOffset(location string) (int64, error) {
+	resp, err := r.tusRequest(http.MethodHead, location, nil, nil)
+	if err != nil {
+		return 0, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return 0, tusStatusError(resp)
+	}
+	resp.Body.Close()
+	return strconv.ParseInt(resp.Header.Get(consts.HeaderUploadOffset), 10, 64)
+}
+
+// TusResume 从服务端记录的偏移继续上传,每块带sha1校验,失败时按Uploader的重试设置重新查询偏移后继续
+// 上传地址不存在时不重试,返回ErrTusUploadGone
+func (r *UploadReq) TusResume(location string, reader io.ReaderAt, size int64) error {
+	chunk := r.chunkSize
+	if chunk == 0 {
+		chunk = chunkSize
+	}
+	buf := make([]byte, chunk)
+	u := r.uploader
+	begin := time.Now()
+	var attempts int
+	var delay time.Duration
+	offset := int64(-1)
+	for {
+		var err error
+		if offset < 0 {
+			offset, err = r.TusOffset(location)
+		}
+		if err == nil {
+			if offset >= size {
+				return nil
+			}
+			offset, err = r.tusPatch(location, reader, offset, size, buf)
+		}
+		if err == nil {
+			attempts = 0
+			continue
+		}
+		if errors.Is(err, ErrTusUploadGone) {
+			return err
+		}
+		attempts++
+		var ok bool
+		if delay, ok = u.nextRetry(begin, attempts, err, delay); !ok {
+			return err
+		}
+		if err = retry.Sleep(r.ctx, delay); err != nil {
+			return err
+		}
+		offset = -1
+	}
+}
+
+func (r *UploadReq) tusPatch(location string, reader io.ReaderAt, offset, size int64, buf []byte) (int64, error) {
+	n, err := reader.ReadAt(buf[:min(int64(len(buf)), size-offset)], offset)
+	if err != nil && !(err == io.EOF && int64(n) == size-offset) {
+		return offset, err
+	}
+	sum := sha1.Sum(buf[:n])
+	resp, err := r.tusRequest(http.MethodPatch, location, bytes.NewReader(buf[:n]), func(h http.Header) {
+		h.Set(consts.HeaderContentType, consts.ContentTypeOffsetOctetStream)
+		h.Set(consts.HeaderUploadOffset, strconv.FormatInt(offset, 10))
+		h.Set(consts.HeaderUploadChecksum, "sha1 "+base64.StdEncoding.EncodeToString(sum[:]))
+	})
+	if err != nil {
+		return offset, err
+	}
+	if resp.StatusCode != http.StatusNoContent {
+		return offset, tusStatusError(resp)
+	}
+	resp.Body.Close()
+	next, err := strconv.ParseInt(resp.Header.Get(consts.HeaderUploadOffset), 10, 64)
+	if err != nil {
+		return offset, err
+	}
+	if next != offset+int64(n) {
+		return next, ErrTusOffsetMismatch
+	}
+	return next, nil
+}
+
+// TusUpload 创建并上传,返回上传地址
+func (r *UploadReq) TusUpload(reader io.ReaderAt, size int64, metadata map[string]string) (string, error) {
+	location, err := r.TusCreate(size, metadata)
+	if err != nil {
+		return "", err
+	}
+	return location, r.TusResume(location, reader, size)
+}
+
+// TusUploadFile 上传文件,上传地址保存在path+DownloadKey+".tus",中断后再次调用时继续上传,完成后删除
+// 保存的上传地址在服务端已不存在时删除状态文件并重新创建上传,metadata默认带filename
+func (r *UploadReq) TusUploadFile(path string, metadata map[string]string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	stat, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	statePath := path + DownloadKey + tusStateSuffix
+	var location string
+	if data, err := os.ReadFile(statePath); err == nil {
+		location = strings.TrimSpace(string(data))
+	}
+	if location != "" {
+		err = r.TusResume(location, f, stat.Size())
+		if err == nil {
+			os.Remove(statePath)
+			return location, nil
+		}
+		if !errors.Is(err, ErrTusUploadGone) {
+			return location, err
+		}
+		os.Remove(statePath)
+	}
+	if metadata == nil {
+		metadata = map[string]string{"filename": filepath.Base(path)}
+	}
+	if location, err = r.TusCreate(stat.Size(), metadata); err != nil {
+		return "", err
+	}
+	if err = os.WriteFile(statePath, []byte(location), 0666); err != nil {
+		return "", err
+	}
+	if err = r.TusResume(location, f, stat.Size()); err != nil {
+		return location, err
+	}
+	os.Remove(statePath)
+	return location, nil
+}
+
+// TusTerminate 终止上传并删除服务端数据
+func (r *UploadReq) TusTerminate(location string) error {
+	resp, err := r.tusRequest(http.MethodDelete, location, nil, nil)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusNoContent {
+		return tusStatusError(resp)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func tusMetadata(metadata map[string]string) string {
+	pairs := make([]string, 0, len(metadata))
+	for k, v := range metadata {
+		pairs = append(pairs, k+" "+base64.StdEncoding.EncodeToString([]byte(v)))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
@@ -0,0 +1,95 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hopeio/gox/net/http/fs/upload"
+)
+
+func TestTusUploadFile(t *testing.T) {
+	store, err := upload.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tus := upload.NewTus(upload.TusConfig{BasePath: "/files", Store: store})
+	var patches, failAt atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPatch && patches.Add(1) == failAt.Load() {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		tus.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	content := bytes.Repeat([]byte("0123456789"), 200)
+	path := filepath.Join(t.TempDir(), "a.txt")
+	os.WriteFile(path, content, 0666)
+
+	// 不重试时第二块失败,上传地址保留在状态文件中
+	failAt.Store(2)
+	location, err := NewUploader().RetryTimes(0).UploadReq(srv.URL+"/files").ChunkSize(512).TusUploadFile(path, nil)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if data, _ := os.ReadFile(path + DownloadKey + tusStateSuffix); string(data) != location {
+		t.Fatalf("unexpected state: %s", data)
+	}
+
+	// 再次调用从服务端偏移继续,失败的块重新查询偏移后重试
+	patches.Store(0)
+	failAt.Store(2)
+	uploader := NewUploader().RetryTimesWithInterval(3, 10*time.Millisecond)
+	resumed, err := uploader.UploadReq(srv.URL+"/files").ChunkSize(512).TusUploadFile(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resumed != location {
+		t.Fatal(resumed, location)
+	}
+	// 首次上传了1块,剩余3块及1次失败
+	if patches.Load() != 4 {
+		t.Fatalf("unexpected patches: %d", patches.Load())
+	}
+	if _, err = os.Stat(path + DownloadKey + tusStateSuffix); !os.IsNotExist(err) {
+		t.Fatal("state file not removed")
+	}
+	id := location[strings.LastIndex(location, "/")+1:]
+	if data, _ := os.ReadFile(store.Path(id)); !bytes.Equal(data, content) {
+		t.Fatal("content mismatch")
+	}
+	info, err := store.Info(context.Background(), id)
+	if err != nil || info.Metadata["filename"] != "a.txt" {
+		t.Fatal(info, err)
+	}
+
+	if err = uploader.UploadReq(srv.URL + "/files").TusTerminate(location); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = uploader.UploadReq(srv.URL + "/files").TusOffset(location); !errors.Is(err, ErrTusUploadGone) {
+		t.Fatalf("expected not found: %v", err)
+	}
+
+	// 保存的上传地址已被终止,删除状态并重新创建上传
+	os.WriteFile(path+DownloadKey+tusStateSuffix, []byte(location), 0666)
+	recreated, err := uploader.UploadReq(srv.URL+"/files").TusUploadFile(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recreated == location {
+		t.Fatal("expected new upload")
+	}
+	if _, err = os.Stat(path + DownloadKey + tusStateSuffix); !os.IsNotExist(err) {
+		t.Fatal("state file not removed")
+	}
+}
@@ -26,6 +26,8 @@ const (
 
 	// ContentTypeOctetStream header value for binary data.
 	ContentTypeOctetStream = "application/octet-stream"
+	// ContentTypeOffsetOctetStream header value for tus upload PATCH body.
+	ContentTypeOffsetOctetStream = "application/offset+octet-stream"
 	// ContentTypeWebassembly header value for web assembly files.
 	ContentTypeWebassembly = "application/wasm"
 	// ContentTypeJson header value for JSON data.
@@ -49,6 +51,10 @@ const (
 	ContentTypeMsgPack2 = "application/x-msgpack"
 	// ContentTypeForm header value for post form data.
 	ContentTypeForm = "application/x-www-form-urlencoded"
+	// ContentTypeEventStream header value for Server-Sent Events.
+	ContentTypeEventStream = "text/event-stream"
+	// ContentTypeNDJson header value for newline delimited JSON.
+	ContentTypeNDJson = "application/x-ndjson"
 
 	// ContentTypeGrpc Content-Type header value for gRPC.
 	ContentTypeGrpc      = "application/grpc"
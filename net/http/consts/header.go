@@ -9,7 +9,10 @@ const (
 	HeaderTrace                       = "Tracing"
 	HeaderTraceID                     = "Tracing-ID"
 	HeaderTraceBin                    = "Tracing-Bin"
+	HeaderTraceparent                 = "traceparent"
 	HeaderAuthorization               = "Authorization"
+	HeaderProxyAuthorization          = "Proxy-Authorization"
+	HeaderProxyAuthenticate           = "Proxy-Authenticate"
 	HeaderCookie                      = "Cookie"
 	HeaderCookieValueToken            = "token"
 	HeaderCookieValueDel              = "del"
@@ -27,6 +30,15 @@ const (
 	HeaderInternal                    = "Internal"
 	HeaderTE                          = "TE"
 	HeaderLastModified                = "Last-Modified"
+	HeaderETag                        = "ETag"
+	HeaderIfNoneMatch                 = "If-None-Match"
+	HeaderIfModifiedSince             = "If-Modified-Since"
+	HeaderIfRange                     = "If-Range"
+	HeaderVary                        = "Vary"
+	HeaderDate                        = "Date"
+	HeaderExpires                     = "Expires"
+	HeaderAge                         = "Age"
+	HeaderXCache                      = "X-Cache"
 	HeaderContentLength               = "Content-Length"
 	HeaderAccessControlRequestMethod  = "Access-Control-Request-Method"
 	HeaderAccessControlRequestHeaders = "Access-Control-Request-Headers"
@@ -35,6 +47,8 @@ const (
 	HeaderRange                       = "Range"
 	HeaderContentRange                = "Content-Range"
 	HeaderAcceptRanges                = "Accept-Ranges"
+	HeaderAllow                       = "Allow"
+	HeaderLastEventID                 = "Last-Event-ID"
 )
 
 const (
@@ -47,3 +61,18 @@ const (
 	HeaderLocation   = "Location"
 	HeaderArea       = "Area"
 )
+
+// tus可恢复上传协议
+const (
+	HeaderTusResumable         = "Tus-Resumable"
+	HeaderTusVersion           = "Tus-Version"
+	HeaderTusExtension         = "Tus-Extension"
+	HeaderTusMaxSize           = "Tus-Max-Size"
+	HeaderTusChecksumAlgorithm = "Tus-Checksum-Algorithm"
+	HeaderUploadOffset         = "Upload-Offset"
+	HeaderUploadLength         = "Upload-Length"
+	HeaderUploadMetadata       = "Upload-Metadata"
+	HeaderUploadChecksum       = "Upload-Checksum"
+	HeaderXHTTPMethodOverride  = "X-HTTP-Method-Override"
+	TusVersion                 = "1.0.0"
+)
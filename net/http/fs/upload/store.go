@@ -0,0 +1,164 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package upload
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNotFound         = errors.New("upload not found")
+	ErrOffsetMismatch   = errors.New("upload offset mismatch")
+	ErrChecksumMismatch = errors.New("upload checksum mismatch")
+	ErrInvalidID        = errors.New("invalid upload id")
+)
+
+// ValidID 上传id不能为空、.或..,也不能包含路径分隔符,避免访问存储目录之外的文件
+func ValidID(id string) bool {
+	return id != "" && id != "." && id != ".." && !strings.ContainsAny(id, `/\`)
+}
+
+// Info 上传的状态
+type Info struct {
+	ID       string            `json:"id"`
+	Size     int64             `json:"size"`
+	Offset   int64             `json:"offset"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+	Created  time.Time         `json:"created"`
+}
+
+func (i *Info) Complete() bool {
+	return i.Offset == i.Size
+}
+
+// Store 上传的存储后端,同一上传的调用由Handler串行化
+type Store interface {
+	Create(ctx context.Context, info *Info) error
+	// Info 不存在时返回ErrNotFound
+	Info(ctx context.Context, id string) (*Info, error)
+	// Write 从offset追加写入,offset与当前偏移不一致时返回ErrOffsetMismatch
+	// r返回ErrChecksumMismatch时丢弃本次写入的数据,其他错误保留已写入的部分以便续传
+	Write(ctx context.Context, id string, offset int64, r io.Reader) (int64, error)
+	Terminate(ctx context.Context, id string) error
+}
+
+// FileStore 本地文件存储,数据保存在Dir/id,状态保存在Dir/id.info
+type FileStore struct {
+	Dir string
+}
+
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &FileStore{Dir: dir}, nil
+}
+
+// Path 上传数据文件的路径,上传完成后即为完整的文件,id需通过ValidID校验
+func (s *FileStore) Path(id string) string {
+	return filepath.Join(s.Dir, filepath.Base(id))
+}
+
+func (s *FileStore) infoPath(id string) string {
+	return s.Path(id) + ".info"
+}
+
+func (s *FileStore) Create(ctx context.Context, info *Info) error {
+	if !ValidID(info.ID) {
+		return ErrInvalidID
+	}
+	data, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(s.Path(info.ID), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return os.WriteFile(s.infoPath(info.ID), data, 0644)
+}
+
+func (s *FileStore) Info(ctx context.Context, id string) (*Info, error) {
+	if !ValidID(id) {
+		return nil, ErrNotFound
+	}
+	data, err := os.ReadFile(s.infoPath(id))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	info := new(Info)
+	if err = json.Unmarshal(data, info); err != nil {
+		return nil, err
+	}
+	stat, err := os.Stat(s.Path(id))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	info.Offset = stat.Size()
+	return info, nil
+}
+
+func (s *FileStore) Write(ctx context.Context, id string, offset int64, r io.Reader) (int64, error) {
+	if !ValidID(id) {
+		return 0, ErrNotFound
+	}
+	f, err := os.OpenFile(s.Path(id), os.O_WRONLY, 0644)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, ErrNotFound
+		}
+		return 0, err
+	}
+	defer f.Close()
+	stat, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	if stat.Size() != offset {
+		return 0, ErrOffsetMismatch
+	}
+	if _, err = f.Seek(offset, io.SeekStart); err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(f, r)
+	if errors.Is(err, ErrChecksumMismatch) {
+		if terr := f.Truncate(offset); terr != nil {
+			return 0, terr
+		}
+		return 0, err
+	}
+	return n, err
+}
+
+func (s *FileStore) Terminate(ctx context.Context, id string) error {
+	if !ValidID(id) {
+		return ErrNotFound
+	}
+	if err := os.Remove(s.infoPath(id)); err != nil {
+		if os.IsNotExist(err) {
+			return ErrNotFound
+		}
+		return err
+	}
+	return os.Remove(s.Path(id))
+}
@@ -0,0 +1,340 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package upload
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"hash"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hopeio/gox/datastructure/idgen/id"
+	"github.com/hopeio/gox/log"
+	"github.com/hopeio/gox/net/http/consts"
+)
+
+// StatusChecksumMismatch tus checksum扩展定义的状态码
+const StatusChecksumMismatch = 460
+
+const tusExtensions = "creation,termination,checksum"
+
+// ChecksumAlgorithms 支持的Upload-Checksum算法
+var ChecksumAlgorithms = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"crc32":  func() hash.Hash { return crc32.NewIEEE() },
+}
+
+func checksumAlgorithms() string {
+	algorithms := make([]string, 0, len(ChecksumAlgorithms))
+	for algorithm := range ChecksumAlgorithms {
+		algorithms = append(algorithms, algorithm)
+	}
+	sort.Strings(algorithms)
+	return strings.Join(algorithms, ",")
+}
+
+// TusConfig tus服务配置
+type TusConfig struct {
+	// 路由前缀,上传地址为BasePath/id
+	BasePath string
+	Store    Store
+	// 最大上传大小,0不限制
+	MaxSize int64
+	// 上传完成后调用
+	OnComplete func(ctx context.Context, info *Info)
+}
+
+// Tus 实现tus 1.0可恢复上传协议的core及creation、termination、checksum扩展
+type Tus struct {
+	conf  TusConfig
+	mu    sync.Mutex
+	locks map[string]struct{}
+}
+
+func NewTus(conf TusConfig) *Tus {
+	conf.BasePath = strings.TrimSuffix(conf.BasePath, "/")
+	return &Tus{conf: conf, locks: make(map[string]struct{})}
+}
+
+// 同一上传同时只允许一个请求修改
+func (t *Tus) lock(id string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.locks[id]; ok {
+		return false
+	}
+	t.locks[id] = struct{}{}
+	return true
+}
+
+func (t *Tus) unlock(id string) {
+	t.mu.Lock()
+	delete(t.locks, id)
+	t.mu.Unlock()
+}
+
+func (t *Tus) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	header := w.Header()
+	header.Set(consts.HeaderTusResumable, consts.TusVersion)
+	method := r.Method
+	if override := r.Header.Get(consts.HeaderXHTTPMethodOverride); override != "" {
+		method = override
+	}
+	if method == http.MethodOptions {
+		header.Set(consts.HeaderTusVersion, consts.TusVersion)
+		header.Set(consts.HeaderTusExtension, tusExtensions)
+		header.Set(consts.HeaderTusChecksumAlgorithm, checksumAlgorithms())
+		if t.conf.MaxSize > 0 {
+			header.Set(consts.HeaderTusMaxSize, strconv.FormatInt(t.conf.MaxSize, 10))
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if r.Header.Get(consts.HeaderTusResumable) != consts.TusVersion {
+		header.Set(consts.HeaderTusVersion, consts.TusVersion)
+		http.Error(w, "unsupported tus version", http.StatusPreconditionFailed)
+		return
+	}
+	uploadID := strings.Trim(strings.TrimPrefix(r.URL.Path, t.conf.BasePath), "/")
+	if uploadID == "" {
+		if method != http.MethodPost {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		t.create(w, r)
+		return
+	}
+	if !ValidID(uploadID) {
+		http.NotFound(w, r)
+		return
+	}
+	switch method {
+	case http.MethodHead:
+		t.head(w, r, uploadID)
+	case http.MethodPatch:
+		t.patch(w, r, uploadID)
+	case http.MethodDelete:
+		t.terminate(w, r, uploadID)
+	default:
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+func (t *Tus) error(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, ErrOffsetMismatch):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, ErrChecksumMismatch):
+		http.Error(w, err.Error(), StatusChecksumMismatch)
+	default:
+		log.Errorf("tus upload: %v", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
+func (t *Tus) create(w http.ResponseWriter, r *http.Request) {
+	size, err := strconv.ParseInt(r.Header.Get(consts.HeaderUploadLength), 10, 64)
+	if err != nil || size < 0 {
+		http.Error(w, "invalid Upload-Length", http.StatusBadRequest)
+		return
+	}
+	if t.conf.MaxSize > 0 && size > t.conf.MaxSize {
+		http.Error(w, "upload too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	metadata, err := ParseMetadata(r.Header.Get(consts.HeaderUploadMetadata))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	info := &Info{ID: id.UniqueID(), Size: size, Metadata: metadata, Created: time.Now()}
+	if err = t.conf.Store.Create(r.Context(), info); err != nil {
+		t.error(w, err)
+		return
+	}
+	w.Header().Set(consts.HeaderLocation, t.conf.BasePath+"/"+info.ID)
+	w.WriteHeader(http.StatusCreated)
+	if info.Complete() && t.conf.OnComplete != nil {
+		t.conf.OnComplete(r.Context(), info)
+	}
+}
+
+func (t *Tus) head(w http.ResponseWriter, r *http.Request, uploadID string) {
+	info, err := t.conf.Store.Info(r.Context(), uploadID)
+	if err != nil {
+		t.error(w, err)
+		return
+	}
+	header := w.Header()
+	header.Set(consts.HeaderCacheControl, "no-store")
+	header.Set(consts.HeaderUploadOffset, strconv.FormatInt(info.Offset, 10))
+	header.Set(consts.HeaderUploadLength, strconv.FormatInt(info.Size, 10))
+	if len(info.Metadata) > 0 {
+		header.Set(consts.HeaderUploadMetadata, FormatMetadata(info.Metadata))
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (t *Tus) patch(w http.ResponseWriter, r *http.Request, uploadID string) {
+	if r.Header.Get(consts.HeaderContentType) != consts.ContentTypeOffsetOctetStream {
+		http.Error(w, "invalid Content-Type", http.StatusUnsupportedMediaType)
+		return
+	}
+	offset, err := strconv.ParseInt(r.Header.Get(consts.HeaderUploadOffset), 10, 64)
+	if err != nil || offset < 0 {
+		http.Error(w, "invalid Upload-Offset", http.StatusBadRequest)
+		return
+	}
+	var newHash func() hash.Hash
+	var expect []byte
+	if checksum := r.Header.Get(consts.HeaderUploadChecksum); checksum != "" {
+		algorithm, sum, _ := strings.Cut(checksum, " ")
+		var ok bool
+		newHash, ok = ChecksumAlgorithms[algorithm]
+		if expect, err = base64.StdEncoding.DecodeString(sum); !ok || err != nil {
+			http.Error(w, "invalid Upload-Checksum", http.StatusBadRequest)
+			return
+		}
+	}
+	if !t.lock(uploadID) {
+		http.Error(w, "upload locked", http.StatusLocked)
+		return
+	}
+	defer t.unlock(uploadID)
+	info, err := t.conf.Store.Info(r.Context(), uploadID)
+	if err != nil {
+		t.error(w, err)
+		return
+	}
+	if offset != info.Offset {
+		t.error(w, ErrOffsetMismatch)
+		return
+	}
+	if r.ContentLength > info.Size-offset {
+		http.Error(w, "upload exceeds Upload-Length", http.StatusRequestEntityTooLarge)
+		return
+	}
+	var body io.Reader = &limitReader{r: r.Body, n: info.Size - offset}
+	if newHash != nil {
+		body = &checksumReader{r: body, hash: newHash(), expect: expect}
+	}
+	n, err := t.conf.Store.Write(r.Context(), uploadID, offset, body)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.error(w, err)
+		return
+	}
+	info.Offset = offset + n
+	w.Header().Set(consts.HeaderUploadOffset, strconv.FormatInt(info.Offset, 10))
+	w.WriteHeader(http.StatusNoContent)
+	if info.Complete() && t.conf.OnComplete != nil {
+		t.conf.OnComplete(r.Context(), info)
+	}
+}
+
+func (t *Tus) terminate(w http.ResponseWriter, r *http.Request, uploadID string) {
+	if !t.lock(uploadID) {
+		http.Error(w, "upload locked", http.StatusLocked)
+		return
+	}
+	defer t.unlock(uploadID)
+	if err := t.conf.Store.Terminate(r.Context(), uploadID); err != nil {
+		t.error(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// 读完后校验,不一致时返回ErrChecksumMismatch代替io.EOF
+type checksumReader struct {
+	r      io.Reader
+	hash   hash.Hash
+	expect []byte
+}
+
+func (c *checksumReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.hash.Write(p[:n])
+	if err == io.EOF && !bytes.Equal(c.hash.Sum(nil), c.expect) {
+		return n, ErrChecksumMismatch
+	}
+	return n, err
+}
+
+// 超过剩余长度的数据不写入
+type limitReader struct {
+	r io.Reader
+	n int64
+}
+
+func (l *limitReader) Read(p []byte) (int, error) {
+	if l.n <= 0 {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > l.n {
+		p = p[:l.n]
+	}
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	return n, err
+}
+
+// ParseMetadata 解析Upload-Metadata,格式为逗号分隔的"key base64(value)"
+func ParseMetadata(header string) (map[string]string, error) {
+	if header == "" {
+		return nil, nil
+	}
+	metadata := make(map[string]string)
+	for _, pair := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if key == "" {
+			return nil, errors.New("invalid Upload-Metadata")
+		}
+		decoded, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return nil, errors.New("invalid Upload-Metadata")
+		}
+		metadata[key] = string(decoded)
+	}
+	return metadata, nil
+}
+
+// FormatMetadata 按key排序编码为Upload-Metadata
+func FormatMetadata(metadata map[string]string) string {
+	keys := make([]string, 0, len(metadata))
+	for k := range metadata {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for i, k := range keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(k)
+		if v := metadata[k]; v != "" {
+			b.WriteByte(' ')
+			b.WriteString(base64.StdEncoding.EncodeToString([]byte(v)))
+		}
+	}
+	return b.String()
+}
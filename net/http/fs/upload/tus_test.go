@@ -0,0 +1,112 @@
+package upload
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/hopeio/gox/net/http/consts"
+)
+
+func tusRequest(h http.Handler, method, path, body string, header ...string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	r.Header.Set(consts.HeaderTusResumable, consts.TusVersion)
+	for i := 0; i+1 < len(header); i += 2 {
+		r.Header.Set(header[i], header[i+1])
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestTus(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var completed *Info
+	h := NewTus(TusConfig{BasePath: "/files/", Store: store, MaxSize: 100, OnComplete: func(ctx context.Context, info *Info) {
+		completed = info
+	}})
+
+	w := tusRequest(h, http.MethodOptions, "/files", "")
+	if w.Code != http.StatusNoContent || w.Header().Get(consts.HeaderTusExtension) != tusExtensions || w.Header().Get(consts.HeaderTusMaxSize) != "100" {
+		t.Fatal(w.Code, w.Header())
+	}
+	if w = tusRequest(h, http.MethodPost, "/files", "", consts.HeaderUploadLength, "101"); w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatal(w.Code)
+	}
+
+	w = tusRequest(h, http.MethodPost, "/files", "", consts.HeaderUploadLength, "11", consts.HeaderUploadMetadata, "filename aGVsbG8udHh0,empty")
+	location := w.Header().Get(consts.HeaderLocation)
+	if w.Code != http.StatusCreated || !strings.HasPrefix(location, "/files/") {
+		t.Fatal(w.Code, location)
+	}
+
+	patch := func(offset, body string, header ...string) *httptest.ResponseRecorder {
+		return tusRequest(h, http.MethodPatch, location, body, append([]string{consts.HeaderContentType, consts.ContentTypeOffsetOctetStream, consts.HeaderUploadOffset, offset}, header...)...)
+	}
+	if w = patch("0", "hello"); w.Code != http.StatusNoContent || w.Header().Get(consts.HeaderUploadOffset) != "5" {
+		t.Fatal(w.Code, w.Body.String())
+	}
+	if w = patch("0", "hello"); w.Code != http.StatusConflict {
+		t.Fatal(w.Code)
+	}
+	if w = patch("5", " world", consts.HeaderUploadChecksum, "sha1 "+base64.StdEncoding.EncodeToString([]byte("bad"))); w.Code != StatusChecksumMismatch {
+		t.Fatal(w.Code)
+	}
+
+	w = tusRequest(h, http.MethodHead, location, "")
+	if w.Header().Get(consts.HeaderUploadOffset) != "5" || w.Header().Get(consts.HeaderUploadLength) != "11" || w.Header().Get(consts.HeaderUploadMetadata) != "empty,filename aGVsbG8udHh0" {
+		t.Fatal(w.Header())
+	}
+
+	sum := sha1.Sum([]byte(" world"))
+	if w = patch("5", " world", consts.HeaderUploadChecksum, "sha1 "+base64.StdEncoding.EncodeToString(sum[:])); w.Code != http.StatusNoContent || w.Header().Get(consts.HeaderUploadOffset) != "11" {
+		t.Fatal(w.Code, w.Body.String())
+	}
+	id := strings.TrimPrefix(location, "/files/")
+	if completed == nil || completed.ID != id || completed.Metadata["filename"] != "hello.txt" {
+		t.Fatalf("unexpected completed: %+v", completed)
+	}
+	if data, _ := os.ReadFile(store.Path(id)); string(data) != "hello world" {
+		t.Fatal(string(data))
+	}
+
+	if w = tusRequest(h, http.MethodDelete, location, ""); w.Code != http.StatusNoContent {
+		t.Fatal(w.Code)
+	}
+	if w = tusRequest(h, http.MethodHead, location, ""); w.Code != http.StatusNotFound {
+		t.Fatal(w.Code)
+	}
+	r := httptest.NewRequest(http.MethodHead, location, nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusPreconditionFailed {
+		t.Fatal(w.Code)
+	}
+
+	// .及..不能访问存储目录之外的文件
+	outside := store.Dir + ".info"
+	os.WriteFile(outside, []byte("{}"), 0644)
+	defer os.Remove(outside)
+	for _, id := range []string{".", "..", `a\b`} {
+		if w = tusRequest(h, http.MethodDelete, "/files/"+id, ""); w.Code != http.StatusNotFound {
+			t.Fatal(id, w.Code)
+		}
+		if w = tusRequest(h, http.MethodHead, "/files/"+id, ""); w.Code != http.StatusNotFound {
+			t.Fatal(id, w.Code)
+		}
+	}
+	if _, err = os.Stat(outside); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = store.Info(context.Background(), ".."); err != ErrNotFound {
+		t.Fatal(err)
+	}
+}
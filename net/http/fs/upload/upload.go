@@ -29,7 +29,7 @@ func Upload(dir string) http.HandlerFunc {
 			return
 		}
 		if fs.Exist(dir + fileHeader.Filename) {
-			fmt.Fprintf(w, "ok", http.StatusOK)
+			fmt.Fprint(w, "ok")
 			return
 		}
 		// 解析Range头部
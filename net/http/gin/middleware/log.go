@@ -111,3 +111,11 @@ func LoggerWithConfig(conf log2.LoggerConfig) gin.HandlerFunc {
 		}
 	}
 }
+
+// Context 为请求ctx注入带trace id的logger,handler中通过log.FromContext(c.Request.Context())获取
+func Context() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Request = log2.WithRequestContext(c.Request, c.Writer)
+		c.Next()
+	}
+}
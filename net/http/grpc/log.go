@@ -7,11 +7,50 @@
 package grpc
 
 import (
+	"context"
+	"strings"
+
 	"github.com/hopeio/gox/log"
+	"github.com/hopeio/gox/net/http/consts"
+	"go.uber.org/zap"
 	"go.uber.org/zap/zapgrpc"
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/grpclog"
+	"google.golang.org/grpc/metadata"
 )
 
 func init() {
 	grpclog.SetLoggerV2(zapgrpc.NewLogger(log.CallerSkipLogger(4).Logger))
 }
+
+// LogContext 从metadata的traceparent或tracing-id中获取trace id(没有时生成),连同方法名写入ctx
+func LogContext(ctx context.Context, fullMethod string) context.Context {
+	md, _ := metadata.FromIncomingContext(ctx)
+	traceId, spanId := log.TraceFromHeader(func(key string) string {
+		if v := md.Get(strings.ToLower(key)); len(v) > 0 {
+			return v[0]
+		}
+		return ""
+	}, consts.HeaderTraceID)
+	ctx = log.WithTrace(ctx, traceId, spanId)
+	return log.WithContext(ctx, zap.String("method", fullMethod))
+}
+
+// UnaryLogInterceptor handler中通过log.FromContext(ctx)获取带有trace id的logger
+func UnaryLogInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	return handler(LogContext(ctx, info.FullMethod), req)
+}
+
+type logServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *logServerStream) Context() context.Context {
+	return s.ctx
+}
+
+// StreamLogInterceptor stream版本的UnaryLogInterceptor
+func StreamLogInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return handler(srv, &logServerStream{ServerStream: ss, ctx: LogContext(ss.Context(), info.FullMethod)})
+}
@@ -0,0 +1,422 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package gzip
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/hopeio/gox/net/http/consts"
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	EncodingGzip     = "gzip"
+	EncodingDeflate  = "deflate"
+	EncodingBrotli   = "br"
+	EncodingZstd     = "zstd"
+	EncodingIdentity = "identity"
+)
+
+var ErrUnsupportedEncoding = errors.New("unsupported content encoding")
+
+// Encoder 压缩writer,Close后通过Reset复用
+type Encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// Compressor 按级别创建压缩writer,级别无效时返回错误
+type Compressor func(w io.Writer, level int) (Encoder, error)
+
+// Decompressor 创建解压reader
+type Decompressor func(r io.Reader) (io.ReadCloser, error)
+
+type codec struct {
+	level      int
+	compress   Compressor
+	decompress Decompressor
+}
+
+var codecs = map[string]*codec{}
+
+// RegisterEncoding 注册Content-Encoding,同名覆盖,需在使用前(如init中)注册
+func RegisterEncoding(name string, defaultLevel int, compress Compressor, decompress Decompressor) {
+	codecs[strings.ToLower(name)] = &codec{level: defaultLevel, compress: compress, decompress: decompress}
+}
+
+func init() {
+	RegisterEncoding(EncodingGzip, gzip.DefaultCompression, func(w io.Writer, level int) (Encoder, error) {
+		return gzip.NewWriterLevel(w, level)
+	}, func(r io.Reader) (io.ReadCloser, error) {
+		return gzip.NewReader(r)
+	})
+	// deflate按RFC 9110为zlib格式,解压时兼容部分实现发送的裸deflate
+	RegisterEncoding(EncodingDeflate, flate.DefaultCompression, func(w io.Writer, level int) (Encoder, error) {
+		return zlib.NewWriterLevel(w, level)
+	}, func(r io.Reader) (io.ReadCloser, error) {
+		br := bufio.NewReader(r)
+		if header, err := br.Peek(2); err == nil && header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+			return zlib.NewReader(br)
+		}
+		return flate.NewReader(br), nil
+	})
+	RegisterEncoding(EncodingBrotli, brotli.DefaultCompression, func(w io.Writer, level int) (Encoder, error) {
+		if level < brotli.BestSpeed || level > brotli.BestCompression {
+			return nil, fmt.Errorf("brotli: invalid compression level: %d", level)
+		}
+		return brotli.NewWriterLevel(w, level), nil
+	}, func(r io.Reader) (io.ReadCloser, error) {
+		return io.NopCloser(brotli.NewReader(r)), nil
+	})
+	// zstd的级别为zstd.EncoderLevel
+	RegisterEncoding(EncodingZstd, int(zstd.SpeedDefault), func(w io.Writer, level int) (Encoder, error) {
+		return zstd.NewWriter(w, zstd.WithEncoderLevel(zstd.EncoderLevel(level)), zstd.WithEncoderConcurrency(1))
+	}, func(r io.Reader) (io.ReadCloser, error) {
+		d, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		return d.IOReadCloser(), nil
+	})
+}
+
+type poolKey struct {
+	encoding string
+	level    int
+}
+
+// 按编码和级别复用Encoder,不同handler间共享
+var encoderPools sync.Map
+
+func encoderPool(encoding string, level int) (*sync.Pool, error) {
+	key := poolKey{encoding, level}
+	if pool, ok := encoderPools.Load(key); ok {
+		return pool.(*sync.Pool), nil
+	}
+	c, ok := codecs[encoding]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedEncoding, encoding)
+	}
+	// 先创建一个以校验级别
+	enc, err := c.compress(io.Discard, level)
+	if err != nil {
+		return nil, err
+	}
+	pool := &sync.Pool{New: func() any {
+		enc, _ := c.compress(io.Discard, level)
+		return enc
+	}}
+	pool.Put(enc)
+	actual, _ := encoderPools.LoadOrStore(key, pool)
+	return actual.(*sync.Pool), nil
+}
+
+// Negotiate 按Accept-Encoding的q值从offers中选择编码,q值相同时按offers顺序,没有可用编码时返回""
+func Negotiate(acceptEncoding string, offers []string) string {
+	if acceptEncoding == "" {
+		return ""
+	}
+	qs := make(map[string]float64)
+	wildcard := -1.0
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			k, v, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if strings.EqualFold(k, "q") {
+				if f, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+					q = f
+				}
+			}
+		}
+		switch name {
+		case "*":
+			wildcard = q
+		case "x-gzip":
+			qs[EncodingGzip] = q
+		default:
+			qs[name] = q
+		}
+	}
+	var best string
+	var bestQ float64
+	for _, offer := range offers {
+		q, ok := qs[offer]
+		if !ok {
+			q = wildcard
+		}
+		if q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
+
+// DecodeBody 按请求的Content-Encoding替换r.Body为解压后的内容,多个编码按逆序解压
+func DecodeBody(r *http.Request) error {
+	header := r.Header.Get(consts.HeaderContentEncoding)
+	if header == "" {
+		return nil
+	}
+	encodings := strings.Split(header, ",")
+	body := r.Body
+	for i := len(encodings) - 1; i >= 0; i-- {
+		encoding := strings.ToLower(strings.TrimSpace(encodings[i]))
+		if encoding == "" || encoding == EncodingIdentity {
+			continue
+		}
+		if encoding == "x-gzip" {
+			encoding = EncodingGzip
+		}
+		c, ok := codecs[encoding]
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrUnsupportedEncoding, encoding)
+		}
+		reader, err := c.decompress(body)
+		if err != nil {
+			return fmt.Errorf("failed to create %s reader %w", encoding, err)
+		}
+		body = &decodedBody{ReadCloser: reader, source: body}
+	}
+	r.Body = body
+	r.Header.Del(consts.HeaderContentEncoding)
+	r.Header.Del(consts.HeaderContentLength)
+	r.ContentLength = -1
+	return nil
+}
+
+// 关闭解压reader的同时关闭原始body
+type decodedBody struct {
+	io.ReadCloser
+	source io.ReadCloser
+}
+
+func (b *decodedBody) Close() error {
+	err := b.ReadCloser.Close()
+	if serr := b.source.Close(); err == nil {
+		err = serr
+	}
+	return err
+}
+
+// Encoding 压缩中间件,按Accept-Encoding协商响应的编码,并按Content-Encoding解压请求体
+// 小于MinSize或ExcludedContentTypes中的响应不压缩,编码级别无效时panic
+func Encoding(next http.Handler, options ...Option) http.Handler {
+	return newEncodingHandler(next, newOptions(options...))
+}
+
+type encodingHandler struct {
+	*Options
+	next  http.Handler
+	pools map[string]*sync.Pool
+}
+
+func newEncodingHandler(next http.Handler, o *Options) *encodingHandler {
+	h := &encodingHandler{Options: o, next: next, pools: make(map[string]*sync.Pool, len(o.Encodings))}
+	for _, encoding := range o.Encodings {
+		c, ok := codecs[encoding]
+		if !ok {
+			panic(fmt.Errorf("%w: %s", ErrUnsupportedEncoding, encoding))
+		}
+		level, ok := o.Levels[encoding]
+		if !ok {
+			level = c.level
+		}
+		pool, err := encoderPool(encoding, level)
+		if err != nil {
+			panic(err)
+		}
+		h.pools[encoding] = pool
+	}
+	return h
+}
+
+func (h *encodingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := DecodeBody(r); err != nil {
+		if errors.Is(err, ErrUnsupportedEncoding) {
+			http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+		} else {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+	if !h.shouldCompress(r) {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+	addVary(w.Header(), consts.HeaderAcceptEncoding)
+	encoding := Negotiate(r.Header.Get(consts.HeaderAcceptEncoding), h.Encodings)
+	if encoding == "" {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+	ew := &encodingWriter{ResponseWriter: w, handler: h, encoding: encoding}
+	defer ew.Close()
+	h.next.ServeHTTP(ew, r)
+}
+
+func (h *encodingHandler) shouldCompress(r *http.Request) bool {
+	if r.Method == http.MethodHead || strings.Contains(strings.ToLower(r.Header.Get(consts.HeaderConnection)), "upgrade") {
+		return false
+	}
+	return !h.ExcludedExtensions.Contains(filepath.Ext(r.URL.Path)) &&
+		!h.ExcludedPaths.Contains(r.URL.Path) &&
+		!h.ExcludedPathsRegex.Contains(r.URL.Path)
+}
+
+func addVary(header http.Header, value string) {
+	for _, v := range header.Values(consts.HeaderVary) {
+		for _, field := range strings.Split(v, ",") {
+			if field = strings.TrimSpace(field); field == "*" || strings.EqualFold(field, value) {
+				return
+			}
+		}
+	}
+	header.Add(consts.HeaderVary, value)
+}
+
+// encodingWriter 缓冲响应直到达到MinSize或响应结束,再根据状态码和响应头决定是否压缩
+type encodingWriter struct {
+	http.ResponseWriter
+	handler  *encodingHandler
+	encoding string
+	encoder  Encoder
+	buf      []byte
+	code     int
+	decided  bool
+}
+
+func (e *encodingWriter) WriteHeader(code int) {
+	if e.code != 0 || e.decided {
+		return
+	}
+	// 1xx直接发送,后续仍可写最终状态
+	if code >= 100 && code < 200 && code != http.StatusSwitchingProtocols {
+		e.ResponseWriter.WriteHeader(code)
+		return
+	}
+	e.code = code
+	if !bodyAllowed(code) {
+		e.decide(false)
+	}
+}
+
+func (e *encodingWriter) Write(data []byte) (int, error) {
+	if e.code == 0 {
+		e.WriteHeader(http.StatusOK)
+	}
+	if !e.decided {
+		e.buf = append(e.buf, data...)
+		if len(e.buf) < e.handler.MinSize {
+			return len(data), nil
+		}
+		if err := e.decide(true); err != nil {
+			return 0, err
+		}
+		return len(data), nil
+	}
+	if e.encoder != nil {
+		return e.encoder.Write(data)
+	}
+	return e.ResponseWriter.Write(data)
+}
+
+func (e *encodingWriter) WriteString(s string) (int, error) {
+	return e.Write([]byte(s))
+}
+
+// decide 决定是否压缩并写出header和缓冲的数据,sizeOK表示响应大小满足MinSize
+func (e *encodingWriter) decide(sizeOK bool) error {
+	e.decided = true
+	if e.code == 0 {
+		e.code = http.StatusOK
+	}
+	header := e.Header()
+	if _, ok := header[consts.HeaderContentType]; !ok && len(e.buf) > 0 {
+		// 压缩后net/http无法再根据内容识别类型
+		header.Set(consts.HeaderContentType, http.DetectContentType(e.buf))
+	}
+	if sizeOK && bodyAllowed(e.code) && e.code != http.StatusPartialContent &&
+		header.Get(consts.HeaderContentEncoding) == "" && header.Get(consts.HeaderContentRange) == "" &&
+		!e.handler.ExcludedContentTypes.Contains(header.Get(consts.HeaderContentType)) {
+		header.Set(consts.HeaderContentEncoding, e.encoding)
+		header.Del(consts.HeaderContentLength)
+		header.Del(consts.HeaderAcceptRanges)
+		if etag := header.Get(consts.HeaderETag); etag != "" && !strings.HasPrefix(etag, "W/") {
+			header.Set(consts.HeaderETag, "W/"+etag)
+		}
+		e.encoder = e.handler.pools[e.encoding].Get().(Encoder)
+		e.encoder.Reset(e.ResponseWriter)
+	}
+	e.ResponseWriter.WriteHeader(e.code)
+	if len(e.buf) == 0 {
+		return nil
+	}
+	var err error
+	if e.encoder != nil {
+		_, err = e.encoder.Write(e.buf)
+	} else {
+		_, err = e.ResponseWriter.Write(e.buf)
+	}
+	e.buf = nil
+	return err
+}
+
+// Flush 未达到MinSize时按不压缩发送,之后的数据直接写出
+func (e *encodingWriter) Flush() {
+	if !e.decided {
+		e.decide(len(e.buf) >= e.handler.MinSize)
+	}
+	if e.encoder != nil {
+		e.encoder.Flush()
+	}
+	http.NewResponseController(e.ResponseWriter).Flush()
+}
+
+func (e *encodingWriter) Unwrap() http.ResponseWriter {
+	return e.ResponseWriter
+}
+
+func (e *encodingWriter) Close() error {
+	if !e.decided {
+		if e.code == 0 {
+			// handler没有写任何内容,交给net/http默认处理
+			e.decided = true
+			return nil
+		}
+		e.decide(len(e.buf) >= e.handler.MinSize)
+	}
+	if e.encoder == nil {
+		return nil
+	}
+	err := e.encoder.Close()
+	e.encoder.Reset(io.Discard)
+	e.handler.pools[e.encoding].Put(e.encoder)
+	e.encoder = nil
+	return err
+}
+
+func bodyAllowed(code int) bool {
+	return code >= 200 && code != http.StatusNoContent && code != http.StatusNotModified
+}
@@ -0,0 +1,159 @@
+package gzip
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/hopeio/gox/net/http/consts"
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestNegotiate(t *testing.T) {
+	offers := []string{EncodingZstd, EncodingBrotli, EncodingGzip, EncodingDeflate}
+	cases := map[string]string{
+		"":                          "",
+		"gzip, deflate, br":         EncodingBrotli,
+		"gzip;q=1.0, br;q=0.5":      EncodingGzip,
+		"x-gzip":                    EncodingGzip,
+		"*":                         EncodingZstd,
+		"*;q=0.5, zstd;q=0, br;q=0": EncodingGzip,
+		"identity":                  "",
+		"gzip;q=0":                  "",
+		"deflate;q=0.8, GZIP;q=0.9": EncodingGzip,
+	}
+	for accept, expect := range cases {
+		if got := Negotiate(accept, offers); got != expect {
+			t.Errorf("%q: expect %q, got %q", accept, expect, got)
+		}
+	}
+}
+
+func decode(t *testing.T, encoding string, body []byte) []byte {
+	var r io.Reader
+	var err error
+	switch encoding {
+	case EncodingGzip:
+		r, err = gzip.NewReader(bytes.NewReader(body))
+	case EncodingDeflate:
+		r, err = zlibReader(body)
+	case EncodingBrotli:
+		r = brotli.NewReader(bytes.NewReader(body))
+	case EncodingZstd:
+		r, err = zstd.NewReader(bytes.NewReader(body))
+	default:
+		return body
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(encoding, err)
+	}
+	return data
+}
+
+func zlibReader(body []byte) (io.Reader, error) {
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+	req.Header.Set(consts.HeaderContentEncoding, EncodingDeflate)
+	return req.Body, DecodeBody(req)
+}
+
+func TestEncoding(t *testing.T) {
+	large := strings.Repeat("hello encoding ", 200)
+	h := Encoding(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/small":
+			w.Write([]byte("small"))
+		case "/png":
+			w.Header().Set(consts.HeaderContentType, "image/png")
+			w.Write([]byte(large))
+		case "/echo":
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			w.Header().Set(consts.HeaderETag, `"v1"`)
+			w.Header().Set(consts.HeaderContentLength, "3000")
+			// 分两次写,第一次低于MinSize
+			w.Write(body[:10])
+			w.Write(body[10:])
+		}
+	}))
+	request := func(path, accept string, body []byte, contentEncoding string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(body))
+		r.Header.Set(consts.HeaderAcceptEncoding, accept)
+		if contentEncoding != "" {
+			r.Header.Set(consts.HeaderContentEncoding, contentEncoding)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	for _, encoding := range []string{EncodingGzip, EncodingDeflate, EncodingBrotli, EncodingZstd} {
+		// 请求体用同一编码压缩,验证对称解压
+		var compressed bytes.Buffer
+		enc, err := codecs[encoding].compress(&compressed, codecs[encoding].level)
+		if err != nil {
+			t.Fatal(err)
+		}
+		enc.Write([]byte(large))
+		enc.Close()
+
+		w := request("/echo", encoding+", identity;q=0.1", compressed.Bytes(), encoding)
+		header := w.Header()
+		if w.Code != http.StatusOK || header.Get(consts.HeaderContentEncoding) != encoding ||
+			header.Get(consts.HeaderVary) != consts.HeaderAcceptEncoding || header.Get(consts.HeaderContentLength) != "" ||
+			header.Get(consts.HeaderETag) != `W/"v1"` || !strings.HasPrefix(header.Get(consts.HeaderContentType), "text/plain") {
+			t.Fatal(encoding, w.Code, header)
+		}
+		if w.Body.Len() >= len(large) {
+			t.Fatalf("%s: not compressed: %d", encoding, w.Body.Len())
+		}
+		if data := decode(t, encoding, w.Body.Bytes()); string(data) != large {
+			t.Fatalf("%s: content mismatch", encoding)
+		}
+	}
+
+	// 兼容裸deflate请求体
+	var raw bytes.Buffer
+	fw, _ := flate.NewWriter(&raw, flate.BestSpeed)
+	fw.Write([]byte(large))
+	fw.Close()
+	if w := request("/echo", "", raw.Bytes(), EncodingDeflate); w.Body.String() != large || w.Header().Get(consts.HeaderContentEncoding) != "" {
+		t.Fatal("raw deflate body", w.Code)
+	}
+
+	if w := request("/small", "gzip", nil, ""); w.Body.String() != "small" || w.Header().Get(consts.HeaderContentEncoding) != "" || w.Header().Get(consts.HeaderVary) != consts.HeaderAcceptEncoding {
+		t.Fatal("small response", w.Header())
+	}
+	if w := request("/png", "gzip", nil, ""); w.Body.String() != large || w.Header().Get(consts.HeaderContentEncoding) != "" {
+		t.Fatal("excluded content type", w.Header())
+	}
+	if w := request("/echo", "gzip", []byte(large), "compress"); w.Code != http.StatusUnsupportedMediaType {
+		t.Fatal(w.Code)
+	}
+}
+
+func TestGzip(t *testing.T) {
+	large := strings.Repeat("hello gzip ", 200)
+	h := Gzip(BestSpeed, WithHandler(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, large)
+	}))
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set(consts.HeaderAcceptEncoding, "br, gzip")
+	w := httptest.NewRecorder()
+	h(w, r)
+	if w.Header().Get(consts.HeaderContentEncoding) != EncodingGzip || string(decode(t, EncodingGzip, w.Body.Bytes())) != large {
+		t.Fatal(w.Header())
+	}
+}
@@ -10,26 +10,63 @@ import (
 	"net/http"
 	"regexp"
 	"strings"
+
+	"github.com/hopeio/gox/net/http/consts"
 )
 
 var (
 	DefaultExcludedExtensions = NewExcludedExtensions([]string{
 		".png", ".gif", ".jpeg", ".jpg",
 	})
+	// DefaultExcludedContentTypes 已压缩或流式的内容类型,以/结尾的按前缀匹配
+	DefaultExcludedContentTypes = NewExcludedContentTypes([]string{
+		"image/png", "image/gif", "image/jpeg", "image/webp", "image/avif",
+		"video/", "audio/", "font/woff", "font/woff2",
+		"application/zip", "application/gzip", "application/x-gzip", "application/zstd",
+		"application/x-7z-compressed", "application/x-rar-compressed", "application/x-xz",
+		consts.ContentTypeEventStream,
+	})
 	DefaultOptions = &Options{
-		ExcludedExtensions: DefaultExcludedExtensions,
+		ExcludedExtensions:   DefaultExcludedExtensions,
+		ExcludedContentTypes: DefaultExcludedContentTypes,
+		MinSize:              DefaultMinSize,
+		Encodings:            []string{EncodingZstd, EncodingBrotli, EncodingGzip, EncodingDeflate},
 	}
 )
 
+// DefaultMinSize 小于该大小的响应不压缩
+const DefaultMinSize = 1024
+
 type Options struct {
-	ExcludedExtensions ExcludedExtensions
-	ExcludedPaths      ExcludedPaths
-	ExcludedPathsRegex ExcludedPathsRegex
-	Handler            http.HandlerFunc
+	ExcludedExtensions   ExcludedExtensions
+	ExcludedPaths        ExcludedPaths
+	ExcludedPathsRegex   ExcludedPathsRegex
+	ExcludedContentTypes ExcludedContentTypes
+	// 响应小于MinSize时不压缩
+	MinSize int
+	// 可用的编码,q值相同时按顺序优先
+	Encodings []string
+	// 各编码的压缩级别,未设置时使用注册时的默认级别
+	Levels  map[string]int
+	Handler http.HandlerFunc
 }
 
 type Option func(*Options)
 
+// 复制DefaultOptions后应用options,避免修改DefaultOptions
+func newOptions(options ...Option) *Options {
+	o := *DefaultOptions
+	o.Encodings = append([]string(nil), o.Encodings...)
+	o.Levels = make(map[string]int, len(DefaultOptions.Levels))
+	for k, v := range DefaultOptions.Levels {
+		o.Levels[k] = v
+	}
+	for _, setter := range options {
+		setter(&o)
+	}
+	return &o
+}
+
 func WithExcludedExtensions(args []string) Option {
 	return func(o *Options) {
 		o.ExcludedExtensions = NewExcludedExtensions(args)
@@ -48,6 +85,33 @@ func WithExcludedPathsRegexes(args []string) Option {
 	}
 }
 
+func WithExcludedContentTypes(args []string) Option {
+	return func(o *Options) {
+		o.ExcludedContentTypes = NewExcludedContentTypes(args)
+	}
+}
+
+func WithMinSize(size int) Option {
+	return func(o *Options) {
+		o.MinSize = size
+	}
+}
+
+func WithEncodings(encodings ...string) Option {
+	return func(o *Options) {
+		o.Encodings = encodings
+	}
+}
+
+func WithLevel(encoding string, level int) Option {
+	return func(o *Options) {
+		if o.Levels == nil {
+			o.Levels = make(map[string]int)
+		}
+		o.Levels[encoding] = level
+	}
+}
+
 func WithHandler(decompressFn http.HandlerFunc) Option {
 	return func(o *Options) {
 		o.Handler = decompressFn
@@ -103,3 +167,20 @@ func (e ExcludedPathsRegex) Contains(requestURI string) bool {
 	}
 	return false
 }
+
+type ExcludedContentTypes []string
+
+func NewExcludedContentTypes(contentTypes []string) ExcludedContentTypes {
+	return ExcludedContentTypes(contentTypes)
+}
+
+func (e ExcludedContentTypes) Contains(contentType string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	for _, t := range e {
+		if t == mediaType || (strings.HasSuffix(t, "/") && strings.HasPrefix(mediaType, t)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,39 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package log
+
+import (
+	"net/http"
+
+	"github.com/hopeio/gox/log"
+	"github.com/hopeio/gox/net/http/consts"
+	"go.uber.org/zap"
+)
+
+// WithRequestContext 从traceparent或Tracing-ID header中获取trace id(没有时生成),连同请求信息写入请求的ctx
+// 并在响应中返回Tracing-ID,handler中通过log.FromContext(r.Context())获取带有trace id的logger
+func WithRequestContext(r *http.Request, w http.ResponseWriter) *http.Request {
+	traceId, spanId := log.TraceFromHeader(r.Header.Get, consts.HeaderTraceID)
+	ctx := log.WithTrace(r.Context(), traceId, spanId)
+	ctx = log.WithContext(ctx, zap.String("method", r.Method), zap.String("path", r.URL.Path))
+	if w != nil {
+		w.Header().Set(consts.HeaderTraceID, traceId)
+	}
+	return r.WithContext(ctx)
+}
+
+// ContextHandler 包装http.Handler,为每个请求注入带trace id的logger
+func ContextHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, WithRequestContext(r, w))
+	})
+}
+
+// ContextMiddleware 用于router.Use,router的中间件顺序执行,这里原地替换请求使后续handler获取到ctx
+func ContextMiddleware(w http.ResponseWriter, r *http.Request) {
+	*r = *WithRequestContext(r, w)
+}
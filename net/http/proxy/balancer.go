@@ -0,0 +1,205 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package proxy
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hopeio/gox/datastructure/consistenthash"
+	"github.com/hopeio/gox/net/http/consts"
+)
+
+const (
+	BalanceRoundRobin     = "round_robin"
+	BalanceLeastConn      = "least_conn"
+	BalanceConsistentHash = "consistent_hash"
+)
+
+// Target 上游的一个地址
+type Target struct {
+	URL *url.URL
+	raw string
+	// 主动健康检查的结果
+	healthy atomic.Bool
+	// 进行中的请求数,包括WebSocket连接
+	active atomic.Int64
+
+	mu sync.Mutex
+	// 被动健康检查,FailTimeout内连续失败的次数
+	fails     int
+	firstFail time.Time
+	downUntil time.Time
+}
+
+func newTarget(raw string) (*Target, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	t := &Target{URL: u, raw: raw}
+	t.healthy.Store(true)
+	return t, nil
+}
+
+func (t *Target) String() string {
+	return t.raw
+}
+
+// Healthy 主动检查健康且未被被动检查摘除
+func (t *Target) Healthy() bool {
+	if !t.healthy.Load() {
+		return false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return !time.Now().Before(t.downUntil)
+}
+
+// Active 进行中的请求数
+func (t *Target) Active() int64 {
+	return t.active.Load()
+}
+
+// 记录失败,failTimeout内失败maxFails次后摘除failTimeout
+func (t *Target) fail(maxFails int, failTimeout time.Duration) bool {
+	if maxFails <= 0 {
+		return false
+	}
+	now := time.Now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.fails == 0 || now.Sub(t.firstFail) > failTimeout {
+		t.fails = 0
+		t.firstFail = now
+	}
+	t.fails++
+	if t.fails >= maxFails {
+		t.fails = 0
+		t.downUntil = now.Add(failTimeout)
+		return true
+	}
+	return false
+}
+
+func (t *Target) succeed() {
+	t.mu.Lock()
+	t.fails = 0
+	t.mu.Unlock()
+}
+
+// Balancer 从可用的上游地址中选择一个,candidates不为空
+type Balancer interface {
+	Pick(r *http.Request, candidates []*Target) *Target
+}
+
+func newBalancer(conf *UpstreamConfig, targets []*Target) Balancer {
+	switch conf.Balance {
+	case BalanceLeastConn:
+		return &LeastConn{}
+	case BalanceConsistentHash:
+		return NewConsistentHash(conf.HashKey, targets)
+	default:
+		return &RoundRobin{}
+	}
+}
+
+type RoundRobin struct {
+	next atomic.Uint64
+}
+
+func (b *RoundRobin) Pick(r *http.Request, candidates []*Target) *Target {
+	return candidates[(b.next.Add(1)-1)%uint64(len(candidates))]
+}
+
+// LeastConn 选择进行中请求最少的,相同时轮询
+type LeastConn struct {
+	next atomic.Uint64
+}
+
+func (b *LeastConn) Pick(r *http.Request, candidates []*Target) *Target {
+	offset := int(b.next.Add(1) - 1)
+	var picked *Target
+	for i := range candidates {
+		t := candidates[(offset+i)%len(candidates)]
+		if picked == nil || t.Active() < picked.Active() {
+			picked = t
+		}
+	}
+	return picked
+}
+
+// ConsistentHash 按请求的key一致性哈希,选中的地址不可用时按顺序重新哈希
+type ConsistentHash struct {
+	key  func(r *http.Request) string
+	ring *consistenthash.Map
+}
+
+// NewConsistentHash key为"ip"(默认)、"path"、"header:Name"、"cookie:Name"或"query:Name"
+func NewConsistentHash(key string, targets []*Target) *ConsistentHash {
+	ring := consistenthash.New(100, nil)
+	for _, t := range targets {
+		ring.Add(t.raw)
+	}
+	return &ConsistentHash{key: hashKey(key), ring: ring}
+}
+
+func (b *ConsistentHash) Pick(r *http.Request, candidates []*Target) *Target {
+	key := b.key(r)
+	for i := 0; i < len(candidates); i++ {
+		k := key
+		if i > 0 {
+			k = key + "#" + strconv.Itoa(i)
+		}
+		raw := b.ring.Get(k)
+		for _, t := range candidates {
+			if t.raw == raw {
+				return t
+			}
+		}
+	}
+	return candidates[0]
+}
+
+func hashKey(key string) func(r *http.Request) string {
+	kind, name, _ := strings.Cut(key, ":")
+	switch kind {
+	case "path":
+		return func(r *http.Request) string { return r.URL.Path }
+	case "header":
+		return func(r *http.Request) string { return r.Header.Get(name) }
+	case "cookie":
+		return func(r *http.Request) string {
+			if c, err := r.Cookie(name); err == nil {
+				return c.Value
+			}
+			return ""
+		}
+	case "query":
+		return func(r *http.Request) string { return r.URL.Query().Get(name) }
+	default:
+		return clientIP
+	}
+}
+
+func clientIP(r *http.Request) string {
+	if xff := r.Header.Get(consts.HeaderXForwardedFor); xff != "" {
+		ip, _, _ := strings.Cut(xff, ",")
+		return strings.TrimSpace(ip)
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,435 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"regexp"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/hopeio/gox/log"
+	"github.com/hopeio/gox/os/fs/loader"
+	timei "github.com/hopeio/gox/time"
+)
+
+var (
+	ErrNoRoute    = errors.New("no matched route")
+	ErrNoUpstream = errors.New("no available upstream")
+	// 上游返回可重试状态码时触发重试
+	errRetryStatus = errors.New("retryable upstream status")
+)
+
+// Config 网关配置,可以通过Watch热加载
+type Config struct {
+	Upstreams map[string]*UpstreamConfig `json:"upstreams"`
+	Routes    []*RouteConfig             `json:"routes"`
+}
+
+type UpstreamConfig struct {
+	Targets []string `json:"targets"`
+	// round_robin(默认)、least_conn、consistent_hash
+	Balance string `json:"balance"`
+	// consistent_hash的key: ip(默认)、path、header:Name、cookie:Name、query:Name
+	HashKey string `json:"hashKey"`
+	// 失败后换一个上游重试的次数,只重试没有请求体的请求
+	Retries int `json:"retries"`
+	// 触发重试的上游状态码,默认502、503、504
+	RetryStatus []int `json:"retryStatus"`
+	// 保留请求的Host头
+	PreserveHost bool              `json:"preserveHost"`
+	HealthCheck  HealthCheckConfig `json:"healthCheck"`
+	// 被动健康检查,FailTimeout内失败MaxFails次后摘除FailTimeout,MaxFails为0时不启用
+	MaxFails    int            `json:"maxFails"`
+	FailTimeout timei.Duration `json:"failTimeout"`
+}
+
+// HealthCheckConfig 主动健康检查,Path为空时不启用,返回2xx、3xx为健康
+type HealthCheckConfig struct {
+	Path     string         `json:"path"`
+	Interval timei.Duration `json:"interval"`
+	Timeout  timei.Duration `json:"timeout"`
+}
+
+type RouteConfig struct {
+	// 为空时匹配任意host
+	Host string `json:"host"`
+	// 路径前缀,多个路由匹配时最长前缀优先
+	Prefix   string `json:"prefix"`
+	Upstream string `json:"upstream"`
+	// 转发前去掉前缀
+	StripPrefix bool `json:"stripPrefix"`
+	// 路径重写,按顺序执行
+	Rewrite []RewriteRule `json:"rewrite"`
+}
+
+// RewriteRule 用正则替换路径,Replace支持$1引用分组
+type RewriteRule struct {
+	Match   string `json:"match"`
+	Replace string `json:"replace"`
+}
+
+type rewriteRule struct {
+	re      *regexp.Regexp
+	replace string
+}
+
+type route struct {
+	conf     *RouteConfig
+	upstream *Upstream
+	rewrite  []rewriteRule
+}
+
+// Upstream 上游地址池
+type Upstream struct {
+	Name     string
+	conf     *UpstreamConfig
+	targets  []*Target
+	balancer Balancer
+	stop     chan struct{}
+}
+
+// Targets 所有上游地址
+func (u *Upstream) Targets() []*Target {
+	return u.targets
+}
+
+func (u *Upstream) candidates(tried []*Target) []*Target {
+	candidates := make([]*Target, 0, len(u.targets))
+	for _, t := range u.targets {
+		if !t.Healthy() {
+			continue
+		}
+		var skip bool
+		for _, tt := range tried {
+			if tt == t {
+				skip = true
+				break
+			}
+		}
+		if !skip {
+			candidates = append(candidates, t)
+		}
+	}
+	return candidates
+}
+
+func (u *Upstream) retryStatus(code int) bool {
+	if len(u.conf.RetryStatus) == 0 {
+		return code == http.StatusBadGateway || code == http.StatusServiceUnavailable || code == http.StatusGatewayTimeout
+	}
+	for _, c := range u.conf.RetryStatus {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+type gatewayState struct {
+	upstreams map[string]*Upstream
+	routes    []*route
+}
+
+func (s *gatewayState) close() {
+	for _, u := range s.upstreams {
+		close(u.stop)
+	}
+}
+
+// Gateway 负载均衡反向代理,支持多上游、健康检查、重试、路径重写及WebSocket
+type Gateway struct {
+	state atomic.Pointer[gatewayState]
+	proxy *httputil.ReverseProxy
+	// 健康检查使用的client
+	checkClient *http.Client
+}
+
+// NewGateway transport为nil时使用http.DefaultTransport
+func NewGateway(conf *Config, transport http.RoundTripper) (*Gateway, error) {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	g := &Gateway{checkClient: &http.Client{Transport: transport}}
+	g.proxy = &httputil.ReverseProxy{
+		Rewrite:        g.rewrite,
+		Transport:      transport,
+		ModifyResponse: g.modifyResponse,
+		ErrorHandler:   g.errorHandler,
+	}
+	if err := g.Update(conf); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// Update 替换配置,相同上游中相同地址的健康状态保留,上游不再主动健康检查时恢复为健康
+func (g *Gateway) Update(conf *Config) error {
+	old := g.state.Load()
+	state := &gatewayState{upstreams: make(map[string]*Upstream, len(conf.Upstreams))}
+	for name, uc := range conf.Upstreams {
+		if len(uc.Targets) == 0 {
+			return fmt.Errorf("upstream %s: no targets", name)
+		}
+		u := &Upstream{Name: name, conf: uc, stop: make(chan struct{})}
+		for _, raw := range uc.Targets {
+			var t *Target
+			if old != nil && old.upstreams[name] != nil {
+				for _, ot := range old.upstreams[name].targets {
+					if ot.raw == raw {
+						t = ot
+						break
+					}
+				}
+			}
+			if t == nil {
+				var err error
+				if t, err = newTarget(raw); err != nil {
+					return fmt.Errorf("upstream %s: %w", name, err)
+				}
+			}
+			u.targets = append(u.targets, t)
+		}
+		u.balancer = newBalancer(uc, u.targets)
+		state.upstreams[name] = u
+	}
+	for _, rc := range conf.Routes {
+		u, ok := state.upstreams[rc.Upstream]
+		if !ok {
+			return fmt.Errorf("route %s%s: upstream %s not found", rc.Host, rc.Prefix, rc.Upstream)
+		}
+		r := &route{conf: rc, upstream: u}
+		for _, rule := range rc.Rewrite {
+			re, err := regexp.Compile(rule.Match)
+			if err != nil {
+				return fmt.Errorf("route %s%s: %w", rc.Host, rc.Prefix, err)
+			}
+			r.rewrite = append(r.rewrite, rewriteRule{re: re, replace: rule.Replace})
+		}
+		state.routes = append(state.routes, r)
+	}
+	sort.SliceStable(state.routes, func(i, j int) bool {
+		return len(state.routes[i].conf.Prefix) > len(state.routes[j].conf.Prefix)
+	})
+	for _, u := range state.upstreams {
+		if u.conf.HealthCheck.Path != "" {
+			go g.healthCheck(u)
+		}
+	}
+	g.state.Store(state)
+	if old != nil {
+		old.close()
+	}
+	// 关闭了主动健康检查的上游,复用的地址不再保留之前的检查结果
+	for _, u := range state.upstreams {
+		if u.conf.HealthCheck.Path == "" {
+			for _, t := range u.targets {
+				t.healthy.Store(true)
+			}
+		}
+	}
+	return nil
+}
+
+// Watch 通过loader加载配置,文件变化时热加载,decode为nil时使用json
+func (g *Gateway) Watch(ld *loader.Loader, decode func(io.Reader, any) error) error {
+	if decode == nil {
+		decode = func(r io.Reader, v any) error {
+			return json.NewDecoder(r).Decode(v)
+		}
+	}
+	return ld.Handle(func(r io.Reader) error {
+		conf := new(Config)
+		if err := decode(r, conf); err != nil {
+			return err
+		}
+		return g.Update(conf)
+	})
+}
+
+// Close 停止健康检查
+func (g *Gateway) Close() {
+	if state := g.state.Swap(&gatewayState{}); state != nil {
+		state.close()
+	}
+}
+
+// Upstream 根据名称获取上游
+func (g *Gateway) Upstream(name string) *Upstream {
+	return g.state.Load().upstreams[name]
+}
+
+func (g *Gateway) healthCheck(u *Upstream) {
+	conf := u.conf.HealthCheck
+	interval := time.Duration(conf.Interval)
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	timeout := time.Duration(conf.Timeout)
+	if timeout <= 0 {
+		timeout = interval
+	}
+	check := func() {
+		for _, t := range u.targets {
+			healthy := g.check(t.URL.JoinPath(conf.Path).String(), timeout)
+			if t.healthy.Swap(healthy) != healthy {
+				log.Warnf("upstream %s target %s healthy: %v", u.Name, t, healthy)
+			}
+		}
+	}
+	check()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-u.stop:
+			return
+		case <-ticker.C:
+			check()
+		}
+	}
+}
+
+func (g *Gateway) check(url string, timeout time.Duration) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return false
+	}
+	resp, err := g.checkClient.Do(req)
+	if err != nil {
+		return false
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	return resp.StatusCode < http.StatusBadRequest
+}
+
+func (g *Gateway) match(r *http.Request) *route {
+	host := r.Host
+	if i := strings.LastIndexByte(host, ':'); i > 0 && !strings.HasSuffix(host, "]") {
+		host = host[:i]
+	}
+	for _, rt := range g.state.Load().routes {
+		if rt.conf.Host != "" && !strings.EqualFold(rt.conf.Host, host) {
+			continue
+		}
+		if strings.HasPrefix(r.URL.Path, rt.conf.Prefix) {
+			return rt
+		}
+	}
+	return nil
+}
+
+type attemptKey struct{}
+
+// 一次转发尝试的状态
+type attempt struct {
+	route  *route
+	target *Target
+	last   bool
+	err    error
+}
+
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rt := g.match(r)
+	if rt == nil {
+		http.Error(w, ErrNoRoute.Error(), http.StatusNotFound)
+		return
+	}
+	u := rt.upstream
+	retries := u.conf.Retries
+	if r.Body != nil && r.Body != http.NoBody && r.ContentLength != 0 {
+		retries = 0
+	}
+	var tried []*Target
+	for i := 0; ; i++ {
+		candidates := u.candidates(tried)
+		if len(candidates) == 0 {
+			http.Error(w, ErrNoUpstream.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		a := &attempt{route: rt, target: u.balancer.Pick(r, candidates)}
+		tried = append(tried, a.target)
+		a.last = i >= retries || len(candidates) == 1
+		a.target.active.Add(1)
+		g.proxy.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), attemptKey{}, a)))
+		a.target.active.Add(-1)
+		if a.err == nil {
+			a.target.succeed()
+			return
+		}
+		if r.Context().Err() != nil {
+			return
+		}
+		if a.target.fail(u.conf.MaxFails, time.Duration(u.conf.FailTimeout)) {
+			log.Warnf("upstream %s target %s down: %v", u.Name, a.target, a.err)
+		}
+		if a.last {
+			if !errors.Is(a.err, errRetryStatus) {
+				http.Error(w, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
+			}
+			return
+		}
+	}
+}
+
+func (g *Gateway) rewrite(pr *httputil.ProxyRequest) {
+	a := pr.In.Context().Value(attemptKey{}).(*attempt)
+	path := pr.In.URL.Path
+	if a.route.conf.StripPrefix {
+		path = strings.TrimPrefix(path, a.route.conf.Prefix)
+		if !strings.HasPrefix(path, "/") {
+			path = "/" + path
+		}
+	}
+	for _, rule := range a.route.rewrite {
+		path = rule.re.ReplaceAllString(path, rule.replace)
+	}
+	if path != pr.In.URL.Path {
+		pr.Out.URL.Path = path
+		pr.Out.URL.RawPath = ""
+	}
+	pr.SetURL(a.target.URL)
+	pr.SetXForwarded()
+	if a.route.upstream.conf.PreserveHost {
+		pr.Out.Host = pr.In.Host
+	}
+}
+
+func (g *Gateway) modifyResponse(resp *http.Response) error {
+	a := resp.Request.Context().Value(attemptKey{}).(*attempt)
+	if a.route.upstream.retryStatus(resp.StatusCode) {
+		if !a.last {
+			resp.Body.Close()
+			return errRetryStatus
+		}
+		// 最后一次尝试时原样返回,但仍记录失败
+		a.err = errRetryStatus
+	}
+	return nil
+}
+
+func (g *Gateway) errorHandler(w http.ResponseWriter, r *http.Request, err error) {
+	a := r.Context().Value(attemptKey{}).(*attempt)
+	a.err = err
+	if r.Context().Err() != nil {
+		return
+	}
+	if a.last && !errors.Is(err, errRetryStatus) {
+		log.Errorf("proxy %s to %s: %v", r.URL.Path, a.target, err)
+	}
+}
@@ -0,0 +1,177 @@
+package proxy
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hopeio/gox/os/fs/loader"
+	timei "github.com/hopeio/gox/time"
+)
+
+func backend(name string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Upgrade") == "echo" {
+			conn, rw, _ := w.(http.Hijacker).Hijack()
+			defer conn.Close()
+			rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: echo\r\n\r\n")
+			rw.Flush()
+			line, _ := rw.ReadString('\n')
+			rw.WriteString(name + ":" + line)
+			rw.Flush()
+			return
+		}
+		fmt.Fprint(w, name+" "+r.URL.Path)
+	}))
+}
+
+func get(t *testing.T, h http.Handler, path string, header ...string) (int, string) {
+	r := httptest.NewRequest(http.MethodGet, path, nil)
+	for i := 0; i+1 < len(header); i += 2 {
+		r.Header.Set(header[i], header[i+1])
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w.Code, w.Body.String()
+}
+
+func TestGateway(t *testing.T) {
+	a, b := backend("a"), backend("b")
+	defer a.Close()
+	defer b.Close()
+	dead := httptest.NewServer(http.NotFoundHandler())
+	dead.Close()
+
+	g, err := NewGateway(&Config{
+		Upstreams: map[string]*UpstreamConfig{
+			"rr":   {Targets: []string{a.URL, b.URL}},
+			"hash": {Targets: []string{a.URL, b.URL}, Balance: BalanceConsistentHash, HashKey: "header:X-User"},
+			"dead": {Targets: []string{dead.URL, a.URL}, Retries: 1, MaxFails: 1, FailTimeout: timei.Duration(time.Minute)},
+		},
+		Routes: []*RouteConfig{
+			{Prefix: "/", Upstream: "rr"},
+			{Prefix: "/api/", Upstream: "rr", StripPrefix: true, Rewrite: []RewriteRule{{Match: `^/v1/(.*)$`, Replace: "/v2/$1"}}},
+			{Prefix: "/hash/", Upstream: "hash"},
+			{Prefix: "/dead/", Upstream: "dead"},
+		},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+
+	seen := map[string]int{}
+	for range 4 {
+		_, body := get(t, g, "/x")
+		seen[body]++
+	}
+	if seen["a /x"] != 2 || seen["b /x"] != 2 {
+		t.Fatalf("unexpected round robin: %v", seen)
+	}
+
+	if _, body := get(t, g, "/api/v1/users"); !strings.HasSuffix(body, " /v2/users") {
+		t.Fatalf("unexpected rewrite: %s", body)
+	}
+
+	_, first := get(t, g, "/hash/", "X-User", "alice")
+	for range 5 {
+		if _, body := get(t, g, "/hash/", "X-User", "alice"); body != first {
+			t.Fatalf("unexpected hash result: %s %s", body, first)
+		}
+	}
+
+	// 失败重试到另一个上游,并被动摘除
+	for range 3 {
+		if code, body := get(t, g, "/dead/"); code != http.StatusOK || body != "a /dead/" {
+			t.Fatalf("unexpected retry result: %d %s", code, body)
+		}
+	}
+	if g.Upstream("dead").Targets()[0].Healthy() {
+		t.Fatal("dead target should be down")
+	}
+
+	// WebSocket等Upgrade请求透传
+	srv := httptest.NewServer(g)
+	defer srv.Close()
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	fmt.Fprint(conn, "GET /ws HTTP/1.1\r\nHost: x\r\nConnection: Upgrade\r\nUpgrade: echo\r\n\r\n")
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil || resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatal(resp, err)
+	}
+	fmt.Fprint(conn, "hello\n")
+	line, err := br.ReadString('\n')
+	if err != nil || !strings.HasSuffix(line, ":hello\n") {
+		t.Fatal(line, err)
+	}
+}
+
+func TestGatewayWatch(t *testing.T) {
+	a, b := backend("a"), backend("b")
+	defer a.Close()
+	defer b.Close()
+	path := filepath.Join(t.TempDir(), "gateway.json")
+	write := func(target string) {
+		conf := `{"upstreams":{"u":{"targets":["` + target + `"],"healthCheck":{"path":"/health","interval":"1s"}}},"routes":[{"prefix":"/","upstream":"u"}]}`
+		if err := os.WriteFile(path, []byte(conf), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(a.URL)
+	g, err := NewGateway(&Config{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+	ld := loader.New(0, path)
+	if err = g.Watch(ld, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, body := get(t, g, "/"); body != "a /" {
+		t.Fatal(body)
+	}
+	write(b.URL)
+	if err = g.Watch(loader.New(0, path), nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, body := get(t, g, "/"); body != "b /" {
+		t.Fatal(body)
+	}
+
+	// 关闭主动健康检查后,之前检查不健康的地址恢复
+	dead := httptest.NewServer(http.NotFoundHandler())
+	dead.Close()
+	conf := &Config{
+		Upstreams: map[string]*UpstreamConfig{"u": {Targets: []string{dead.URL}, HealthCheck: HealthCheckConfig{Path: "/health", Interval: timei.Duration(time.Second)}}},
+		Routes:    []*RouteConfig{{Prefix: "/", Upstream: "u"}},
+	}
+	if err = g.Update(conf); err != nil {
+		t.Fatal(err)
+	}
+	target := g.Upstream("u").Targets()[0]
+	for i := 0; target.Healthy() && i < 100; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if target.Healthy() {
+		t.Fatal("dead target should be unhealthy")
+	}
+	conf.Upstreams = map[string]*UpstreamConfig{"u": {Targets: []string{dead.URL}}}
+	if err = g.Update(conf); err != nil {
+		t.Fatal(err)
+	}
+	if !g.Upstream("u").Targets()[0].Healthy() {
+		t.Fatal("target should be healthy without active health check")
+	}
+}
@@ -0,0 +1,186 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package router
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	httpi "github.com/hopeio/gox/net/http"
+)
+
+var ErrRouteNotFound = errors.New("route not found")
+
+// Route 注册的路由信息,用于命名、反向生成URL及路由列表(如apidoc)
+type Route struct {
+	Method  string
+	Path    string
+	Name    string
+	Handler http.Handler
+	router  *Router
+}
+
+// Named 设置路由名,重复的名称会panic
+func (route *Route) Named(name string) *Route {
+	r := route.router
+	if old, ok := r.names[name]; ok && old != route {
+		panic("route name '" + name + "' is already registered for path '" + old.Path + "'")
+	}
+	if route.Name != "" {
+		delete(r.names, route.Name)
+	}
+	if r.names == nil {
+		r.names = make(map[string]*Route)
+	}
+	route.Name = name
+	r.names[name] = route
+	return route
+}
+
+// Params 路径中的参数名,按出现顺序
+func (route *Route) Params() []string {
+	var names []string
+	for path := route.Path; ; {
+		wildcard, i, _ := findWildcard(path)
+		if i < 0 {
+			return names
+		}
+		names = append(names, paramKey(wildcard))
+		path = path[i+len(wildcard):]
+	}
+}
+
+// URL 用参数填充路径,:name及{name}参数会被转义,*name参数原样拼接且保证以/开头
+func (route *Route) URL(params Params) (string, error) {
+	var b strings.Builder
+	path := route.Path
+	for {
+		wildcard, i, _ := findWildcard(path)
+		if i < 0 {
+			b.WriteString(path)
+			return b.String(), nil
+		}
+		b.WriteString(path[:i])
+		path = path[i+len(wildcard):]
+		key := paramKey(wildcard)
+		value := params.ByName(key)
+		if wildcard[0] != '*' {
+			if value == "" {
+				return "", errors.New("missing param '" + key + "' for route '" + route.Path + "'")
+			}
+			b.WriteString(url.PathEscape(value))
+			continue
+		}
+		// catch-all前一个字符固定为/
+		if strings.HasPrefix(value, "/") {
+			value = value[1:]
+		}
+		b.WriteString(value)
+	}
+}
+
+func (r *Router) addRouteInfo(method, path string, handler http.Handler) *Route {
+	route := &Route{Method: method, Path: path, Handler: handler, router: r}
+	r.routes = append(r.routes, route)
+	return route
+}
+
+// URL 根据路由名反向生成URL
+func (r *Router) URL(name string, params Params) (string, error) {
+	route, ok := r.names[name]
+	if !ok {
+		return "", ErrRouteNotFound
+	}
+	return route.URL(params)
+}
+
+// Route 根据名称查找路由
+func (r *Router) Route(name string) *Route {
+	return r.names[name]
+}
+
+// Routes 所有注册的路由,按路径及方法排序
+func (r *Router) Routes() []Route {
+	routes := make([]Route, len(r.routes))
+	for i, route := range r.routes {
+		routes[i] = *route
+		routes[i].router = nil
+	}
+	sort.SliceStable(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		return routes[i].Method < routes[j].Method
+	})
+	return routes
+}
+
+// Group 路由分组,共享路径前缀及中间件
+// 分组中间件在注册路由时加在路由自身中间件之前,Use只影响之后注册的路由
+type Group struct {
+	router     *Router
+	prefix     string
+	middleware httpi.HandlerFuncs
+}
+
+// Group 创建路由分组
+func (r *Router) Group(prefix string, middleware ...http.HandlerFunc) *Group {
+	return &Group{router: r, prefix: joinPath("", prefix), middleware: middleware}
+}
+
+// Group 创建子分组,继承前缀及中间件
+func (g *Group) Group(prefix string, middleware ...http.HandlerFunc) *Group {
+	mw := make(httpi.HandlerFuncs, 0, len(g.middleware)+len(middleware))
+	mw = append(append(mw, g.middleware...), middleware...)
+	return &Group{router: g.router, prefix: joinPath(g.prefix, prefix), middleware: mw}
+}
+
+func (g *Group) Use(middleware ...http.HandlerFunc) {
+	g.middleware = append(g.middleware, middleware...)
+}
+
+func (g *Group) Prefix() string {
+	return g.prefix
+}
+
+func (g *Group) Handle(method, path string, middleware []http.HandlerFunc, httpHandler http.Handler) *Route {
+	return g.router.Handle(method, joinPath(g.prefix, path), g.combine(middleware), httpHandler)
+}
+
+func (g *Group) Handler(method, path string, handle ...http.HandlerFunc) *Route {
+	if len(handle) == 0 {
+		panic("handle must not be empty")
+	}
+	return g.router.Handler(method, joinPath(g.prefix, path), append(g.combine(handle[:len(handle)-1]), handle[len(handle)-1])...)
+}
+
+func (g *Group) combine(middleware []http.HandlerFunc) []http.HandlerFunc {
+	if len(g.middleware) == 0 {
+		return middleware
+	}
+	mw := make([]http.HandlerFunc, 0, len(g.middleware)+len(middleware))
+	return append(append(mw, g.middleware...), middleware...)
+}
+
+func joinPath(prefix, path string) string {
+	if path == "" {
+		if prefix == "" {
+			return "/"
+		}
+		return prefix
+	}
+	if path[0] != '/' {
+		path = "/" + path
+	}
+	if prefix == "" || prefix == "/" {
+		return path
+	}
+	return strings.TrimSuffix(prefix, "/") + path
+}
@@ -0,0 +1,58 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGroup(t *testing.T) {
+	r := New()
+	var trace []string
+	mw := func(name string) http.HandlerFunc {
+		return func(w http.ResponseWriter, req *http.Request) { trace = append(trace, name) }
+	}
+	api := r.Group("/api", mw("api"))
+	v1 := api.Group("v1/", mw("v1"))
+	v1.Handler(http.MethodGet, "/user/:id", mw("route"), func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("user"))
+	}).Named("user.show")
+	v1.Handler(http.MethodGet, "/files/*path", func(w http.ResponseWriter, req *http.Request) {}).Named("files")
+	r.Handler(http.MethodPost, "/login", func(w http.ResponseWriter, req *http.Request) {})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/user/1", nil))
+	if w.Body.String() != "user" || len(trace) != 3 || trace[0] != "api" || trace[1] != "v1" || trace[2] != "route" {
+		t.Fatalf("unexpected: %q %v", w.Body.String(), trace)
+	}
+
+	u, err := r.URL("user.show", Params{{Key: "id", Value: "a b"}})
+	if err != nil || u != "/api/v1/user/a%20b" {
+		t.Fatal(u, err)
+	}
+	u, err = r.URL("files", Params{{Key: "path", Value: "/a/b.txt"}})
+	if err != nil || u != "/api/v1/files/a/b.txt" {
+		t.Fatal(u, err)
+	}
+	if _, err = r.URL("user.show", nil); err == nil {
+		t.Fatal("expected missing param error")
+	}
+	if _, err = r.URL("none", nil); err != ErrRouteNotFound {
+		t.Fatal(err)
+	}
+
+	routes := r.Routes()
+	if len(routes) != 3 || routes[0].Path != "/api/v1/files/*path" || routes[2].Path != "/login" || routes[1].Name != "user.show" {
+		t.Fatalf("unexpected routes: %+v", routes)
+	}
+	if params := routes[1].Params(); len(params) != 1 || params[0] != "id" {
+		t.Fatal(params)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected duplicate name panic")
+		}
+	}()
+	r.Handler(http.MethodGet, "/other", func(w http.ResponseWriter, req *http.Request) {}).Named("files")
+}
@@ -0,0 +1,95 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package router
+
+import (
+	"errors"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/hopeio/gox/reflect/converter"
+)
+
+var errParamMismatch = errors.New("param mismatch")
+
+// 内置的参数类型,{id:int}转换为int,{uuid}等价于{uuid:uuid}
+var paramTypes = map[string]converter.StringConverterE{
+	"int":   converter.GetStringConverterEByKind(reflect.Int),
+	"uint":  converter.GetStringConverterEByKind(reflect.Uint),
+	"float": converter.GetStringConverterEByKind(reflect.Float64),
+	"bool":  converter.GetStringConverterEByKind(reflect.Bool),
+	"uuid":  regexpConverter(regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)),
+	"alpha": regexpConverter(regexp.MustCompile(`^[a-zA-Z]+$`)),
+}
+
+// RegisterParamType 注册参数类型,convert返回error表示不匹配,需在注册路由前调用
+func RegisterParamType(name string, convert converter.StringConverterE) {
+	paramTypes[name] = convert
+}
+
+func regexpConverter(re *regexp.Regexp) converter.StringConverterE {
+	return func(value string) (any, error) {
+		if re.MatchString(value) {
+			return nil, nil
+		}
+		return nil, errParamMismatch
+	}
+}
+
+// 解析:name或{name:constraint},约束为注册的类型名或正则
+func newParamNode(wildcard, fullPath string) *node {
+	n := &node{nType: param, path: wildcard}
+	if wildcard[0] == ':' {
+		n.key = wildcard[1:]
+		return n
+	}
+	name, constraint, ok := strings.Cut(wildcard[1:len(wildcard)-1], ":")
+	if name == "" {
+		panic("wildcards must be named with a non-empty name in path '" + fullPath + "'")
+	}
+	n.key = name
+	if !ok {
+		n.convert = paramTypes[name]
+		return n
+	}
+	if convert, ok := paramTypes[constraint]; ok {
+		n.convert = convert
+		return n
+	}
+	re, err := regexp.Compile("^(?:" + constraint + ")$")
+	if err != nil {
+		panic("invalid param constraint '" + constraint + "' in path '" + fullPath + "': " + err.Error())
+	}
+	n.convert = regexpConverter(re)
+	return n
+}
+
+// paramKey 通配段的参数名
+func paramKey(wildcard string) string {
+	if wildcard[0] == '{' {
+		name, _, _ := strings.Cut(wildcard[1:len(wildcard)-1], ":")
+		return name
+	}
+	return wildcard[1:]
+}
+
+// Typed 按名称获取转换后的参数值
+func (ps Params) Typed(name string) any {
+	for _, p := range ps {
+		if p.Key == name {
+			return p.Typed
+		}
+	}
+	return nil
+}
+
+// ParamAs 按名称获取转换后的参数值并断言为T
+func ParamAs[T any](ps Params, name string) (T, bool) {
+	v, ok := ps.Typed(name).(T)
+	return v, ok
+}
@@ -0,0 +1,65 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParamConstraint(t *testing.T) {
+	r := New()
+	var matched string
+	var ps Params
+	handle := func(name string) http.HandlerFunc {
+		return func(w http.ResponseWriter, req *http.Request) {
+			matched = name
+			ps = ParamsFromContext(req.Context())
+		}
+	}
+	r.Handler(http.MethodGet, "/user/{id:int}", handle("id"))
+	r.Handler(http.MethodGet, "/user/:name", handle("name"))
+	r.Handler(http.MethodGet, "/user/{uuid}/profile", handle("uuid"))
+	r.Handler(http.MethodGet, "/user/{slug:[a-z-]+}/posts", handle("slug"))
+	r.Handler(http.MethodGet, "/file/{name}", handle("file"))
+
+	cases := []struct {
+		path, matched, key, value string
+	}{
+		{"/user/42", "id", "id", "42"},
+		{"/user/bob", "name", "name", "bob"},
+		{"/user/0b5c8c4e-1f5a-4d2b-9c1e-3a7f2f1e8d6a/profile", "uuid", "uuid", "0b5c8c4e-1f5a-4d2b-9c1e-3a7f2f1e8d6a"},
+		{"/user/hello-world/posts", "slug", "slug", "hello-world"},
+		{"/file/a.txt", "file", "name", "a.txt"},
+	}
+	for _, c := range cases {
+		matched, ps = "", nil
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, c.path, nil))
+		if matched != c.matched || ps.ByName(c.key) != c.value {
+			t.Fatalf("%s: matched %q params %v", c.path, matched, ps)
+		}
+	}
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/user/42", nil))
+	if id, ok := ParamAs[int](ps, "id"); !ok || id != 42 {
+		t.Fatalf("unexpected typed param: %v", ps)
+	}
+
+	w := httptest.NewRecorder()
+	matched = ""
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/user/Bad_Slug/posts", nil))
+	if matched != "" || w.Code != http.StatusNotFound {
+		t.Fatalf("unexpected match %q %d", matched, w.Code)
+	}
+
+	if u, err := r.Handler(http.MethodGet, "/order/{no:int}", handle("order")).URL(Params{{Key: "no", Value: "7"}}); err != nil || u != "/order/7" {
+		t.Fatal(u, err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected conflict panic")
+		}
+	}()
+	r.Handler(http.MethodGet, "/user/:other", handle("other"))
+}
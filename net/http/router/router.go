@@ -40,9 +40,15 @@
 // The registered path, against which the router matches incoming requests, can
 // contain two types of parameters:
 //
-//	Syntax    Type
-//	:name     named parameter
-//	*name     catch-all parameter
+//	Syntax              Type
+//	:name               named parameter
+//	*name               catch-all parameter
+//	{name}              named parameter, typed if name is a registered type (e.g. {uuid})
+//	{name:int}          typed parameter, converted value stored in Param.Typed
+//	{name:[a-z-]+}      regexp constrained parameter
+//
+// Parameters with different constraints may share the same position, they are
+// tried in registration order, an unconstrained parameter is tried last.
 //
 // Named parameters are dynamic path segments. They match anything until the
 // next '/' or the path end:
@@ -93,6 +99,8 @@ import (
 type Param struct {
 	Key   string
 	Value string
+	// 带类型约束的参数转换后的值,如{id:int}为int,无约束或正则约束时为nil
+	Typed any
 }
 
 // Params is a Param-slice, as returned by the router.
@@ -145,6 +153,11 @@ type Router struct {
 	//前后调用
 	middleware httpi.HandlerFuncs
 
+	// 注册的路由,按注册顺序
+	routes []*Route
+	// 命名路由
+	names map[string]*Route
+
 	// If enabled, adds the matched route path onto the http.Request context
 	// before invoking the handler.
 	// The matched route path is only added to handlers of routes that were
@@ -243,7 +256,7 @@ func New() *Router {
 // This function is intended for bulk loading and to allow the usage of less
 // frequently used, non-standardized or custom methods (e.g. for internal
 // communication with a proxy).
-func (r *Router) Handle(method, path string, middleware []http.HandlerFunc, httpHandler http.Handler) {
+func (r *Router) Handle(method, path string, middleware []http.HandlerFunc, httpHandler http.Handler) *Route {
 	varsCount := uint16(0)
 
 	if method == "" {
@@ -262,6 +275,7 @@ func (r *Router) Handle(method, path string, middleware []http.HandlerFunc, http
 	}
 
 	r.trees.addRoute(path, &methodHandle{method, middleware, httpHandler})
+	route := r.addRouteInfo(method, path, httpHandler)
 
 	// Update maxParams
 	if paramsCount := countParams(path); paramsCount+varsCount > r.maxParams {
@@ -275,9 +289,10 @@ func (r *Router) Handle(method, path string, middleware []http.HandlerFunc, http
 			return &ps
 		}
 	}
+	return route
 }
 
-func (r *Router) Handler(method, path string, handle ...http.HandlerFunc) {
+func (r *Router) Handler(method, path string, handle ...http.HandlerFunc) *Route {
 	varsCount := uint16(0)
 
 	if method == "" {
@@ -300,6 +315,7 @@ func (r *Router) Handler(method, path string, handle ...http.HandlerFunc) {
 	}
 
 	r.trees.addRoute(path, &methodHandle{method, handle[:len(handle)-1], handle[len(handle)-1]})
+	route := r.addRouteInfo(method, path, handle[len(handle)-1])
 
 	// Update maxParams
 	if paramsCount := countParams(path); paramsCount+varsCount > r.maxParams {
@@ -313,6 +329,7 @@ func (r *Router) Handler(method, path string, handle ...http.HandlerFunc) {
 			return &ps
 		}
 	}
+	return route
 }
 
 // ServeFiles serves files from the given file system root.
@@ -403,7 +420,11 @@ func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 
 	if root := r.trees; root != nil {
 		r.middleware.ServeHTTP(w, req)
-		if middleware, handles, _, tsr := root.getValue(path, r.getParams); handles != nil {
+		if middleware, handles, ps, tsr := root.getValue(path, r.getParams); handles != nil {
+			if ps != nil {
+				req = req.WithContext(context.WithValue(req.Context(), ParamsKey, *ps))
+				defer r.putParams(ps)
+			}
 			mh := getHandle(req.Method, handles)
 			if mh.Valid() {
 				for i := range middleware {
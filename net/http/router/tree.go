@@ -6,6 +6,7 @@ package router
 
 import (
 	httpi "github.com/hopeio/gox/net/http"
+	"github.com/hopeio/gox/reflect/converter"
 	"net/http"
 	"reflect"
 	"sort"
@@ -27,9 +28,25 @@ func longestCommonPrefix(a, b string) int {
 
 // Search for a wildcard segment and check the name for invalid characters.
 // Returns -1 as index, if no wildcard was found.
+// {name:constraint}形式的参数以匹配的}结尾,必须占据整个路径段的剩余部分
 func findWildcard(path string) (wilcard string, i int, valid bool) {
 	// Find start
 	for start, c := range []byte(path) {
+		if c == '{' {
+			depth := 0
+			for end := start; end < len(path); end++ {
+				switch path[end] {
+				case '{':
+					depth++
+				case '}':
+					depth--
+				}
+				if depth == 0 {
+					return path[start : end+1], start, end+1 == len(path) || path[end+1] == '/'
+				}
+			}
+			return path[start:], start, false
+		}
 		// A wildcard starts with ':' (param) or '*' (catch-all)
 		if c != ':' && c != '*' {
 			continue
@@ -41,7 +58,7 @@ func findWildcard(path string) (wilcard string, i int, valid bool) {
 			switch c {
 			case '/':
 				return path[start : start+1+end], start, valid
-			case ':', '*':
+			case ':', '*', '{':
 				valid = false
 			}
 		}
@@ -54,7 +71,7 @@ func countParams(path string) uint16 {
 	var n uint
 	for i := range []byte(path) {
 		switch path[i] {
-		case ':', '*':
+		case ':', '*', '{':
 			n++
 		}
 	}
@@ -113,6 +130,10 @@ type node struct {
 	children   []*node
 	middleware httpi.HandlerFuncs
 	handle     []*methodHandle
+	// 参数名
+	key string
+	// 参数约束,为nil时匹配任意值
+	convert converter.StringConverterE
 }
 
 // Increments priority of the given child and reorders if necessary
@@ -186,6 +207,19 @@ walk:
 			path = path[i:]
 
 			if n.cType >= param {
+				// 约束不同的参数可以共存
+				if wildcard, i, valid := findWildcard(path); i == 0 && valid && n.cType&catchAll == 0 && wildcard[0] != '*' {
+					for _, child := range n.children {
+						if child.path == wildcard {
+							n = child
+							n.priority++
+							continue walk
+						}
+					}
+					if n.addParamChild(path, fullPath, mHandle) {
+						return
+					}
+				}
 				n = n.children[0]
 				n.priority++
 
@@ -284,7 +318,7 @@ func (n *node) insertChild(path, fullPath string, handle *methodHandle) {
 				"' conflicts with existing children in path '" + fullPath + "'")
 		}
 
-		if wildcard[0] == ':' { // param
+		if wildcard[0] == ':' || wildcard[0] == '{' { // param
 			if i > 0 {
 				// Insert prefix before the current wildcard
 				n.path = path[:i]
@@ -292,10 +326,7 @@ func (n *node) insertChild(path, fullPath string, handle *methodHandle) {
 			}
 
 			n.cType = n.cType | param
-			child := &node{
-				nType: param,
-				path:  wildcard,
-			}
+			child := newParamNode(wildcard, fullPath)
 			n.children = []*node{child}
 			n = child
 			n.priority++
@@ -340,6 +371,7 @@ func (n *node) insertChild(path, fullPath string, handle *methodHandle) {
 				path:     path[i:],
 				nType:    catchAll,
 				priority: 1,
+				key:      wildcard[1:],
 			}
 
 			if handle.Valid() {
@@ -363,6 +395,104 @@ func (n *node) insertChild(path, fullPath string, handle *methodHandle) {
 	}
 }
 
+// 依次尝试匹配path下一段的参数子节点,直到后续路径也能匹配
+func (n *node) getParamValue(path string, params func() *Params, middleware []http.HandlerFunc) ([]http.HandlerFunc, []*methodHandle, *Params, *node) {
+	end := 0
+	for end < len(path) && path[end] != '/' {
+		end++
+	}
+	var ps *Params
+	var tsr *node
+	getParams := params
+	if params != nil {
+		getParams = func() *Params {
+			if ps == nil {
+				ps = params()
+			}
+			return ps
+		}
+	}
+	for _, child := range n.children {
+		typed, ok := child.matchParam(path[:end])
+		if !ok {
+			continue
+		}
+		var i int
+		if params != nil {
+			getParams()
+			i = len(*ps)
+			*ps = (*ps)[:i+1]
+			(*ps)[i] = Param{Key: child.key, Value: path[:end], Typed: typed}
+		}
+		if end == len(path) {
+			if child.handle != nil {
+				return middleware, child.handle, ps, nil
+			}
+			if len(child.children) == 1 {
+				tsr = child.children[0]
+			}
+		} else if len(child.children) > 0 {
+			mw, handles, _, childTsr := child.children[0].getValue(path[end:], getParams)
+			if handles != nil {
+				return append(middleware, mw...), handles, ps, nil
+			}
+			if tsr == nil {
+				tsr = childTsr
+			}
+		}
+		if params != nil {
+			*ps = (*ps)[:i]
+		}
+	}
+	return middleware, nil, ps, tsr
+}
+
+func (n *node) matchParam(value string) (any, bool) {
+	if n.convert == nil {
+		return nil, true
+	}
+	v, err := n.convert(value)
+	return v, err == nil
+}
+
+// 按约束查找匹配path下一段的通配子节点,返回转换后的值,没有匹配时返回nil
+func (n *node) wildChild(path string) (*node, any) {
+	if n.cType&catchAll != 0 {
+		return n.children[0], nil
+	}
+	end := 0
+	for end < len(path) && path[end] != '/' {
+		end++
+	}
+	for _, child := range n.children {
+		if v, ok := child.matchParam(path[:end]); ok {
+			return child, v
+		}
+	}
+	return nil, nil
+}
+
+// 添加约束不同的参数子节点,有约束的在前,无约束的在最后匹配,不能添加时返回false
+func (n *node) addParamChild(path, fullPath string, mHandle *methodHandle) bool {
+	holder := &node{}
+	holder.insertChild(path, fullPath, mHandle)
+	child := holder.children[0]
+	last := n.children[len(n.children)-1]
+	if child.convert == nil {
+		if last.convert == nil {
+			return false
+		}
+		n.children = append(n.children, child)
+		return true
+	}
+	if last.convert == nil {
+		n.children = append(n.children[:len(n.children)-1], child, last)
+	} else {
+		n.children = append(n.children, child)
+	}
+	return true
+}
+
 func (n *node) use(path string, middleware ...http.HandlerFunc) {
 	n.addRoute(path, &methodHandle{middleware: middleware})
 }
@@ -417,7 +547,14 @@ walk: // Outer loop for walking the tree
 				if n.middleware != nil {
 					middleware = append(middleware, n.middleware...)
 				}
-				n = n.children[0]
+				// 多个约束不同的参数时按顺序回溯匹配
+				if n.cType&catchAll == 0 && len(n.children) > 1 {
+					return n.getParamValue(path, params, middleware)
+				}
+				var typed any
+				if n, typed = n.wildChild(path); n == nil {
+					return
+				}
 				switch n.nType {
 				case param:
 					// Find param end (either '/' or path end)
@@ -435,8 +572,9 @@ walk: // Outer loop for walking the tree
 						i := len(*ps)
 						*ps = (*ps)[:i+1]
 						(*ps)[i] = Param{
-							Key:   n.path[1:],
+							Key:   n.key,
 							Value: path[:end],
+							Typed: typed,
 						}
 					}
 
@@ -473,7 +611,7 @@ walk: // Outer loop for walking the tree
 						i := len(*ps)
 						*ps = (*ps)[:i+1]
 						(*ps)[i] = Param{
-							Key:   n.path[2:],
+							Key:   n.key,
 							Value: path,
 						}
 					}
@@ -665,7 +803,9 @@ walk: // Outer loop for walking the tree
 				return nil
 			}
 
-			n = n.children[0]
+			if n, _ = n.wildChild(path); n == nil {
+				return nil
+			}
 			switch n.nType {
 			case param:
 				// Find param end (either '/' or path end)
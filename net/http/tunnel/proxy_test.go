@@ -0,0 +1,116 @@
+package tunnel
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/hopeio/gox/net/http/consts"
+)
+
+func proxyClient(proxyURL string, header http.Header) *http.Client {
+	u, _ := url.Parse(proxyURL)
+	return &http.Client{Transport: &http.Transport{
+		Proxy:              http.ProxyURL(u),
+		ProxyConnectHeader: header,
+		TLSClientConfig:    &tls.Config{InsecureSkipVerify: true},
+	}}
+}
+
+func fetch(t *testing.T, c *http.Client, target string) (int, string) {
+	resp, err := c.Get(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(body)
+}
+
+func TestProxy(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "hello "+r.Header.Get(consts.HeaderProxyAuthorization))
+	}))
+	defer target.Close()
+	tlsTarget := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "secure")
+	}))
+	defer tlsTarget.Close()
+
+	p, err := New(&Config{Users: map[string]string{"alice": "secret"}, Tokens: []string{"tok"}, Deny: []string{"*.internal"}, DenyPorts: []string{"22"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	srv := httptest.NewServer(p)
+	defer srv.Close()
+
+	// 未认证
+	if code, _ := fetch(t, proxyClient(srv.URL, nil), target.URL); code != http.StatusProxyAuthRequired {
+		t.Fatal(code)
+	}
+	authed := proxyClient("http://alice:secret@"+srv.Listener.Addr().String(), nil)
+	code, body := fetch(t, authed, target.URL)
+	if code != http.StatusOK || body != "hello " {
+		t.Fatal(code, body)
+	}
+	if code, _ = fetch(t, authed, "http://db.internal/"); code != http.StatusForbidden {
+		t.Fatal(code)
+	}
+	if code, _ = fetch(t, authed, "http://127.0.0.1:22/"); code != http.StatusForbidden {
+		t.Fatal(code)
+	}
+
+	// CONNECT,token认证
+	tokenClient := proxyClient(srv.URL, http.Header{consts.HeaderProxyAuthorization: {"Bearer tok"}})
+	if code, body = fetch(t, tokenClient, tlsTarget.URL); code != http.StatusOK || body != "secure" {
+		t.Fatal(code, body)
+	}
+
+	stats := p.Stats()
+	if s := stats["alice"]; s.Requests != 1 || s.BytesOut != int64(len("hello ")) {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+	if s := stats["token:0"]; s.Requests != 1 || s.BytesIn == 0 || s.BytesOut == 0 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+
+	// 空闲超时的客户端被清除,进行中的保留
+	busy := p.client("busy")
+	idle := p.client("idle")
+	idle.done()
+	now := time.Now().Add(time.Hour).UnixNano()
+	p.sweep(now)
+	stats = p.Stats()
+	if _, ok := stats["busy"]; !ok {
+		t.Fatalf("busy client removed: %+v", stats)
+	}
+	if _, ok := stats["idle"]; ok {
+		t.Fatalf("idle client not removed: %+v", stats)
+	}
+	busy.done()
+
+	// 上游代理链
+	chained, err := New(&Config{Upstream: "http://alice:secret@" + srv.Listener.Addr().String(), Allow: []string{"127.0.0.?"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer chained.Close()
+	chainedSrv := httptest.NewServer(chained)
+	defer chainedSrv.Close()
+	c := proxyClient(chainedSrv.URL, nil)
+	if code, body = fetch(t, c, tlsTarget.URL); code != http.StatusOK || body != "secure" {
+		t.Fatal(code, body)
+	}
+	if code, body = fetch(t, c, target.URL); code != http.StatusOK || body != "hello " {
+		t.Fatal(code, body)
+	}
+	if code, _ = fetch(t, c, "http://localhost:1/"); code != http.StatusForbidden {
+		t.Fatal(code)
+	}
+}
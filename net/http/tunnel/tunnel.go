@@ -7,6 +7,7 @@
 package tunnel
 
 import (
+	"errors"
 	"github.com/hopeio/gox/log"
 	"io"
 	"net"
@@ -14,22 +15,28 @@ import (
 	"time"
 )
 
+// Tunneling 不带认证及访问控制的CONNECT隧道,需要这些功能时使用Proxy
 func Tunneling(w http.ResponseWriter, r *http.Request) {
-	dest_conn, err := net.DialTimeout("tcp", r.Host, 10*time.Second)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusServiceUnavailable)
-		return
-	}
-	w.WriteHeader(http.StatusOK)
-	io.WriteString(w, "HTTP/1.1 200 Connection Established\r\n\r\n")
 	hijacker, ok := w.(http.Hijacker)
 	if !ok {
 		http.Error(w, "Hijacking not supported", http.StatusInternalServerError)
 		return
 	}
+	dest_conn, err := net.DialTimeout("tcp", r.Host, 10*time.Second)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
 	client_conn, _, err := hijacker.Hijack()
 	if err != nil {
+		dest_conn.Close()
 		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	if _, err = io.WriteString(client_conn, "HTTP/1.1 200 Connection Established\r\n\r\n"); err != nil {
+		dest_conn.Close()
+		client_conn.Close()
+		return
 	}
 	go transfer(dest_conn, client_conn)
 	go transfer(client_conn, dest_conn)
@@ -37,7 +44,7 @@ func Tunneling(w http.ResponseWriter, r *http.Request) {
 func transfer(destination io.WriteCloser, source io.ReadCloser) {
 	defer destination.Close()
 	defer source.Close()
-	if _, err := io.Copy(destination, source); err != nil {
-		log.Error("transfer failed: %v", err)
+	if _, err := io.Copy(destination, source); err != nil && !errors.Is(err, net.ErrClosed) {
+		log.Errorf("transfer failed: %v", err)
 	}
 }
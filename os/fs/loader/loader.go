@@ -201,7 +201,7 @@ func (ld *Loader) watchNotify(handle func(reader io.Reader) error) {
 }
 
 func (ld *Loader) watchTimer(handle func(reader io.Reader) error) {
-	var fileModTimes map[string]time.Time
+	fileModTimes := make(map[string]time.Time)
 	for i := range ld.Paths {
 		file := ld.Paths[i]
 
@@ -220,7 +220,7 @@ func (ld *Loader) watchTimer(handle func(reader io.Reader) error) {
 				if fileInfo.ModTime().After(fileModTimes[file]) {
 					fileModTimes[file] = fileInfo.ModTime()
 					if err := loads(handle, file); err != nil {
-						log.Error("failed to reload data from %v, got error %v\n", ld.Paths, err)
+						log.Errorf("failed to reload data from %v, got error %v\n", ld.Paths, err)
 					}
 				}
 			}
@@ -0,0 +1,375 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package crawler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/hopeio/gox/log"
+	"github.com/hopeio/gox/net/http/client"
+	"github.com/hopeio/gox/net/http/consts"
+	"github.com/hopeio/gox/scheduler/engine"
+	"github.com/hopeio/gox/scheduler/retry"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const sitemapKeyPrefix = "sitemap:"
+
+// Page 抓取到的页面
+type Page struct {
+	URL        *url.URL
+	Depth      int
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// LinkExtractor 从页面中提取链接,返回的链接可以是相对地址
+type LinkExtractor func(page *Page) ([]string, error)
+
+// PageHandler 处理抓取到的页面,返回错误时不再提取该页面的链接
+type PageHandler func(ctx context.Context, page *Page) error
+
+type CrawlerConfig struct {
+	UserAgent string
+	// 最大深度,种子url深度为0,为0不限制
+	MaxDepth int
+	// 同一host的最大并发数,默认1
+	HostConcurrency int
+	// 同一host两次请求的最小间隔,robots.txt中的Crawl-delay更大时使用Crawl-delay
+	HostDelay time.Duration
+	// 不遵守robots.txt
+	IgnoreRobots bool
+	// 从robots.txt的Sitemap或/sitemap.xml发现url,发现的url深度为0
+	Sitemap bool
+	// 只抓取种子url所在的host
+	SameHost bool
+	// 页面最大字节数,默认10M
+	MaxBodySize int64
+	Kind        engine.Kind
+}
+
+type host struct {
+	sem        chan struct{}
+	mu         sync.Mutex
+	next       time.Time
+	robotsOnce sync.Once
+	robots     *Robots
+}
+
+// Crawler 基于Engine的爬虫,负责url去重,robots.txt,按host限流,深度限制及sitemap发现
+type Crawler struct {
+	conf      CrawlerConfig
+	engine    *Engine
+	client    *client.Client
+	extractor LinkExtractor
+	handler   PageHandler
+	filter    func(u *url.URL) bool
+
+	mu        sync.Mutex
+	seen      map[string]struct{}
+	hosts     map[string]*host
+	seedHosts map[string]struct{}
+}
+
+func NewCrawler(engine *Engine, conf CrawlerConfig) *Crawler {
+	if conf.HostConcurrency <= 0 {
+		conf.HostConcurrency = 1
+	}
+	if conf.MaxBodySize <= 0 {
+		conf.MaxBodySize = 10 << 20
+	}
+	return &Crawler{
+		conf:      conf,
+		engine:    engine,
+		client:    client.New(),
+		extractor: HTMLLinkExtractor,
+		seen:      make(map[string]struct{}),
+		hosts:     make(map[string]*host),
+		seedHosts: make(map[string]struct{}),
+	}
+}
+
+func (c *Crawler) Client(client *client.Client) *Crawler {
+	c.client = client
+	return c
+}
+
+// LinkExtractor 设置链接提取,默认为HTMLLinkExtractor,为nil时不提取链接
+func (c *Crawler) LinkExtractor(extractor LinkExtractor) *Crawler {
+	c.extractor = extractor
+	return c
+}
+
+func (c *Crawler) OnPage(handler PageHandler) *Crawler {
+	c.handler = handler
+	return c
+}
+
+// Filter 返回false的url不会被抓取
+func (c *Crawler) Filter(filter func(u *url.URL) bool) *Crawler {
+	c.filter = filter
+	return c
+}
+
+// Run 从种子url开始抓取,直到没有新的url
+func (c *Crawler) Run(urls ...string) {
+	c.engine.Run(c.Requests(urls...)...)
+}
+
+// Requests 将种子url转换为任务,可用于加入已运行的Engine
+func (c *Crawler) Requests(urls ...string) []*Request {
+	var reqs []*Request
+	for _, rawURL := range urls {
+		u, err := url.Parse(rawURL)
+		if err == nil {
+			err = normalize(u)
+		}
+		if err != nil {
+			log.Warnf("crawler invalid url %s: %v", rawURL, err)
+			continue
+		}
+		c.mu.Lock()
+		c.seedHosts[u.Host] = struct{}{}
+		c.mu.Unlock()
+		if req := c.pageRequest(u, 0); req != nil {
+			reqs = append(reqs, req)
+		}
+	}
+	return reqs
+}
+
+// markSeen 返回key是否第一次出现
+func (c *Crawler) markSeen(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.seen[key]; ok {
+		return false
+	}
+	c.seen[key] = struct{}{}
+	return true
+}
+
+func (c *Crawler) allowed(u *url.URL) bool {
+	if c.conf.SameHost {
+		c.mu.Lock()
+		_, ok := c.seedHosts[u.Host]
+		c.mu.Unlock()
+		if !ok {
+			return false
+		}
+	}
+	return c.filter == nil || c.filter(u)
+}
+
+func (c *Crawler) pageRequest(u *url.URL, depth int) *Request {
+	key := u.String()
+	if !c.allowed(u) || !c.markSeen(key) {
+		return nil
+	}
+	return NewRequest(key, c.conf.Kind, func(ctx context.Context) ([]*Request, error) {
+		return c.crawl(ctx, u, depth)
+	})
+}
+
+func (c *Crawler) sitemapRequest(u *url.URL) *Request {
+	key := sitemapKeyPrefix + u.String()
+	if !c.markSeen(key) {
+		return nil
+	}
+	return NewRequest(key, c.conf.Kind, func(ctx context.Context) ([]*Request, error) {
+		return c.crawlSitemap(ctx, u)
+	})
+}
+
+// host 获取host状态,第一次访问时加载robots.txt,isNew表示是否第一次访问
+func (c *Crawler) host(ctx context.Context, u *url.URL) (h *host, isNew bool) {
+	c.mu.Lock()
+	h, ok := c.hosts[u.Host]
+	if !ok {
+		h = &host{sem: make(chan struct{}, c.conf.HostConcurrency)}
+		c.hosts[u.Host] = h
+	}
+	c.mu.Unlock()
+	h.robotsOnce.Do(func() {
+		if c.conf.IgnoreRobots {
+			return
+		}
+		robotsURL := &url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/robots.txt"}
+		body, _, err := c.fetch(ctx, h, robotsURL)
+		if err != nil {
+			if !errors.Is(err, client.ErrNotFound) {
+				log.Warnf("crawler get %s error: %v", robotsURL, err)
+			}
+			return
+		}
+		robots, err := ParseRobots(bytes.NewReader(body), c.conf.UserAgent)
+		if err != nil {
+			log.Warnf("crawler parse %s error: %v", robotsURL, err)
+			return
+		}
+		h.robots = robots
+	})
+	return h, !ok
+}
+
+// acquire 占用host的并发数并等待到允许请求的时间
+func (c *Crawler) acquire(ctx context.Context, h *host) error {
+	select {
+	case h.sem <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	delay := c.conf.HostDelay
+	if h.robots != nil && h.robots.CrawlDelay > delay {
+		delay = h.robots.CrawlDelay
+	}
+	now := time.Now()
+	h.mu.Lock()
+	at := h.next
+	if at.Before(now) {
+		at = now
+	}
+	h.next = at.Add(delay)
+	h.mu.Unlock()
+	if err := retry.Sleep(ctx, at.Sub(now)); err != nil {
+		<-h.sem
+		return err
+	}
+	return nil
+}
+
+func (c *Crawler) fetch(ctx context.Context, h *host, u *url.URL) ([]byte, *http.Response, error) {
+	if err := c.acquire(ctx, h); err != nil {
+		return nil, nil, err
+	}
+	defer func() { <-h.sem }()
+	req := c.client.Request(http.MethodGet, u.String()).Context(ctx)
+	if c.conf.UserAgent != "" {
+		req.AddHeader(consts.HeaderUserAgent, c.conf.UserAgent)
+	}
+	var resp *http.Response
+	if err := req.Do(nil, &resp); err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, c.conf.MaxBodySize))
+	return body, resp, err
+}
+
+func (c *Crawler) crawl(ctx context.Context, u *url.URL, depth int) ([]*Request, error) {
+	h, isNew := c.host(ctx, u)
+	// sitemap直接加入引擎,不依赖页面的抓取结果,出错时引擎会丢弃返回的任务,而这些url已标记为已抓取
+	if isNew && c.conf.Sitemap {
+		if reqs := c.discoverSitemaps(h, u); len(reqs) > 0 {
+			c.engine.AddTasks(reqs...)
+		}
+	}
+	if !c.conf.IgnoreRobots && !h.robots.Allowed(u.RequestURI()) {
+		log.Debugf("crawler %s disallowed by robots.txt", u)
+		return nil, nil
+	}
+	body, resp, err := c.fetch(ctx, h, u)
+	if err != nil {
+		if errors.Is(err, client.ErrNotFound) {
+			log.Debugf("crawler %s not found", u)
+			return nil, nil
+		}
+		return nil, err
+	}
+	page := &Page{URL: u, Depth: depth, StatusCode: resp.StatusCode, Header: resp.Header, Body: body}
+	if c.handler != nil {
+		if err = c.handler(ctx, page); err != nil {
+			return nil, err
+		}
+	}
+	if c.extractor == nil || (c.conf.MaxDepth > 0 && depth >= c.conf.MaxDepth) {
+		return nil, nil
+	}
+	links, err := c.extractor(page)
+	if err != nil {
+		return nil, err
+	}
+	var reqs []*Request
+	for _, link := range links {
+		linkURL, err := ResolveURL(u, link)
+		if err != nil {
+			continue
+		}
+		if req := c.pageRequest(linkURL, depth+1); req != nil {
+			reqs = append(reqs, req)
+		}
+	}
+	return reqs, nil
+}
+
+func (c *Crawler) discoverSitemaps(h *host, u *url.URL) []*Request {
+	var sitemaps []string
+	if h.robots != nil {
+		sitemaps = h.robots.Sitemaps
+	}
+	if len(sitemaps) == 0 {
+		sitemaps = []string{u.Scheme + "://" + u.Host + "/sitemap.xml"}
+	}
+	var reqs []*Request
+	for _, sitemap := range sitemaps {
+		sitemapURL, err := ResolveURL(u, sitemap)
+		if err != nil {
+			continue
+		}
+		if req := c.sitemapRequest(sitemapURL); req != nil {
+			reqs = append(reqs, req)
+		}
+	}
+	return reqs
+}
+
+func (c *Crawler) crawlSitemap(ctx context.Context, u *url.URL) ([]*Request, error) {
+	h, _ := c.host(ctx, u)
+	body, _, err := c.fetch(ctx, h, u)
+	if err != nil {
+		if errors.Is(err, client.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	sitemap, err := ParseSitemap(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	var reqs []*Request
+	for _, loc := range sitemap.Sitemaps {
+		if sitemapURL, err := ResolveURL(u, loc); err == nil {
+			if req := c.sitemapRequest(sitemapURL); req != nil {
+				reqs = append(reqs, req)
+			}
+		}
+	}
+	for _, loc := range sitemap.URLs {
+		if pageURL, err := ResolveURL(u, loc); err == nil {
+			if req := c.pageRequest(pageURL, 0); req != nil {
+				reqs = append(reqs, req)
+			}
+		}
+	}
+	return reqs, nil
+}
+
+// HTMLLinkExtractor 提取html页面中a及area标签的href,支持base标签
+func HTMLLinkExtractor(page *Page) ([]string, error) {
+	contentType := page.Header.Get(consts.HeaderContentType)
+	if contentType != "" && !strings.Contains(contentType, "html") {
+		return nil, nil
+	}
+	return extractLinks(page.URL, bytes.NewReader(page.Body))
+}
@@ -0,0 +1,167 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/hopeio/gox/scheduler/engine"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestNormalizeURL(t *testing.T) {
+	cases := map[string]string{
+		"HTTP://Example.COM:80/a/./b/../c?b=2&a=1#frag": "http://example.com/a/c?a=1&b=2",
+		"https://example.com:443":                       "https://example.com/",
+		"https://example.com/dir/":                      "https://example.com/dir/",
+		"http://[::1]:8080/":                            "http://[::1]:8080/",
+		"http://[::1]:80/a":                             "http://[::1]/a",
+		"HTTP://[FE80::1]":                              "http://[fe80::1]/",
+	}
+	for raw, expected := range cases {
+		if u, err := NormalizeURL(raw); err != nil || u != expected {
+			t.Errorf("normalize %s: %s %v", raw, u, err)
+		}
+	}
+	if _, err := NormalizeURL("mailto:a@example.com"); err == nil {
+		t.Error("mailto should be unsupported")
+	}
+}
+
+func TestRobots(t *testing.T) {
+	robots, err := ParseRobots(strings.NewReader(`
+User-agent: other
+Disallow: /
+
+User-agent: *
+Disallow: /private
+Allow: /private/ok
+Disallow: /*.pdf$
+Crawl-delay: 0.5
+
+Sitemap: http://example.com/sitemap.xml
+`), "gox-crawler")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for path, allowed := range map[string]bool{"/": true, "/private/x": false, "/private/ok/1": true, "/a.pdf": false, "/a.pdf?x": true} {
+		if robots.Allowed(path) != allowed {
+			t.Errorf("%s allowed should be %v", path, allowed)
+		}
+	}
+	if robots.CrawlDelay != 500*time.Millisecond || len(robots.Sitemaps) != 1 {
+		t.Fatalf("unexpected robots: %+v", robots)
+	}
+}
+
+func TestCrawler(t *testing.T) {
+	var srv *httptest.Server
+	pages := map[string]string{
+		"/":           `<a href="/a">a</a><a href="/a#frag">a</a><a href="/a?y=2&x=1">q</a><a href="/private/x">x</a><a href="/private/ok">ok</a><a href="http://other.example/">other</a>`,
+		"/a":          `<a href="b">b</a><a href="/">home</a>`,
+		"/b":          `<a href="/c">c</a>`,
+		"/c":          ``,
+		"/private/x":  ``,
+		"/private/ok": ``,
+		"/hidden":     ``,
+	}
+	var running, maxRunning int32
+	var mu sync.Mutex
+	var visited []string
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/robots.txt":
+			fmt.Fprintf(w, "User-agent: *\nDisallow: /private\nAllow: /private/ok\nSitemap: %s/sitemap.xml\n", srv.URL)
+			return
+		case "/sitemap.xml":
+			fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?><urlset><url><loc>%s/hidden</loc></url></urlset>`, srv.URL)
+			return
+		}
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		if n > atomic.LoadInt32(&maxRunning) {
+			atomic.StoreInt32(&maxRunning, n)
+		}
+		page, ok := pages[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		mu.Lock()
+		visited = append(visited, r.URL.RequestURI())
+		mu.Unlock()
+		time.Sleep(5 * time.Millisecond)
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(page))
+	}))
+	defer srv.Close()
+
+	crawler := NewCrawler(engine.NewConfig(engine.WithWorkerCount[string](4), engine.WithMonitorInterval[string](100*time.Millisecond)).NewEngine(), CrawlerConfig{
+		UserAgent: "gox-crawler",
+		MaxDepth:  2,
+		HostDelay: 5 * time.Millisecond,
+		Sitemap:   true,
+		SameHost:  true,
+	})
+	var handled int32
+	crawler.OnPage(func(ctx context.Context, page *Page) error {
+		atomic.AddInt32(&handled, 1)
+		return nil
+	})
+	crawler.Run(srv.URL)
+
+	slices.Sort(visited)
+	expected := []string{"/", "/a", "/a?x=1&y=2", "/b", "/hidden", "/private/ok"}
+	if !slices.Equal(visited, expected) {
+		t.Fatalf("unexpected visited: %v", visited)
+	}
+	if int(handled) != len(expected) {
+		t.Fatalf("unexpected handled: %d", handled)
+	}
+	if maxRunning > 1 {
+		t.Fatalf("host concurrency exceeded: %d", maxRunning)
+	}
+}
+
+// 页面处理出错时,首次访问host发现的sitemap不应丢失
+func TestCrawlerSitemapOnError(t *testing.T) {
+	var srv *httptest.Server
+	var hidden int32
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/robots.txt":
+			http.NotFound(w, r)
+		case "/sitemap.xml":
+			fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?><urlset><url><loc>%s/hidden</loc></url></urlset>`, srv.URL)
+		case "/hidden":
+			atomic.AddInt32(&hidden, 1)
+		}
+	}))
+	defer srv.Close()
+
+	crawler := NewCrawler(engine.NewConfig(engine.WithWorkerCount[string](2), engine.WithMonitorInterval[string](100*time.Millisecond)).NewEngine(), CrawlerConfig{
+		Sitemap: true,
+	})
+	crawler.OnPage(func(ctx context.Context, page *Page) error {
+		if page.URL.Path == "/" {
+			return errors.New("handle failed")
+		}
+		return nil
+	})
+	crawler.Run(srv.URL)
+	if atomic.LoadInt32(&hidden) != 1 {
+		t.Fatalf("sitemap url visited %d times", hidden)
+	}
+}
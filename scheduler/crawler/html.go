@@ -0,0 +1,53 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package crawler
+
+import (
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"io"
+	"net/url"
+	"strings"
+)
+
+func extractLinks(base *url.URL, r io.Reader) ([]string, error) {
+	var links []string
+	tokenizer := html.NewTokenizer(r)
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			if err := tokenizer.Err(); err != io.EOF {
+				return links, err
+			}
+			return links, nil
+		case html.StartTagToken, html.SelfClosingTagToken:
+			token := tokenizer.Token()
+			if token.DataAtom != atom.A && token.DataAtom != atom.Area && token.DataAtom != atom.Base {
+				continue
+			}
+			for _, attr := range token.Attr {
+				if attr.Key != "href" {
+					continue
+				}
+				href := strings.TrimSpace(attr.Val)
+				if href == "" || strings.HasPrefix(href, "#") {
+					break
+				}
+				if token.DataAtom == atom.Base {
+					if u, err := base.Parse(href); err == nil {
+						base = u
+					}
+					break
+				}
+				if u, err := base.Parse(href); err == nil {
+					links = append(links, u.String())
+				}
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,155 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package crawler
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type robotsRule struct {
+	allow   bool
+	pattern string
+}
+
+// Robots robots.txt中对某个User-agent生效的规则
+type Robots struct {
+	rules      []robotsRule
+	CrawlDelay time.Duration
+	Sitemaps   []string
+}
+
+type robotsGroup struct {
+	agents     []string
+	rules      []robotsRule
+	crawlDelay time.Duration
+}
+
+// ParseRobots 解析robots.txt,选取与userAgent最匹配的组,没有则使用*组
+func ParseRobots(r io.Reader, userAgent string) (*Robots, error) {
+	robots := &Robots{}
+	var groups []*robotsGroup
+	var group *robotsGroup
+	// 连续的User-agent属于同一组
+	lastAgent := false
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+		switch key {
+		case "user-agent":
+			if !lastAgent || group == nil {
+				group = &robotsGroup{}
+				groups = append(groups, group)
+			}
+			group.agents = append(group.agents, strings.ToLower(value))
+			lastAgent = true
+			continue
+		case "allow", "disallow":
+			// 空的Disallow表示允许所有
+			if group != nil && value != "" {
+				group.rules = append(group.rules, robotsRule{allow: key == "allow", pattern: value})
+			}
+		case "crawl-delay":
+			if group != nil {
+				if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+					group.crawlDelay = time.Duration(seconds * float64(time.Second))
+				}
+			}
+		case "sitemap":
+			// Sitemap不属于任何组
+			robots.Sitemaps = append(robots.Sitemaps, value)
+		}
+		lastAgent = false
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	userAgent = strings.ToLower(userAgent)
+	var matched, wildcard *robotsGroup
+	matchedLen := 0
+	for _, g := range groups {
+		for _, agent := range g.agents {
+			if agent == "*" {
+				if wildcard == nil {
+					wildcard = g
+				}
+				continue
+			}
+			if strings.Contains(userAgent, agent) && len(agent) > matchedLen {
+				matched, matchedLen = g, len(agent)
+			}
+		}
+	}
+	if matched == nil {
+		matched = wildcard
+	}
+	if matched != nil {
+		robots.rules = matched.rules
+		robots.CrawlDelay = matched.crawlDelay
+	}
+	return robots, nil
+}
+
+// Allowed path(包含query)是否允许抓取,最长匹配的规则生效,长度相同时Allow优先
+func (r *Robots) Allowed(path string) bool {
+	if r == nil {
+		return true
+	}
+	if path == "" {
+		path = "/"
+	}
+	allow := true
+	matchedLen := -1
+	for _, rule := range r.rules {
+		if !matchRobotsPattern(rule.pattern, path) {
+			continue
+		}
+		if len(rule.pattern) > matchedLen || (len(rule.pattern) == matchedLen && rule.allow) {
+			allow, matchedLen = rule.allow, len(rule.pattern)
+		}
+	}
+	return allow
+}
+
+// matchRobotsPattern 支持*匹配任意字符及$匹配结尾
+func matchRobotsPattern(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	if anchored {
+		pattern = pattern[:len(pattern)-1]
+	}
+	parts := strings.Split(pattern, "*")
+	// 第一段必须是前缀
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	pos := len(parts[0])
+	for i := 1; i < len(parts); i++ {
+		part := parts[i]
+		if i == len(parts)-1 && anchored {
+			return strings.HasSuffix(path[pos:], part)
+		}
+		idx := strings.Index(path[pos:], part)
+		if idx < 0 {
+			return false
+		}
+		pos += idx + len(part)
+	}
+	return !anchored || pos == len(path)
+}
@@ -0,0 +1,49 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package crawler
+
+import (
+	"encoding/xml"
+	"io"
+	"strings"
+)
+
+// Sitemap 解析后的sitemap,索引文件的子sitemap在Sitemaps中
+type Sitemap struct {
+	URLs     []string
+	Sitemaps []string
+}
+
+type sitemapXML struct {
+	XMLName xml.Name
+	URLs    []struct {
+		Loc string `xml:"loc"`
+	} `xml:"url"`
+	Sitemaps []struct {
+		Loc string `xml:"loc"`
+	} `xml:"sitemap"`
+}
+
+// ParseSitemap 解析urlset或sitemapindex格式的sitemap
+func ParseSitemap(r io.Reader) (*Sitemap, error) {
+	var doc sitemapXML
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, err
+	}
+	sitemap := &Sitemap{}
+	for _, u := range doc.URLs {
+		if loc := strings.TrimSpace(u.Loc); loc != "" {
+			sitemap.URLs = append(sitemap.URLs, loc)
+		}
+	}
+	for _, s := range doc.Sitemaps {
+		if loc := strings.TrimSpace(s.Loc); loc != "" {
+			sitemap.Sitemaps = append(sitemap.Sitemaps, loc)
+		}
+	}
+	return sitemap, nil
+}
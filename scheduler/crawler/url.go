@@ -0,0 +1,97 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package crawler
+
+import (
+	"errors"
+	"net"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+)
+
+var ErrUnsupportedScheme = errors.New("unsupported url scheme")
+
+// NormalizeURL 规范化url用于去重:scheme及host小写,去掉默认端口,fragment,解析路径中的./..,query按key排序
+func NormalizeURL(rawURL string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return "", err
+	}
+	if err = normalize(u); err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+// ResolveURL 将页面中的链接解析为相对base的绝对地址并规范化
+func ResolveURL(base *url.URL, href string) (*url.URL, error) {
+	ref, err := url.Parse(strings.TrimSpace(href))
+	if err != nil {
+		return nil, err
+	}
+	u := base.ResolveReference(ref)
+	if err = normalize(u); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+func normalize(u *url.URL) error {
+	u.Scheme = strings.ToLower(u.Scheme)
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return ErrUnsupportedScheme
+	}
+	host := strings.ToLower(u.Hostname())
+	port := u.Port()
+	if (u.Scheme == "http" && port == "80") || (u.Scheme == "https" && port == "443") {
+		port = ""
+	}
+	if port != "" {
+		host = net.JoinHostPort(host, port)
+	} else if strings.Contains(host, ":") {
+		// IPv6
+		host = "[" + host + "]"
+	}
+	u.Host = host
+	u.Fragment = ""
+	u.RawFragment = ""
+	u.User = nil
+	if u.Path == "" {
+		u.Path = "/"
+	} else {
+		cleaned := path.Clean(u.Path)
+		if strings.HasSuffix(u.Path, "/") && cleaned != "/" {
+			cleaned += "/"
+		}
+		u.Path = cleaned
+	}
+	u.RawPath = ""
+	if u.RawQuery != "" {
+		query := u.Query()
+		keys := make([]string, 0, len(query))
+		for k := range query {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		var builder strings.Builder
+		for _, k := range keys {
+			for _, v := range query[k] {
+				if builder.Len() > 0 {
+					builder.WriteByte('&')
+				}
+				builder.WriteString(url.QueryEscape(k))
+				builder.WriteByte('=')
+				builder.WriteString(url.QueryEscape(v))
+			}
+		}
+		u.RawQuery = builder.String()
+	}
+	u.ForceQuery = false
+	return nil
+}
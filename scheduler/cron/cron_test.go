@@ -0,0 +1,120 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package cron
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+func TestCronLocker(t *testing.T) {
+	locker := NewMemoryLocker()
+	var runs int32
+	job := func(ctx context.Context) error {
+		atomic.AddInt32(&runs, 1)
+		return nil
+	}
+	// 模拟两个副本
+	replicas := []*Cron{NewCron(WithLocker(locker)), NewCron(WithLocker(locker))}
+	for _, c := range replicas {
+		if err := c.AddJob("job", "* * * * * *", job); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.AddJob("job", "* * * * * *", job); err == nil {
+			t.Fatal("duplicate job added")
+		}
+		c.Start()
+	}
+	time.Sleep(2500 * time.Millisecond)
+	for _, c := range replicas {
+		<-c.Stop().Done()
+	}
+	n := atomic.LoadInt32(&runs)
+	if n < 2 || n > 3 {
+		t.Fatalf("unexpected runs: %d", n)
+	}
+}
+
+// 两个副本的触发相差约1s,跨越秒边界,仍应使用同一把锁
+func TestCronLockerClockSkew(t *testing.T) {
+	locker := NewMemoryLocker()
+	job := func(ctx context.Context) error { return nil }
+	a, b := NewCron(WithLocker(locker)), NewCron(WithLocker(locker))
+	b.cron = cron.New(cron.WithLocation(b.location), cron.WithSeconds(), cron.WithChain(func(j cron.Job) cron.Job {
+		return cron.FuncJob(func() {
+			time.Sleep(1100 * time.Millisecond)
+			j.Run()
+		})
+	}))
+	for _, c := range []*Cron{a, b} {
+		if err := c.AddJob("job", "*/2 * * * * *", job); err != nil {
+			t.Fatal(err)
+		}
+		c.Start()
+	}
+	time.Sleep(4500 * time.Millisecond)
+	<-a.Stop().Done()
+	<-b.Stop().Done()
+
+	// b总是晚于a触发,同一次调度的锁已被a获取
+	if len(a.Jobs(context.Background())[0].History) == 0 {
+		t.Fatal("job not run")
+	}
+	if history := b.Jobs(context.Background())[0].History; len(history) > 0 {
+		t.Fatalf("scheduled runs executed twice: %+v", history)
+	}
+}
+
+func TestCronCatchUp(t *testing.T) {
+	locker := NewMemoryLocker()
+	locker.SetLastRun(context.Background(), "job", time.Now().Truncate(time.Second).Add(-5*time.Second))
+	c := NewCron(WithLocker(locker))
+	err := c.AddJob("job", "* * * * * *", func(ctx context.Context) error {
+		return nil
+	}, WithCatchUp(CatchUpAll))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = c.AddJob("timeout", "* * * * * *", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}, WithTimeout(100*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Start()
+	time.Sleep(1500 * time.Millisecond)
+	<-c.Stop().Done()
+
+	recorder := httptest.NewRecorder()
+	c.Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/debug/cron", nil))
+	var states []JobState
+	if err = json.Unmarshal(recorder.Body.Bytes(), &states); err != nil {
+		t.Fatal(err)
+	}
+	if len(states) != 2 || states[0].Next.IsZero() {
+		t.Fatalf("unexpected states: %s", recorder.Body.String())
+	}
+	if len(states[1].History) == 0 || states[1].History[0].Err == "" {
+		t.Fatalf("timeout not recorded: %+v", states[1])
+	}
+	var catchUp int
+	for _, run := range states[0].History {
+		if run.CatchUp {
+			catchUp++
+		}
+	}
+	if catchUp < 4 || catchUp > 5 {
+		t.Fatalf("unexpected catch up runs: %d", catchUp)
+	}
+}
@@ -0,0 +1,75 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package cron
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/hopeio/gox/net/http/consts"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// JobState 任务当前状态
+type JobState struct {
+	Name    string        `json:"name"`
+	Spec    string        `json:"spec"`
+	Timeout time.Duration `json:"timeout,omitempty"`
+	CatchUp CatchUpPolicy `json:"catchUp"`
+	Running bool          `json:"running"`
+	Next    time.Time     `json:"next"`
+	// 最后一次调度时间,可能由其他节点执行
+	Last    time.Time `json:"last,omitempty"`
+	History []Run     `json:"history,omitempty"`
+}
+
+// Jobs 获取所有任务的状态
+func (c *Cron) Jobs(ctx context.Context) []JobState {
+	c.mu.RLock()
+	entries := make([]*Entry, 0, len(c.names))
+	for _, name := range c.names {
+		entries = append(entries, c.entries[name])
+	}
+	c.mu.RUnlock()
+	states := make([]JobState, 0, len(entries))
+	for _, entry := range entries {
+		state := JobState{
+			Name:    entry.Name,
+			Spec:    entry.Spec,
+			Timeout: entry.Timeout,
+			CatchUp: entry.CatchUp,
+			Running: atomic.LoadInt32(&entry.running) > 0,
+			Next:    c.cron.Entry(entry.id).Next,
+		}
+		entry.mu.Lock()
+		state.History = append([]Run(nil), entry.history...)
+		entry.mu.Unlock()
+		if len(state.History) > 0 {
+			state.Last = state.History[len(state.History)-1].Scheduled
+		}
+		if c.lastRunStore != nil {
+			if last, err := c.lastRunStore.LastRun(ctx, entry.Name); err == nil && last.After(state.Last) {
+				state.Last = last
+			}
+		}
+		states = append(states, state)
+	}
+	return states
+}
+
+// Handler 以json格式输出所有任务的下次及上次调度时间和执行记录
+func (c *Cron) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(consts.HeaderContentType, consts.ContentTypeJsonUtf8)
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(c.Jobs(r.Context())); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
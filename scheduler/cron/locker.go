@@ -0,0 +1,119 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package cron
+
+import (
+	"context"
+	"errors"
+	goredis "github.com/go-redis/redis/v8"
+	redisi "github.com/hopeio/gox/datax/redis"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Locker 多副本部署时保证同一任务的同一次调度只在一个节点执行
+// 锁的key包含任务名及调度时间,获取后不主动释放,ttl过期后自动失效
+type Locker interface {
+	TryLock(ctx context.Context, key string, ttl time.Duration) (bool, error)
+}
+
+// LastRunStore 记录任务最后一次调度的时间,用于补偿错过的调度
+type LastRunStore interface {
+	LastRun(ctx context.Context, name string) (time.Time, error)
+	SetLastRun(ctx context.Context, name string, t time.Time) error
+}
+
+// MemoryLocker 进程内的锁,适用于单节点或测试
+type MemoryLocker struct {
+	mu      sync.Mutex
+	locks   map[string]time.Time
+	lastRun map[string]time.Time
+}
+
+func NewMemoryLocker() *MemoryLocker {
+	return &MemoryLocker{locks: make(map[string]time.Time), lastRun: make(map[string]time.Time)}
+}
+
+func (l *MemoryLocker) TryLock(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for k, expireAt := range l.locks {
+		if !expireAt.After(now) {
+			delete(l.locks, k)
+		}
+	}
+	if _, ok := l.locks[key]; ok {
+		return false, nil
+	}
+	l.locks[key] = now.Add(ttl)
+	return true, nil
+}
+
+func (l *MemoryLocker) LastRun(ctx context.Context, name string) (time.Time, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.lastRun[name], nil
+}
+
+func (l *MemoryLocker) SetLastRun(ctx context.Context, name string, t time.Time) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if t.After(l.lastRun[name]) {
+		l.lastRun[name] = t
+	}
+	return nil
+}
+
+var setLastRunScript = goredis.NewScript(redisi.SetIfGreaterScript)
+
+// RedisLocker 基于redis的分布式锁,同时记录任务最后一次调度的时间
+type RedisLocker struct {
+	client goredis.UniversalClient
+	prefix string
+}
+
+// NewRedisLocker prefix为所有key的前缀,为空时使用"cron:"
+func NewRedisLocker(client goredis.UniversalClient, prefix string) *RedisLocker {
+	if prefix == "" {
+		prefix = "cron:"
+	}
+	return &RedisLocker{client: client, prefix: prefix}
+}
+
+func (l *RedisLocker) TryLock(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	_, args := redisi.LockArgs(l.prefix+"lock:"+key, ttl)
+	err := l.client.Do(ctx, args...).Err()
+	if errors.Is(err, goredis.Nil) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (l *RedisLocker) LastRun(ctx context.Context, name string) (time.Time, error) {
+	v, err := l.client.Get(ctx, l.prefix+"last:"+name).Result()
+	if errors.Is(err, goredis.Nil) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	unix, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(unix, 0), nil
+}
+
+// SetLastRun 只向后推进,较晚完成的补偿执行或较慢的副本不会使记录回退
+func (l *RedisLocker) SetLastRun(ctx context.Context, name string, t time.Time) error {
+	return setLastRunScript.Run(ctx, l.client, []string{l.prefix + "last:" + name}, t.Unix()).Err()
+}
@@ -0,0 +1,365 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package cron
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/hopeio/gox/log"
+	"github.com/robfig/cron/v3"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// JobFunc 定时任务,ctx在超时或Cron停止时取消
+type JobFunc func(ctx context.Context) error
+
+// CatchUpPolicy 启动时对停机期间错过的调度的处理策略
+type CatchUpPolicy uint8
+
+const (
+	// CatchUpNone 忽略错过的调度
+	CatchUpNone CatchUpPolicy = iota
+	// CatchUpOnce 有错过的调度时补偿执行一次
+	CatchUpOnce
+	// CatchUpAll 按顺序补偿执行每一次错过的调度,最多maxCatchUp次
+	CatchUpAll
+)
+
+const maxCatchUp = 100
+
+func (p CatchUpPolicy) String() string {
+	switch p {
+	case CatchUpNone:
+		return "none"
+	case CatchUpOnce:
+		return "once"
+	case CatchUpAll:
+		return "all"
+	}
+	return "unknown"
+}
+
+func (p CatchUpPolicy) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+func (p *CatchUpPolicy) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "none", "":
+		*p = CatchUpNone
+	case "once":
+		*p = CatchUpOnce
+	case "all":
+		*p = CatchUpAll
+	default:
+		return fmt.Errorf("unknown catch up policy: %s", text)
+	}
+	return nil
+}
+
+var ErrJobExists = errors.New("cron job already exists")
+
+// Run 任务的一次执行记录
+type Run struct {
+	Scheduled time.Time `json:"scheduled"`
+	BeginAt   time.Time `json:"beginAt"`
+	EndAt     time.Time `json:"endAt"`
+	Err       string    `json:"err,omitempty"`
+	CatchUp   bool      `json:"catchUp,omitempty"`
+}
+
+type Entry struct {
+	Name    string
+	Spec    string
+	Func    JobFunc
+	Timeout time.Duration
+	CatchUp CatchUpPolicy
+
+	id       cron.EntryID
+	schedule cron.Schedule
+	running  int32
+	mu       sync.Mutex
+	history  []Run
+}
+
+type JobOption func(entry *Entry)
+
+// WithTimeout 任务执行超时时间,超时后取消ctx
+func WithTimeout(timeout time.Duration) JobOption {
+	return func(entry *Entry) {
+		entry.Timeout = timeout
+	}
+}
+
+func WithCatchUp(policy CatchUpPolicy) JobOption {
+	return func(entry *Entry) {
+		entry.CatchUp = policy
+	}
+}
+
+// Cron 支持分布式锁的定时任务,多副本部署时每次调度只会在一个节点执行
+type Cron struct {
+	cron         *cron.Cron
+	locker       Locker
+	lastRunStore LastRunStore
+	lockTTL      time.Duration
+	historySize  int
+	location     *time.Location
+
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	mu      sync.RWMutex
+	entries map[string]*Entry
+	names   []string
+	started bool
+}
+
+type Option func(c *Cron)
+
+// WithLocker 设置分布式锁,默认为进程内的MemoryLocker
+func WithLocker(locker Locker) Option {
+	return func(c *Cron) {
+		c.locker = locker
+	}
+}
+
+// WithLastRunStore 设置调度时间的存储,未设置时如果Locker实现了LastRunStore则使用Locker
+func WithLastRunStore(store LastRunStore) Option {
+	return func(c *Cron) {
+		c.lastRunStore = store
+	}
+}
+
+// WithLockTTL 锁的过期时间,需大于各节点之间的时钟偏差,默认1分钟
+func WithLockTTL(ttl time.Duration) Option {
+	return func(c *Cron) {
+		c.lockTTL = ttl
+	}
+}
+
+// WithHistorySize 每个任务保留的执行记录数,默认20
+func WithHistorySize(size int) Option {
+	return func(c *Cron) {
+		c.historySize = size
+	}
+}
+
+func WithLocation(location *time.Location) Option {
+	return func(c *Cron) {
+		c.location = location
+	}
+}
+
+// NewCron 构造Cron,spec支持可选的秒字段及@every等描述符
+func NewCron(opts ...Option) *Cron {
+	c := &Cron{
+		lockTTL:     time.Minute,
+		historySize: 20,
+		location:    time.Local,
+		entries:     make(map[string]*Entry),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.locker == nil {
+		c.locker = NewMemoryLocker()
+	}
+	if c.lastRunStore == nil {
+		if store, ok := c.locker.(LastRunStore); ok {
+			c.lastRunStore = store
+		}
+	}
+	c.ctx, c.cancel = context.WithCancel(context.Background())
+	c.cron = cron.New(cron.WithLocation(c.location), cron.WithParser(cron.NewParser(
+		cron.SecondOptional|cron.Minute|cron.Hour|cron.Dom|cron.Month|cron.Dow|cron.Descriptor,
+	)))
+	return c
+}
+
+// AddJob 添加任务,name在所有副本中需唯一且一致,用于加锁及记录调度时间
+func (c *Cron) AddJob(name, spec string, f JobFunc, opts ...JobOption) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[name]; ok {
+		return fmt.Errorf("%w: %s", ErrJobExists, name)
+	}
+	entry := &Entry{Name: name, Spec: spec, Func: f}
+	for _, opt := range opts {
+		opt(entry)
+	}
+	id, err := c.cron.AddFunc(spec, func() {
+		c.fire(entry)
+	})
+	if err != nil {
+		return err
+	}
+	entry.id = id
+	entry.schedule = c.cron.Entry(id).Schedule
+	c.entries[name] = entry
+	c.names = append(c.names, name)
+	if c.started && entry.CatchUp != CatchUpNone {
+		c.catchUp(entry)
+	}
+	return nil
+}
+
+// RemoveJob 移除任务,正在执行的不受影响
+func (c *Cron) RemoveJob(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[name]
+	if !ok {
+		return
+	}
+	c.cron.Remove(entry.id)
+	delete(c.entries, name)
+	for i, n := range c.names {
+		if n == name {
+			c.names = append(c.names[:i], c.names[i+1:]...)
+			break
+		}
+	}
+}
+
+func (c *Cron) Start() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.started {
+		return
+	}
+	c.started = true
+	for _, name := range c.names {
+		if entry := c.entries[name]; entry.CatchUp != CatchUpNone {
+			c.catchUp(entry)
+		}
+	}
+	c.cron.Start()
+}
+
+// Stop 停止调度并取消正在执行的任务,返回的ctx在所有任务结束后Done
+func (c *Cron) Stop() context.Context {
+	stopCtx := c.cron.Stop()
+	c.cancel()
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-stopCtx.Done()
+		c.wg.Wait()
+		cancel()
+	}()
+	return ctx
+}
+
+// catchUp 补偿停机期间错过的调度,调用方需持有c.mu
+func (c *Cron) catchUp(entry *Entry) {
+	if c.lastRunStore == nil {
+		return
+	}
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		last, err := c.lastRunStore.LastRun(c.ctx, entry.Name)
+		if err != nil {
+			log.Errorf("cron job %s get last run error: %v", entry.Name, err)
+			return
+		}
+		// 从未执行过
+		if last.IsZero() {
+			return
+		}
+		now := time.Now().In(c.location)
+		var missed []time.Time
+		for t := entry.schedule.Next(last.In(c.location)); !t.IsZero() && t.Before(now.Truncate(time.Second)); t = entry.schedule.Next(t) {
+			missed = append(missed, t)
+			if len(missed) > maxCatchUp {
+				missed = missed[1:]
+			}
+		}
+		if len(missed) == 0 {
+			return
+		}
+		if entry.CatchUp == CatchUpOnce {
+			missed = missed[len(missed)-1:]
+		}
+		log.Infof("cron job %s catch up %d missed runs since %s", entry.Name, len(missed), last)
+		for _, scheduled := range missed {
+			if c.ctx.Err() != nil {
+				return
+			}
+			c.runJob(entry, scheduled, true)
+		}
+	}()
+}
+
+// fire cron调度时调用,以调度时间而非当前时间作为锁的一部分,时钟有偏差或触发跨秒的各节点得到相同的key
+func (c *Cron) fire(entry *Entry) {
+	c.mu.RLock()
+	id := entry.id
+	c.mu.RUnlock()
+	// cron在启动任务后、处理下一个请求前更新Prev,这里取到的就是本次的调度时间
+	scheduled := c.cron.Entry(id).Prev
+	// 任务已被移除
+	if scheduled.IsZero() {
+		return
+	}
+	c.runJob(entry, scheduled.In(c.location), false)
+}
+
+func (c *Cron) lockKey(entry *Entry, scheduled time.Time) string {
+	return entry.Name + ":" + strconv.FormatInt(scheduled.Unix(), 10)
+}
+
+func (c *Cron) runJob(entry *Entry, scheduled time.Time, catchUp bool) {
+	ok, err := c.locker.TryLock(c.ctx, c.lockKey(entry, scheduled), c.lockTTL)
+	if err != nil {
+		log.Errorf("cron job %s lock error: %v", entry.Name, err)
+		return
+	}
+	// 其他节点已执行
+	if !ok {
+		return
+	}
+	if c.lastRunStore != nil {
+		if err = c.lastRunStore.SetLastRun(c.ctx, entry.Name, scheduled); err != nil {
+			log.Errorf("cron job %s set last run error: %v", entry.Name, err)
+		}
+	}
+
+	ctx, cancel := c.ctx, context.CancelFunc(func() {})
+	if entry.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, entry.Timeout)
+	}
+	defer cancel()
+	atomic.AddInt32(&entry.running, 1)
+	run := Run{Scheduled: scheduled, BeginAt: time.Now(), CatchUp: catchUp}
+	err = c.call(ctx, entry)
+	run.EndAt = time.Now()
+	atomic.AddInt32(&entry.running, -1)
+	if err != nil {
+		run.Err = err.Error()
+		log.Errorf("cron job %s run error: %v", entry.Name, err)
+	}
+	entry.mu.Lock()
+	entry.history = append(entry.history, run)
+	if len(entry.history) > c.historySize {
+		entry.history = entry.history[len(entry.history)-c.historySize:]
+	}
+	entry.mu.Unlock()
+}
+
+func (c *Cron) call(ctx context.Context, entry *Entry) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return entry.Func(ctx)
+}
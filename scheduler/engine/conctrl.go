@@ -19,6 +19,7 @@ import (
 
 func (e *Engine[KEY]) Run(tasks ...*Task[KEY]) {
 	e.mu.Lock()
+	e.resume()
 	if e.isRunning {
 		if len(tasks) > 0 {
 			e.addTasks(e.ctx, 0, tasks...)
@@ -39,8 +40,26 @@ func (e *Engine[KEY]) Run(tasks ...*Task[KEY]) {
 					e.errHandlerRunning = false
 					return
 				case task := <-e.errTaskChan:
-					e.taskErrHandleCount++
+					atomic.AddUint64(&e.taskErrHandleCount, 1)
+					if kindHandler := e.getKindHandler(task.Kind); kindHandler != nil && kindHandler.group != nil {
+						atomic.AddUint64(&kindHandler.group.taskErrHandleCount, 1)
+					}
+					if e.store != nil && task.Key != e.zeroKey {
+						if err := e.store.Failed(task.record()); err != nil {
+							log.Errorf("task store failed err:%v", err)
+						}
+					}
+					id := task.id
 					e.errHandler(task)
+					// errHandler没有重新添加任务(会分配新id)且没有等待重试则任务最终失败
+					// 等待重试的任务由定时器在持有e.mu时重新添加,这里同样加锁读取
+					e.mu.Lock()
+					failed := task.id == id && !task.retrying
+					task.retrying = false
+					e.mu.Unlock()
+					if failed {
+						e.dagFail(task)
+					}
 					e.wg.Done()
 				}
 			}
@@ -48,6 +67,8 @@ func (e *Engine[KEY]) Run(tasks ...*Task[KEY]) {
 		e.errHandlerRunning = true
 	}
 	e.addWorker()
+	e.runWorkerGroups()
+	e.runMonitor()
 	if !e.isRunning {
 		e.isRunning = true
 		e.wg.Add(1)
@@ -72,7 +93,7 @@ func (e *Engine[KEY]) Run(tasks ...*Task[KEY]) {
 					readyTask = nil
 				case <-timer.C:
 					//检测任务是否已空
-					if uint(e.workingWorkerCount) == 0 && len(e.readyTaskHeap) == 0 {
+					if atomic.LoadUint64(&e.workingWorkerCount) == 0 && e.readyTaskCount() == 0 {
 						e.mu.Lock()
 						counter, _ := synci.WaitGroupState(&e.wg)
 						if counter == 1 {
@@ -87,8 +108,8 @@ func (e *Engine[KEY]) Run(tasks ...*Task[KEY]) {
 						}
 						e.mu.Unlock()
 					}
-					fmt.Printf("[Running] task:R:%d,D:%d/T:%d/S:%d/H:%d/F:%d/E:%d,worker: %d/%d\r", len(e.readyTaskHeap),
-						e.taskDoneCount, e.taskTotalCount, e.taskSkipCount, e.taskErrHandleCount, e.taskFailedCount, e.taskErrorTimes, e.workingWorkerCount, e.currentWorkerCount)
+					fmt.Printf("[Running] task:R:%d,D:%d/T:%d/S:%d/H:%d/F:%d/E:%d,worker: %d/%d\r", e.readyTaskCount(),
+						e.TaskDoneCount(), e.TaskTotalCount(), e.TaskSkipCount(), e.TaskErrHandleCount(), e.TaskFailedCount(), e.TaskErrorTimes(), atomic.LoadUint64(&e.workingWorkerCount), atomic.LoadUint64(&e.currentWorkerCount))
 					timer.Reset(e.monitorInterval)
 				case <-e.ctx.Done():
 					if err := e.ctx.Err(); err != nil {
@@ -103,7 +124,7 @@ func (e *Engine[KEY]) Run(tasks ...*Task[KEY]) {
 	}
 	e.mu.Unlock()
 	e.wg.Wait()
-	log.NoCallerLogger().Infof("[END] task:D:%d/T:%d/S:%d/H:%d/F:%d/E:%d", e.taskDoneCount, e.taskTotalCount, e.taskSkipCount, e.taskErrHandleCount, e.taskFailedCount, e.taskErrorTimes)
+	log.NoCallerLogger().Infof("[END] task:D:%d/T:%d/S:%d/H:%d/F:%d/E:%d", e.TaskDoneCount(), e.TaskTotalCount(), e.TaskSkipCount(), e.TaskErrHandleCount(), e.TaskFailedCount(), e.TaskErrorTimes())
 }
 
 func (e *Engine[KEY]) newWorker(readyTask *Task[KEY]) {
@@ -116,7 +137,7 @@ func (e *Engine[KEY]) newWorker(readyTask *Task[KEY]) {
 			if r := recover(); r != nil {
 				worker.canExecute = false
 				log.StackError(r, spew.Sdump(readyTask))
-				atomic.AddUint64(&e.taskFailedCount, 1)
+				e.recoverTask(readyTask, r)
 				e.wg.Done()
 				// 创建一个新的
 				e.newWorker(nil)
@@ -170,12 +191,54 @@ func (e *Engine[KEY]) addWorker() {
 
 }
 
+// resume 从store中恢复未完成的任务,只在第一次Run时执行
+func (e *Engine[KEY]) resume() {
+	if e.store == nil || e.resumed {
+		return
+	}
+	e.resumed = true
+	if e.restore == nil {
+		log.Warn("task store without restore func, pending tasks will not be resumed")
+		return
+	}
+	records, err := e.store.Pending()
+	if err != nil {
+		log.Errorf("task store pending err:%v", err)
+		return
+	}
+	for _, record := range records {
+		task := e.restore(record)
+		if task == nil {
+			continue
+		}
+		task.Key = record.Key
+		task.Kind = record.Kind
+		if len(task.deps) == 0 {
+			task.deps = record.Deps
+		}
+		if task.Describe == "" {
+			task.Describe = record.Describe
+		}
+		// 任务已在TaskStore中,不再重复记录
+		e.enqueueTasks(nil, record.Priority, false, task)
+	}
+	if len(records) > 0 {
+		log.Infof("resume %d tasks from task store", len(records))
+	}
+}
+
 func (e *Engine[KEY]) addTasks(ctx context.Context, priority int, tasks ...*Task[KEY]) {
+	e.enqueueTasks(ctx, priority, true, tasks...)
+}
+
+// enqueueTasks journal为false时不写入TaskStore,用于恢复及重试已记录过的任务
+func (e *Engine[KEY]) enqueueTasks(ctx context.Context, priority int, journal bool, tasks ...*Task[KEY]) {
 	l := len(tasks)
 	atomic.AddUint64(&e.taskTotalCount, uint64(l))
 	e.wg.Add(l)
+	var records []*TaskRecord[KEY]
 	for _, task := range tasks {
-		if task == nil || task.Run == nil {
+		if task == nil || (task.Run == nil && e.taskHandler(task) == nil) {
 			atomic.AddUint64(&e.taskTotalCount, ^uint64(0))
 			e.wg.Done()
 			continue
@@ -188,9 +251,44 @@ func (e *Engine[KEY]) addTasks(ctx context.Context, priority int, tasks ...*Task
 		}
 		task.Priority = priority
 		task.id = id2.NewOrderedID()
-		e.readyTaskHeap.Push(task)
+		if kindHandler := e.getKindHandler(task.Kind); kindHandler != nil && kindHandler.group != nil {
+			atomic.AddUint64(&kindHandler.group.taskTotalCount, 1)
+		}
+		if journal && e.store != nil && task.Key != e.zeroKey {
+			records = append(records, task.record())
+		}
+		if e.dag != nil {
+			ready, skip := e.dagAdd(task)
+			if skip {
+				e.skipTask(task)
+				continue
+			}
+			if !ready {
+				continue
+			}
+		}
+		e.pushTask(task)
+	}
+	if len(records) > 0 {
+		if err := e.store.Enqueue(records...); err != nil {
+			log.Errorf("task store enqueue err:%v", err)
+		}
 	}
+}
 
+// taskHandler Run为nil时任务的执行函数,优先KindHandler,其次工作组的Handler
+func (e *Engine[KEY]) taskHandler(task *Task[KEY]) TaskHandler[KEY] {
+	kindHandler := e.getKindHandler(task.Kind)
+	if kindHandler == nil {
+		return nil
+	}
+	if kindHandler.Handler != nil {
+		return kindHandler.Handler
+	}
+	if kindHandler.group != nil {
+		return kindHandler.group.handler
+	}
+	return nil
 }
 
 func (e *Engine[KEY]) AddOptionTasks(ctx context.Context, priority int, tasks ...*Task[KEY]) {
@@ -225,7 +323,7 @@ func (e *Engine[KEY]) newFixedWorker(worker *Worker[KEY], interval time.Duration
 			if r := recover(); r != nil {
 				worker.canExecute = false
 				log.StackError(r, spew.Sdump(task))
-				atomic.AddUint64(&e.taskFailedCount, 1)
+				e.recoverTask(task, r)
 				e.wg.Done()
 				// 创建一个新的
 				e.newFixedWorker(worker, interval)
@@ -283,17 +381,29 @@ func (e *Engine[KEY]) ExecTask(worker *Worker[KEY], task *Task[KEY]) {
 	atomic.AddUint64(&e.workingWorkerCount, 1)
 	worker.isExecuting = true
 	worker.currentTask = task
+	// panic时也要恢复计数,由worker的recover负责替换worker
+	defer func() {
+		atomic.AddUint64(&e.workingWorkerCount, ^uint64(0))
+		worker.isExecuting = false
+	}()
 	if e.execTask(task) {
 		e.wg.Done()
 	}
-	atomic.AddUint64(&e.workingWorkerCount, ^uint64(0))
-	worker.isExecuting = false
 }
 
 func (e *Engine[KEY]) execTask(task *Task[KEY]) bool {
+	kindHandler := e.getKindHandler(task.Kind)
+	var group *WorkerGroup[KEY]
+	if kindHandler != nil {
+		group = kindHandler.group
+	}
 	if task.Key != e.zeroKey {
-		if _, ok := e.done.Get(task.Key); ok {
+		if e.isDone(task.Key) {
 			atomic.AddUint64(&e.taskSkipCount, 1)
+			if group != nil {
+				atomic.AddUint64(&group.taskSkipCount, 1)
+			}
+			e.dagDone(task.Key)
 			return true
 		}
 	}
@@ -309,14 +419,13 @@ func (e *Engine[KEY]) execTask(task *Task[KEY]) bool {
 		}
 	}
 
-	var kindHandler *KindHandler[KEY]
-	if e.kindHandlers != nil && int(task.Kind) < len(e.kindHandlers) {
-		kindHandler = e.kindHandlers[task.Kind]
-	}
-
 	if kindHandler != nil {
 		if kindHandler.Skip {
 			atomic.AddUint64(&e.taskSkipCount, 1)
+			if group != nil {
+				atomic.AddUint64(&group.taskSkipCount, 1)
+			}
+			e.dagDone(task.Key)
 			return true
 		}
 
@@ -331,6 +440,18 @@ func (e *Engine[KEY]) execTask(task *Task[KEY]) bool {
 		}
 	}
 
+	if group != nil {
+		if group.speedLimit != nil {
+			group.speedLimit.Wait()
+		}
+		if group.rateLimiter != nil {
+			err := group.rateLimiter.Wait(task.ctx)
+			if err != nil {
+				log.Warnf("group %s rate limit err:%v", group.Name, err)
+			}
+		}
+	}
+
 	if task.reExecTimes > 0 {
 		task.reExecLogs = append(task.reExecLogs, &execLog{
 			execBeginAt: time.Now(),
@@ -338,15 +459,23 @@ func (e *Engine[KEY]) execTask(task *Task[KEY]) bool {
 	} else {
 		task.execBeginAt = time.Now()
 	}
-	tasks, err := task.Run.Run(task.ctx)
-	if task.reExecTimes > 0 {
-		task.reExecLogs[len(task.reExecLogs)-1].execEndAt = time.Now()
-	} else {
-		task.execEndAt = time.Now()
-	}
+	var tasks []*Task[KEY]
+	var err error
+	ctx := e.beginExec(task)
+	func() {
+		defer e.endExec(task)
+		if task.Run != nil {
+			tasks, err = task.Run.Run(ctx)
+		} else {
+			tasks, err = e.taskHandler(task)(ctx, task)
+		}
+	}()
 
 	if err != nil {
 		atomic.AddUint64(&e.taskErrorTimes, 1)
+		if group != nil {
+			atomic.AddUint64(&group.taskErrorTimes, 1)
+		}
 		task.errTimes++
 		if task.reExecTimes > 0 {
 			task.reExecLogs[len(task.reExecLogs)-1].err = err
@@ -356,10 +485,14 @@ func (e *Engine[KEY]) execTask(task *Task[KEY]) bool {
 
 		if task.errTimes < 5 {
 			task.reExecTimes++
+			atomic.AddUint64(&e.taskRepeatTimes, 1)
+			if group != nil {
+				atomic.AddUint64(&group.taskRepeatTimes, 1)
+			}
 			log.Warnf("%v执行失败:%v,将第%d次执行", task.Key, err, task.reExecTimes)
 			task.Priority++
 			e.mu.Lock()
-			e.readyTaskHeap.Push(task)
+			e.pushTask(task)
 			e.mu.Unlock()
 		} else {
 			log.Warn(task.Key, "多次执行失败:", err, "将执行错误处理")
@@ -368,13 +501,23 @@ func (e *Engine[KEY]) execTask(task *Task[KEY]) bool {
 
 		return false
 	}
-	if task.Key != e.zeroKey {
-		e.done.SetWithTTL(task.Key, struct{}{}, 1, time.Hour)
-	}
+	// 先记录子任务再标记完成,中断时子任务不会丢失
 	if len(tasks) > 0 {
 		e.AddOptionTasks(task.ctx, task.Priority+1, tasks...)
 	}
+	if task.Key != e.zeroKey {
+		e.done.SetWithTTL(task.Key, struct{}{}, 1, time.Hour)
+		if e.store != nil {
+			if err = e.store.Done(task.Key); err != nil {
+				log.Errorf("task store done err:%v", err)
+			}
+		}
+		e.dagDone(task.Key)
+	}
 	atomic.AddUint64(&e.taskDoneCount, 1)
+	if group != nil {
+		atomic.AddUint64(&group.taskDoneCount, 1)
+	}
 	return true
 }
 
@@ -384,6 +527,9 @@ func (e *Engine[KEY]) Stop() {
 		e.speedLimit.Stop()
 	}
 	e.done.Close()
+	for _, group := range e.workerGroups {
+		group.stop()
+	}
 	for _, kindHandler := range e.kindHandlers {
 		if kindHandler != nil {
 			if kindHandler.speedLimit != nil {
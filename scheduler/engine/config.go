@@ -10,14 +10,18 @@ import (
 	"context"
 	"github.com/dgraph-io/ristretto/v2"
 	"github.com/hopeio/gox/datastructure/heap"
+	"github.com/hopeio/gox/log"
+	"github.com/prometheus/client_golang/prometheus"
 	"time"
 )
 
 type Config[KEY Key] struct {
 	WorkerCount     uint64
 	MonitorInterval time.Duration // 全局检测定时器间隔时间，任务的卡住检测，worker panic recover都可以用这个检测
+	TaskTimeout     time.Duration // 任务默认执行超时时间,为0不限制
 	DoneCache       ristretto.Config[KEY, struct{}]
-	EnableTelemetry bool
+	EnableTelemetry bool // 注册指标到prometheus.DefaultRegisterer
+	WorkerGroups    []*WorkerGroupConfig[KEY]
 }
 
 func (c *Config[KEY]) NewEngine() *Engine[KEY] {
@@ -42,9 +46,19 @@ func (c *Config[KEY]) NewEngineWithContext(ctx context.Context) *Engine[KEY] {
 		errTaskChan:      make(chan *Task[KEY]),
 		readyTaskHeap:    heap.Heap[*Task[KEY]]{},
 		monitorInterval:  c.MonitorInterval,
+		taskTimeout:      c.TaskTimeout,
+		executing:        make(map[uint64]*Task[KEY]),
 		done:             cache,
 		errHandler:       func(task *Task[KEY]) { task.ErrLog() },
 	}
+	for _, group := range c.WorkerGroups {
+		engine.WorkerGroup(group)
+	}
+	if c.EnableTelemetry {
+		if err := engine.Metrics(prometheus.DefaultRegisterer, nil); err != nil {
+			log.Errorf("engine metrics register err:%v", err)
+		}
+	}
 	return engine
 }
 
@@ -86,3 +100,29 @@ func NewConfig[KEY Key](opts ...Option[KEY]) *Config[KEY] {
 }
 
 type Option[KEY Key] func(engine *Config[KEY])
+
+func WithWorkerCount[KEY Key](workerCount uint64) Option[KEY] {
+	return func(c *Config[KEY]) {
+		c.WorkerCount = workerCount
+	}
+}
+
+func WithMonitorInterval[KEY Key](interval time.Duration) Option[KEY] {
+	return func(c *Config[KEY]) {
+		c.MonitorInterval = interval
+	}
+}
+
+// WithTaskTimeout 任务默认执行超时时间
+func WithTaskTimeout[KEY Key](timeout time.Duration) Option[KEY] {
+	return func(c *Config[KEY]) {
+		c.TaskTimeout = timeout
+	}
+}
+
+// WithWorkerGroup 添加工作组
+func WithWorkerGroup[KEY Key](group *WorkerGroupConfig[KEY]) Option[KEY] {
+	return func(c *Config[KEY]) {
+		c.WorkerGroups = append(c.WorkerGroups, group)
+	}
+}
@@ -0,0 +1,322 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package engine
+
+import (
+	"fmt"
+	"github.com/hopeio/gox/log"
+	synci "github.com/hopeio/gox/sync"
+	"io"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// DAGPolicy 依赖的任务失败时的处理策略
+type DAGPolicy uint8
+
+const (
+	// DAGSkip 跳过所有直接或间接依赖失败任务的任务,其他任务继续执行
+	DAGSkip DAGPolicy = iota
+	// DAGFailFast 任一任务失败则跳过所有尚未执行的任务
+	DAGFailFast
+)
+
+type dagStatus uint8
+
+const (
+	dagWaiting dagStatus = iota
+	dagReady
+	dagDone
+	dagFailed
+	dagSkipped
+)
+
+func (s dagStatus) String() string {
+	switch s {
+	case dagWaiting:
+		return "waiting"
+	case dagReady:
+		return "ready"
+	case dagDone:
+		return "done"
+	case dagFailed:
+		return "failed"
+	case dagSkipped:
+		return "skipped"
+	}
+	return "unknown"
+}
+
+func (s dagStatus) color() string {
+	switch s {
+	case dagReady:
+		return "lightblue"
+	case dagDone:
+		return "green"
+	case dagFailed:
+		return "red"
+	case dagSkipped:
+		return "orange"
+	}
+	return "gray"
+}
+
+// dag 任务依赖图,由Engine.mu保护
+type dag[KEY Key] struct {
+	policy  DAGPolicy
+	aborted bool
+	status  map[KEY]dagStatus
+	// 依赖key -> 等待该key完成的任务
+	children map[KEY][]*Task[KEY]
+	// 等待中的任务 -> 未完成的依赖数
+	waiting map[*Task[KEY]]int
+	// 用于导出,key -> 依赖
+	edges map[KEY][]KEY
+	keys  []KEY
+}
+
+func newDAG[KEY Key](policy DAGPolicy) *dag[KEY] {
+	return &dag[KEY]{
+		policy:   policy,
+		status:   make(map[KEY]dagStatus),
+		children: make(map[KEY][]*Task[KEY]),
+		waiting:  make(map[*Task[KEY]]int),
+		edges:    make(map[KEY][]KEY),
+	}
+}
+
+// DAG 开启依赖图模式,通过Task.DependsOn声明依赖的任务Key,所有依赖完成后任务才会被调度
+// 依赖已完成的Key(包括TaskStore中记录的)视为满足,失败时按policy传播
+func (e *Engine[KEY]) DAG(policy DAGPolicy) *Engine[KEY] {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.dag == nil {
+		e.dag = newDAG[KEY](policy)
+	} else {
+		e.dag.policy = policy
+	}
+	return e
+}
+
+func (d *dag[KEY]) setStatus(key KEY, status dagStatus) {
+	if _, ok := d.status[key]; !ok {
+		d.keys = append(d.keys, key)
+	}
+	d.status[key] = status
+}
+
+// add 加入任务,返回任务是否可以立即调度及是否应该被跳过
+func (e *Engine[KEY]) dagAdd(task *Task[KEY]) (ready, skip bool) {
+	d := e.dag
+	if d.aborted {
+		return false, true
+	}
+	hasKey := task.Key != e.zeroKey
+	if hasKey {
+		d.edges[task.Key] = task.deps
+	}
+	pending := 0
+	for _, dep := range task.deps {
+		status, ok := d.status[dep]
+		if !ok && e.isDone(dep) {
+			status, ok = dagDone, true
+			d.setStatus(dep, dagDone)
+		}
+		if ok && (status == dagFailed || status == dagSkipped) {
+			if hasKey {
+				d.setStatus(task.Key, dagSkipped)
+			}
+			return false, true
+		}
+		if ok && status == dagDone {
+			continue
+		}
+		pending++
+	}
+	if pending == 0 {
+		if hasKey {
+			d.setStatus(task.Key, dagReady)
+		}
+		return true, false
+	}
+	if hasKey {
+		d.setStatus(task.Key, dagWaiting)
+	}
+	d.waiting[task] = pending
+	for _, dep := range task.deps {
+		if status, ok := d.status[dep]; ok && status == dagDone {
+			continue
+		}
+		d.children[dep] = append(d.children[dep], task)
+	}
+	return false, false
+}
+
+func (e *Engine[KEY]) isDone(key KEY) bool {
+	if _, ok := e.done.Get(key); ok {
+		return true
+	}
+	return e.store != nil && e.store.IsDone(key)
+}
+
+// dagDone 任务完成,调度所有依赖已满足的任务
+func (e *Engine[KEY]) dagDone(key KEY) {
+	if e.dag == nil || key == e.zeroKey {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	d := e.dag
+	d.setStatus(key, dagDone)
+	children := d.children[key]
+	delete(d.children, key)
+	for _, child := range children {
+		pending, ok := d.waiting[child]
+		if !ok {
+			continue
+		}
+		if pending > 1 {
+			d.waiting[child] = pending - 1
+			continue
+		}
+		delete(d.waiting, child)
+		if child.Key != e.zeroKey {
+			d.setStatus(child.Key, dagReady)
+		}
+		e.pushTask(child)
+	}
+}
+
+// dagFail 任务最终失败,按策略跳过依赖它的任务
+func (e *Engine[KEY]) dagFail(task *Task[KEY]) {
+	if e.dag == nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	d := e.dag
+	if task.Key != e.zeroKey {
+		d.setStatus(task.Key, dagFailed)
+	}
+	if d.policy == DAGFailFast {
+		log.Warnf("task %v failed, skip all remaining tasks", task.Key)
+		e.dagAbort()
+		return
+	}
+	if task.Key != e.zeroKey {
+		e.dagSkipChildren(task.Key)
+	}
+}
+
+func (e *Engine[KEY]) dagSkipChildren(key KEY) {
+	d := e.dag
+	children := d.children[key]
+	delete(d.children, key)
+	for _, child := range children {
+		if _, ok := d.waiting[child]; !ok {
+			continue
+		}
+		delete(d.waiting, child)
+		e.skipTask(child)
+		if child.Key != e.zeroKey {
+			d.setStatus(child.Key, dagSkipped)
+			e.dagSkipChildren(child.Key)
+		}
+	}
+}
+
+// dagAbort 跳过所有等待中及就绪的任务,调用方需持有e.mu
+func (e *Engine[KEY]) dagAbort() {
+	d := e.dag
+	d.aborted = true
+	for task := range d.waiting {
+		e.skipTask(task)
+		if task.Key != e.zeroKey {
+			d.setStatus(task.Key, dagSkipped)
+		}
+	}
+	clear(d.waiting)
+	clear(d.children)
+	drain := func(task *Task[KEY]) {
+		e.skipTask(task)
+		if task.Key != e.zeroKey {
+			d.setStatus(task.Key, dagSkipped)
+		}
+	}
+	for task, ok := e.readyTaskHeap.Pop(); ok; task, ok = e.readyTaskHeap.Pop() {
+		drain(task)
+	}
+	for _, group := range e.workerGroups {
+		for task, ok := group.readyTaskHeap.Pop(); ok; task, ok = group.readyTaskHeap.Pop() {
+			atomic.AddUint64(&group.waitTaskCount, ^uint64(0))
+			drain(task)
+		}
+	}
+}
+
+// skipTask 跳过已计入的任务
+func (e *Engine[KEY]) skipTask(task *Task[KEY]) {
+	atomic.AddUint64(&e.taskSkipCount, 1)
+	if kindHandler := e.getKindHandler(task.Kind); kindHandler != nil && kindHandler.group != nil {
+		atomic.AddUint64(&kindHandler.group.taskSkipCount, 1)
+	}
+	e.wg.Done()
+}
+
+// checkDAG 只剩等待中的任务时,其依赖永远不会完成(依赖不存在或循环依赖),按失败处理
+func (e *Engine[KEY]) checkDAG() {
+	if e.dag == nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	d := e.dag
+	if len(d.waiting) == 0 || !e.isRunning || atomic.LoadUint64(&e.workingWorkerCount) != 0 || e.readyTaskLen() != 0 {
+		return
+	}
+	// Run本身占用一个计数
+	counter, _ := synci.WaitGroupState(&e.wg)
+	if int(counter) != len(d.waiting)+1 {
+		return
+	}
+	for task := range d.waiting {
+		log.Warnf("task %v dependencies %v unresolvable, skipped", task.Key, task.deps)
+		e.skipTask(task)
+		if task.Key != e.zeroKey {
+			d.setStatus(task.Key, dagSkipped)
+		}
+	}
+	clear(d.waiting)
+	clear(d.children)
+}
+
+// DAGDot 以graphviz dot格式导出任务依赖图及各任务状态
+func (e *Engine[KEY]) DAGDot(w io.Writer) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.dag == nil {
+		return fmt.Errorf("dag mode not enabled")
+	}
+	d := e.dag
+	var builder strings.Builder
+	builder.WriteString("digraph engine {\n\trankdir=LR;\n\tnode [style=filled];\n")
+	for _, key := range d.keys {
+		status := d.status[key]
+		name := strconv.Quote(fmt.Sprint(key))
+		builder.WriteString("\t" + name + " [fillcolor=" + status.color() + ", tooltip=" + strconv.Quote(status.String()) + "];\n")
+	}
+	for _, key := range d.keys {
+		name := strconv.Quote(fmt.Sprint(key))
+		for _, dep := range d.edges[key] {
+			builder.WriteString("\t" + strconv.Quote(fmt.Sprint(dep)) + " -> " + name + ";\n")
+		}
+	}
+	builder.WriteString("}\n")
+	_, err := io.WriteString(w, builder.String())
+	return err
+}
@@ -13,7 +13,7 @@ import (
 	"github.com/hopeio/gox/datastructure/heap"
 	"github.com/hopeio/gox/log"
 	"github.com/hopeio/gox/os/fs"
-	"github.com/hopeio/gox/slices"
+	"github.com/hopeio/gox/scheduler/retry"
 	time2 "github.com/hopeio/gox/time"
 	"golang.org/x/time/rate"
 	"sync"
@@ -30,17 +30,17 @@ type Engine[KEY Key] struct {
 	workerCount, currentWorkerCount, workingWorkerCount uint64
 	waitTaskCount                                       uint64
 	workers                                             []*Worker[KEY]
-	// workerGroup [][]*Worker[KEY] //TODO 工作组概念
-	taskChanConsumer chan *Task[KEY]
-	errTaskChan      chan *Task[KEY]
-	readyTaskHeap    heap.Heap[*Task[KEY]]
-	ctx              context.Context
-	cancel           context.CancelFunc // 手动停止执行
-	wg               sync.WaitGroup     // 控制确保所有任务执行完
-	mu               sync.RWMutex
-	speedLimit       time2.Ticker
-	rateLimiter      *rate.Limiter
-	//TODO
+	taskChanConsumer                                    chan *Task[KEY]
+	errTaskChan                                         chan *Task[KEY]
+	readyTaskHeap                                       heap.Heap[*Task[KEY]]
+	workerGroups                                        []*WorkerGroup[KEY]
+	ctx                                                 context.Context
+	cancel                                              context.CancelFunc // 手动停止执行
+	wg                                                  sync.WaitGroup     // 控制确保所有任务执行完
+	mu                                                  sync.RWMutex
+	speedLimit                                          time2.Ticker
+	rateLimiter                                         *rate.Limiter
+
 	monitorInterval      time.Duration // 全局检测定时器间隔时间，任务的卡住检测，worker panic recover都可以用这个检测
 	workerFactoryRunning atomic.Bool
 	errHandlerRunning    bool
@@ -48,18 +48,33 @@ type Engine[KEY Key] struct {
 	isRunning, isStopped bool
 	EngineStatistics
 	done         *ristretto.Cache[KEY, struct{}]
+	store        TaskStore[KEY]
+	restore      TaskRestore[KEY]
+	resumed      bool
 	kindHandlers []*KindHandler[KEY]
 	errHandler   func(task *Task[KEY])
 	onStop       []func(context.Context)
 	zeroKey      KEY // 泛型不够强大,又为了性能妥协的字段
+
+	// 任务卡住检测
+	taskTimeout    time.Duration
+	onStuck        func(task *Task[KEY], execLog ExecLog)
+	onPanic        func(task *Task[KEY], r any)
+	execMu         sync.Mutex
+	executing      map[uint64]*Task[KEY]
+	monitorRunning bool
+
+	metrics *metrics[KEY]
+	dag     *dag[KEY]
 }
 
 type KindHandler[KEY Key] struct {
 	Skip        bool
 	speedLimit  time2.Ticker
 	rateLimiter *rate.Limiter
-	// TODO 指定Kind的Handler
-	Handler TaskFunc[KEY]
+	// 指定Kind的Handler,任务Run为nil时执行
+	Handler TaskHandler[KEY]
+	group   *WorkerGroup[KEY]
 }
 
 func New[KEY Key](opts ...Option[KEY]) *Engine[KEY] {
@@ -78,22 +93,34 @@ func NewEngineWithContext[KEY Key](workerCount uint64, ctx context.Context) *Eng
 }
 
 func (e *Engine[KEY]) SkipKind(kinds ...Kind) *Engine[KEY] {
-	length := slices.Max(kinds) + 1
-	if e.kindHandlers == nil {
-		e.kindHandlers = make([]*KindHandler[KEY], length)
+	for _, kind := range kinds {
+		e.kindHandler(kind).Skip = true
 	}
-	if int(length) > len(e.kindHandlers) {
-		e.kindHandlers = append(e.kindHandlers, make([]*KindHandler[KEY], int(length)-len(e.kindHandlers))...)
+	return e
+}
+
+// KindHandler 指定Kind的Handler,Run为nil的该Kind任务将由handler执行
+func (e *Engine[KEY]) KindHandler(kind Kind, handler TaskHandler[KEY]) *Engine[KEY] {
+	e.kindHandler(kind).Handler = handler
+	return e
+}
+
+// kindHandler 获取kind对应的KindHandler,不存在则创建
+func (e *Engine[KEY]) kindHandler(kind Kind) *KindHandler[KEY] {
+	if int(kind)+1 > len(e.kindHandlers) {
+		e.kindHandlers = append(e.kindHandlers, make([]*KindHandler[KEY], int(kind)+1-len(e.kindHandlers))...)
 	}
-	for _, kind := range kinds {
-		if e.kindHandlers[kind] == nil {
-			e.kindHandlers[kind] = &KindHandler[KEY]{Skip: true}
-		} else {
-			e.kindHandlers[kind].Skip = true
-		}
+	if e.kindHandlers[kind] == nil {
+		e.kindHandlers[kind] = &KindHandler[KEY]{}
+	}
+	return e.kindHandlers[kind]
+}
 
+func (e *Engine[KEY]) getKindHandler(kind Kind) *KindHandler[KEY] {
+	if int(kind) < len(e.kindHandlers) {
+		return e.kindHandlers[kind]
 	}
-	return e
+	return nil
 }
 
 func (e *Engine[KEY]) MonitorInterval(interval time.Duration) {
@@ -110,25 +137,52 @@ func (e *Engine[KEY]) ErrHandler(errHandler func(task *Task[KEY])) *Engine[KEY]
 	return e
 }
 
+// ErrHandlerUtilSuccess 不限次数地重新执行失败的任务
 func (e *Engine[KEY]) ErrHandlerUtilSuccess() *Engine[KEY] {
-	log.Warn("ErrHandlerUtilSuccess will clear history exec log contains err")
-	return e.ErrHandler(func(task *Task[KEY]) {
-		task.errTimes = 0
-		task.reExecLogs = task.reExecLogs[:0]
-		e.AddOptionTasks(task.ctx, task.Priority, task)
-	})
+	return e.ErrHandlerRetryPolicy(&retry.Policy{})
 }
 
+// ErrHandlerRetryTimes 任务的总重新执行次数小于times时重新执行
 func (e *Engine[KEY]) ErrHandlerRetryTimes(times int) *Engine[KEY] {
+	return e.ErrHandlerRetryPolicy(&retry.Policy{MaxAttempts: times + 1})
+}
+
+// ErrHandlerRetryPolicy 按重试策略重新执行失败的任务,等待期间不阻塞其他任务的错误处理
+// 执行记录会保留,Errs及ErrLog包含所有轮次的错误
+func (e *Engine[KEY]) ErrHandlerRetryPolicy(policy *retry.Policy) *Engine[KEY] {
 	return e.ErrHandler(func(task *Task[KEY]) {
-		if task.reExecTimes < times {
-			task.errTimes = 0
-			task.reExecLogs = task.reExecLogs[:0]
-			e.AddOptionTasks(task.ctx, task.Priority, task)
-		} else {
+		// 任务每轮会先执行多次才进入错误处理,以总执行次数作为尝试次数
+		errs := task.Errs()
+		attempt, err := task.reExecTimes+1, errs[len(errs)-1]
+		delay, ok := policy.Next(task.execBeginAt, attempt, err, task.retryDelay)
+		if !ok {
 			task.ErrLog()
+			return
 		}
+		if policy.OnRetry != nil {
+			policy.OnRetry(attempt, err, delay)
+		}
+		task.retryDelay = delay
+		e.retryTask(task, delay)
+	})
+}
 
+// retryTask 在错误处理中调用,delay后在持有e.mu时重新添加任务
+// 任务的状态只在这里(错误处理协程)修改,定时器协程只在持有e.mu时访问任务
+func (e *Engine[KEY]) retryTask(task *Task[KEY], delay time.Duration) {
+	task.errTimes = 0
+	task.retrying = true
+	priority := task.Priority
+	e.wg.Add(1)
+	time.AfterFunc(delay, func() {
+		defer e.wg.Done()
+		if e.ctx.Err() != nil {
+			return
+		}
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		// 任务入队时已记录到TaskStore,重试不再重复记录
+		e.enqueueTasks(nil, priority, false, task)
 	})
 }
 
@@ -145,6 +199,26 @@ func (e *Engine[KEY]) ErrHandlerWriteToFile(path string) *Engine[KEY] {
 	})
 }
 
+// Store 设置任务持久化存储,Run时会先通过restore重建store中未完成的任务,已完成的Key不会再次执行
+func (e *Engine[KEY]) Store(store TaskStore[KEY], restore TaskRestore[KEY]) *Engine[KEY] {
+	e.store = store
+	e.restore = restore
+	e.OnStop(func(context.Context) {
+		if err := store.Close(); err != nil {
+			log.Error(err)
+		}
+	})
+	return e
+}
+
+func (e *Engine[KEY]) FileStore(path string, restore TaskRestore[KEY]) *Engine[KEY] {
+	store, err := NewFileTaskStore[KEY](path)
+	if err != nil {
+		panic(err)
+	}
+	return e.Store(store, restore)
+}
+
 func (e *Engine[KEY]) OnStop(callBack func(context.Context)) *Engine[KEY] {
 	e.onStop = append(e.onStop, callBack)
 	return e
@@ -173,17 +247,7 @@ func (e *Engine[KEY]) KindRandSpeedLimit(kind Kind, minInterval, maxInterval tim
 }
 
 func (e *Engine[KEY]) kindSpeedLimit(kind Kind, limiter time2.Ticker) *Engine[KEY] {
-	if e.kindHandlers == nil {
-		e.kindHandlers = make([]*KindHandler[KEY], int(kind)+1)
-	}
-	if int(kind)+1 > len(e.kindHandlers) {
-		e.kindHandlers = append(e.kindHandlers, make([]*KindHandler[KEY], int(kind)+1-len(e.kindHandlers))...)
-	}
-	if e.kindHandlers[kind] == nil {
-		e.kindHandlers[kind] = &KindHandler[KEY]{speedLimit: limiter}
-	} else {
-		e.kindHandlers[kind].speedLimit = limiter
-	}
+	e.kindHandler(kind).speedLimit = limiter
 	return e
 }
 
@@ -215,17 +279,7 @@ func (e *Engine[KEY]) KindLimiter(kind Kind, r rate.Limit, b int) *Engine[KEY] {
 }
 
 func (e *Engine[KEY]) kindLimiter(kind Kind, r rate.Limit, b int) {
-	if e.kindHandlers == nil {
-		e.kindHandlers = make([]*KindHandler[KEY], int(kind)+1)
-	}
-	if int(kind)+1 > len(e.kindHandlers) {
-		e.kindHandlers = append(e.kindHandlers, make([]*KindHandler[KEY], int(kind)+1-len(e.kindHandlers))...)
-	}
-	if e.kindHandlers[kind] == nil {
-		e.kindHandlers[kind] = &KindHandler[KEY]{rateLimiter: rate.NewLimiter(r, b)}
-	} else {
-		e.kindHandlers[kind].rateLimiter = rate.NewLimiter(r, b)
-	}
+	e.kindHandler(kind).rateLimiter = rate.NewLimiter(r, b)
 }
 
 type AddTask[KEY Key] func(ctx context.Context, priority int, task ...*Task[KEY])
@@ -8,8 +8,13 @@ package engine
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"github.com/hopeio/gox/scheduler/retry"
 	"golang.org/x/time/rate"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -93,3 +98,130 @@ func TestEngineLimit(t *testing.T) {
 	engine.Limiter(rate.Limit(1), 1)
 	engine.Run()
 }
+
+func TestEngineWorkerGroup(t *testing.T) {
+	const kindImage Kind = 1
+	var imageCount atomic.Int32
+	engine := New[int](WithMonitorInterval[int](time.Second), WithWorkerGroup(&WorkerGroupConfig[int]{
+		Name:        "image",
+		Kinds:       []Kind{kindImage},
+		WorkerCount: 2,
+		Handler: func(ctx context.Context, task *Task[int]) ([]*Task[int], error) {
+			imageCount.Add(1)
+			time.Sleep(time.Millisecond * 100)
+			return nil, nil
+		},
+	}))
+	engine.Run(&Task[int]{Key: 1, Run: func(ctx context.Context) ([]*Task[int], error) {
+		var tasks []*Task[int]
+		for i := range 5 {
+			tasks = append(tasks, &Task[int]{Key: 100 + i, Kind: kindImage})
+		}
+		return tasks, nil
+	}})
+	if imageCount.Load() != 5 {
+		t.Fatalf("image handler executed %d times", imageCount.Load())
+	}
+	stats := engine.GroupStatistics()
+	if len(stats) != 1 || stats[0].TaskDoneCount() != 5 || stats[0].TaskTotalCount() != 5 {
+		t.Fatalf("unexpected group statistics: %+v", stats[0])
+	}
+	if engine.TaskDoneCount() != 6 {
+		t.Fatalf("unexpected done count: %d", engine.TaskDoneCount())
+	}
+}
+
+func TestEngineMonitor(t *testing.T) {
+	var stuck, panicked, done atomic.Int32
+	engine := New[int](WithMonitorInterval[int](time.Second), WithWorkerCount[int](2))
+	engine.OnStuck(func(task *Task[int], execLog ExecLog) {
+		if execLog.BeginAt.IsZero() || !execLog.EndAt.IsZero() {
+			t.Errorf("unexpected exec log: %+v", execLog)
+		}
+		stuck.Add(1)
+	}).OnPanic(func(task *Task[int], r any) {
+		panicked.Add(1)
+	})
+	engine.Run(
+		NewTask[int](func(ctx context.Context) ([]*Task[int], error) {
+			<-ctx.Done()
+			if !errors.Is(context.Cause(ctx), ErrTaskTimeout) {
+				t.Errorf("unexpected cause: %v", context.Cause(ctx))
+			}
+			done.Add(1)
+			return nil, nil
+		}).SetKey(1).SetTimeout(100*time.Millisecond),
+		NewTask[int](func(ctx context.Context) ([]*Task[int], error) {
+			panic("worker panic")
+		}).SetKey(2),
+		NewTask[int](func(ctx context.Context) ([]*Task[int], error) {
+			done.Add(1)
+			return nil, nil
+		}).SetKey(3),
+	)
+	if stuck.Load() != 1 || panicked.Load() != 1 || done.Load() != 2 {
+		t.Fatalf("stuck:%d panicked:%d done:%d", stuck.Load(), panicked.Load(), done.Load())
+	}
+}
+
+func TestEngineDAG(t *testing.T) {
+	var mu sync.Mutex
+	var order []string
+	run := func(key string, err error) *Task[string] {
+		return NewTask[string](func(ctx context.Context) ([]*Task[string], error) {
+			if err != nil {
+				return nil, err
+			}
+			mu.Lock()
+			order = append(order, key)
+			mu.Unlock()
+			return nil, nil
+		}).SetKey(key)
+	}
+	engine := New[string](WithMonitorInterval[string](time.Second)).DAG(DAGSkip)
+	engine.Run(
+		run("c", nil).DependsOn("a", "b"),
+		run("a", nil),
+		run("b", nil),
+		run("d", errors.New("failed")),
+		run("e", nil).DependsOn("d"),
+		run("f", nil).DependsOn("e", "a"),
+		run("g", nil).DependsOn("missing"),
+	)
+	if len(order) != 3 || order[2] != "c" {
+		t.Fatalf("unexpected execution order: %v", order)
+	}
+	if engine.TaskSkipCount() != 3 {
+		t.Fatalf("unexpected skip count: %d", engine.TaskSkipCount())
+	}
+	var dot strings.Builder
+	if err := engine.DAGDot(&dot); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{`"a" -> "c"`, `"d" [fillcolor=red`, `"f" [fillcolor=orange`} {
+		if !strings.Contains(dot.String(), s) {
+			t.Fatalf("dot missing %s:\n%s", s, dot.String())
+		}
+	}
+}
+
+func TestEngineRetryPolicy(t *testing.T) {
+	var execs, retries atomic.Int32
+	task := NewTask[string](func(ctx context.Context) ([]*Task[string], error) {
+		return nil, fmt.Errorf("failed %d", execs.Add(1))
+	}).SetKey("retry")
+	engine := New[string](WithMonitorInterval[string](time.Second))
+	engine.ErrHandlerRetryPolicy(retry.NewPolicy(retry.WithMaxAttempts(7), retry.WithBackoff(retry.Constant(10*time.Millisecond)),
+		retry.WithOnRetry(func(attempt int, err error, delay time.Duration) {
+			retries.Add(1)
+		})))
+	engine.Run(task)
+	// 每轮执行5次,第一轮后重试一次
+	if execs.Load() != 10 || retries.Load() != 1 {
+		t.Fatalf("execs:%d retries:%d", execs.Load(), retries.Load())
+	}
+	// 重试不清除之前的执行记录
+	if errs := task.Errs(); len(errs) != 10 || errs[0].Error() != "failed 1" {
+		t.Fatalf("unexpected errs: %v", errs)
+	}
+}
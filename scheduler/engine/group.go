@@ -0,0 +1,197 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package engine
+
+import (
+	"github.com/davecgh/go-spew/spew"
+	"github.com/hopeio/gox/datastructure/heap"
+	"github.com/hopeio/gox/log"
+	time2 "github.com/hopeio/gox/time"
+	"golang.org/x/time/rate"
+	"sync/atomic"
+	"time"
+)
+
+// WorkerGroupConfig 工作组配置
+type WorkerGroupConfig[KEY Key] struct {
+	Name string
+	// 工作组负责的任务Kind
+	Kinds []Kind
+	// 工作组的worker数量,即并发上限
+	WorkerCount uint64
+	// 任务执行间隔,为0不限制
+	Interval time.Duration
+	// 令牌桶限流,RateLimit为0不限制
+	RateLimit rate.Limit
+	Burst     int
+	// 任务Run及KindHandler都为nil时执行
+	Handler TaskHandler[KEY]
+}
+
+// WorkerGroup 工作组,绑定一个或多个Kind的固定worker池,拥有独立的任务队列,并发上限,限流及Handler
+// 使得慢任务(如图片下载)不会挤占快任务(如页面解析)的worker
+type WorkerGroup[KEY Key] struct {
+	*GroupStatistics
+	kinds         []Kind
+	speedLimit    time2.Ticker
+	rateLimiter   *rate.Limiter
+	handler       TaskHandler[KEY]
+	readyTaskHeap heap.Heap[*Task[KEY]]
+	taskCh        chan *Task[KEY]
+	signal        chan struct{}
+	workers       []*Worker[KEY]
+	running       bool
+}
+
+// WorkerGroup 添加工作组,需要在Run之前调用
+func (e *Engine[KEY]) WorkerGroup(conf *WorkerGroupConfig[KEY]) *Engine[KEY] {
+	if conf.WorkerCount == 0 {
+		conf.WorkerCount = 1
+	}
+	group := &WorkerGroup[KEY]{
+		GroupStatistics: &GroupStatistics{Name: conf.Name, workerCount: conf.WorkerCount},
+		kinds:           conf.Kinds,
+		handler:         conf.Handler,
+		taskCh:          make(chan *Task[KEY]),
+		signal:          make(chan struct{}, 1),
+	}
+	if conf.Interval > 0 {
+		group.speedLimit = time2.NewTicker(conf.Interval)
+	}
+	if conf.RateLimit > 0 {
+		burst := conf.Burst
+		if burst == 0 {
+			burst = 1
+		}
+		group.rateLimiter = rate.NewLimiter(conf.RateLimit, burst)
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, kind := range conf.Kinds {
+		e.kindHandler(kind).group = group
+	}
+	e.workerGroups = append(e.workerGroups, group)
+	e.groupStatistics = append(e.groupStatistics, group.GroupStatistics)
+	return e
+}
+
+func (e *Engine[KEY]) WorkerGroups() []*WorkerGroup[KEY] {
+	return e.workerGroups
+}
+
+// pushTask 任务放入对应的队列,调用方需持有e.mu
+func (e *Engine[KEY]) pushTask(task *Task[KEY]) {
+	if kindHandler := e.getKindHandler(task.Kind); kindHandler != nil && kindHandler.group != nil {
+		group := kindHandler.group
+		group.readyTaskHeap.Push(task)
+		atomic.AddUint64(&group.waitTaskCount, 1)
+		select {
+		case group.signal <- struct{}{}:
+		default:
+		}
+		return
+	}
+	e.readyTaskHeap.Push(task)
+}
+
+// readyTaskCount 所有队列中等待执行的任务数
+func (e *Engine[KEY]) readyTaskCount() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.readyTaskLen()
+}
+
+// readyTaskLen 同readyTaskCount,调用方需持有e.mu
+func (e *Engine[KEY]) readyTaskLen() int {
+	count := len(e.readyTaskHeap)
+	for _, group := range e.workerGroups {
+		count += len(group.readyTaskHeap)
+	}
+	return count
+}
+
+// runWorkerGroups 启动工作组的调度及worker,调用方需持有e.mu
+func (e *Engine[KEY]) runWorkerGroups() {
+	for _, group := range e.workerGroups {
+		if group.running {
+			continue
+		}
+		group.running = true
+		for range group.workerCount {
+			e.newGroupWorker(group)
+		}
+		go e.dispatchGroup(group)
+	}
+}
+
+func (e *Engine[KEY]) dispatchGroup(group *WorkerGroup[KEY]) {
+	for {
+		e.mu.Lock()
+		task, ok := group.readyTaskHeap.Pop()
+		e.mu.Unlock()
+		if !ok {
+			select {
+			case <-group.signal:
+				continue
+			case <-e.ctx.Done():
+				return
+			}
+		}
+		select {
+		case group.taskCh <- task:
+			atomic.AddUint64(&group.waitTaskCount, ^uint64(0))
+		case <-e.ctx.Done():
+			return
+		}
+	}
+}
+
+func (e *Engine[KEY]) newGroupWorker(group *WorkerGroup[KEY]) {
+	atomic.AddUint64(&group.currentWorkerCount, 1)
+	worker := &Worker[KEY]{id: uint(len(group.workers)), typ: groupType}
+	group.workers = append(group.workers, worker)
+	e.runGroupWorker(group, worker)
+}
+
+func (e *Engine[KEY]) runGroupWorker(group *WorkerGroup[KEY], worker *Worker[KEY]) {
+	go func() {
+		var task *Task[KEY]
+		defer func() {
+			if r := recover(); r != nil {
+				worker.canExecute = false
+				log.StackError(r, spew.Sdump(task))
+				e.recoverTask(task, r)
+				e.wg.Done()
+				// 原地重启
+				e.runGroupWorker(group, worker)
+			}
+		}()
+		worker.canExecute = true
+		for {
+			select {
+			case task = <-group.taskCh:
+				e.execGroupTask(group, worker, task)
+			case <-e.ctx.Done():
+				worker.canExecute = false
+				atomic.AddUint64(&group.currentWorkerCount, ^uint64(0))
+				return
+			}
+		}
+	}()
+}
+
+func (e *Engine[KEY]) execGroupTask(group *WorkerGroup[KEY], worker *Worker[KEY], task *Task[KEY]) {
+	atomic.AddUint64(&group.workingWorkerCount, 1)
+	defer atomic.AddUint64(&group.workingWorkerCount, ^uint64(0))
+	e.ExecTask(worker, task)
+}
+
+func (group *WorkerGroup[KEY]) stop() {
+	if group.speedLimit != nil {
+		group.speedLimit.Stop()
+	}
+}
@@ -0,0 +1,140 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package engine
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+const metricsNamespace = "engine"
+
+// metrics 引擎的prometheus指标,计数类指标在采集时从统计数据中读取,只有任务耗时需要在执行时记录
+type metrics[KEY Key] struct {
+	engine *Engine[KEY]
+
+	readyTasks     *prometheus.Desc
+	executingTasks *prometheus.Desc
+	workers        *prometheus.Desc
+	tasks          *prometheus.Desc
+	taskErrors     *prometheus.Desc
+	taskRetries    *prometheus.Desc
+	taskTimeouts   *prometheus.Desc
+
+	groupReadyTasks   *prometheus.Desc
+	groupWorkers      *prometheus.Desc
+	groupTasks        *prometheus.Desc
+	groupTaskErrors   *prometheus.Desc
+	groupTaskRetries  *prometheus.Desc
+	groupTaskTimeouts *prometheus.Desc
+
+	taskDuration *prometheus.HistogramVec
+}
+
+func newMetrics[KEY Key](e *Engine[KEY], constLabels prometheus.Labels) *metrics[KEY] {
+	desc := func(name, help string, labels ...string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "", name), help, labels, constLabels)
+	}
+	return &metrics[KEY]{
+		engine:            e,
+		readyTasks:        desc("ready_tasks", "Number of tasks waiting in the queue."),
+		executingTasks:    desc("executing_tasks", "Number of tasks being executed."),
+		workers:           desc("workers", "Number of workers by state.", "state"),
+		tasks:             desc("tasks_total", "Number of tasks by status.", "status"),
+		taskErrors:        desc("task_errors_total", "Number of task execution errors."),
+		taskRetries:       desc("task_retries_total", "Number of task retries after an error."),
+		taskTimeouts:      desc("task_timeouts_total", "Number of task executions exceeding the deadline."),
+		groupReadyTasks:   desc("group_ready_tasks", "Number of tasks waiting in the worker group queue.", "group"),
+		groupWorkers:      desc("group_workers", "Number of worker group workers by state.", "group", "state"),
+		groupTasks:        desc("group_tasks_total", "Number of worker group tasks by status.", "group", "status"),
+		groupTaskErrors:   desc("group_task_errors_total", "Number of worker group task execution errors.", "group"),
+		groupTaskRetries:  desc("group_task_retries_total", "Number of worker group task retries after an error.", "group"),
+		groupTaskTimeouts: desc("group_task_timeouts_total", "Number of worker group task executions exceeding the deadline.", "group"),
+		taskDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   metricsNamespace,
+			Name:        "task_duration_seconds",
+			Help:        "Task execution latency by kind.",
+			ConstLabels: constLabels,
+			Buckets:     prometheus.ExponentialBuckets(0.005, 2, 15),
+		}, []string{"kind"}),
+	}
+}
+
+func (m *metrics[KEY]) Describe(ch chan<- *prometheus.Desc) {
+	ch <- m.readyTasks
+	ch <- m.executingTasks
+	ch <- m.workers
+	ch <- m.tasks
+	ch <- m.taskErrors
+	ch <- m.taskRetries
+	ch <- m.taskTimeouts
+	ch <- m.groupReadyTasks
+	ch <- m.groupWorkers
+	ch <- m.groupTasks
+	ch <- m.groupTaskErrors
+	ch <- m.groupTaskRetries
+	ch <- m.groupTaskTimeouts
+	m.taskDuration.Describe(ch)
+}
+
+func (m *metrics[KEY]) Collect(ch chan<- prometheus.Metric) {
+	e := m.engine
+	e.mu.Lock()
+	readyTasks := len(e.readyTaskHeap)
+	e.mu.Unlock()
+	e.execMu.Lock()
+	executingTasks := len(e.executing)
+	e.execMu.Unlock()
+
+	ch <- prometheus.MustNewConstMetric(m.readyTasks, prometheus.GaugeValue, float64(readyTasks))
+	ch <- prometheus.MustNewConstMetric(m.executingTasks, prometheus.GaugeValue, float64(executingTasks))
+	ch <- prometheus.MustNewConstMetric(m.workers, prometheus.GaugeValue, float64(atomic.LoadUint64(&e.workerCount)), "max")
+	ch <- prometheus.MustNewConstMetric(m.workers, prometheus.GaugeValue, float64(atomic.LoadUint64(&e.currentWorkerCount)), "current")
+	ch <- prometheus.MustNewConstMetric(m.workers, prometheus.GaugeValue, float64(atomic.LoadUint64(&e.workingWorkerCount)), "working")
+	m.collectWork(ch, &e.workStatistics, m.tasks, m.taskErrors, m.taskRetries, m.taskTimeouts)
+
+	for _, group := range e.workerGroups {
+		ch <- prometheus.MustNewConstMetric(m.groupReadyTasks, prometheus.GaugeValue, float64(group.WaitTaskCount()), group.Name)
+		ch <- prometheus.MustNewConstMetric(m.groupWorkers, prometheus.GaugeValue, float64(group.WorkerCount()), group.Name, "max")
+		ch <- prometheus.MustNewConstMetric(m.groupWorkers, prometheus.GaugeValue, float64(group.CurrentWorkerCount()), group.Name, "current")
+		ch <- prometheus.MustNewConstMetric(m.groupWorkers, prometheus.GaugeValue, float64(group.WorkingWorkerCount()), group.Name, "working")
+		m.collectWork(ch, &group.workStatistics, m.groupTasks, m.groupTaskErrors, m.groupTaskRetries, m.groupTaskTimeouts, group.Name)
+	}
+	m.taskDuration.Collect(ch)
+}
+
+func (m *metrics[KEY]) collectWork(ch chan<- prometheus.Metric, s *workStatistics, tasks, errors, retries, timeouts *prometheus.Desc, labels ...string) {
+	status := func(name string, value uint64) {
+		ch <- prometheus.MustNewConstMetric(tasks, prometheus.CounterValue, float64(value), append(labels, name)...)
+	}
+	status("total", s.TaskTotalCount())
+	status("done", s.TaskDoneCount())
+	status("skip", s.TaskSkipCount())
+	status("err_handle", s.TaskErrHandleCount())
+	status("failed", s.TaskFailedCount())
+	ch <- prometheus.MustNewConstMetric(errors, prometheus.CounterValue, float64(s.TaskErrorTimes()), labels...)
+	ch <- prometheus.MustNewConstMetric(retries, prometheus.CounterValue, float64(s.TaskRepeatTimes()), labels...)
+	ch <- prometheus.MustNewConstMetric(timeouts, prometheus.CounterValue, float64(s.TaskTimeoutTimes()), labels...)
+}
+
+func (m *metrics[KEY]) observe(kind Kind, cost time.Duration) {
+	m.taskDuration.WithLabelValues(strconv.FormatUint(uint64(kind), 10)).Observe(cost.Seconds())
+}
+
+// Metrics 将引擎的队列长度,worker数量,任务耗时,重试及错误次数等指标注册到prometheus,
+// 同一个registerer注册多个引擎时需要通过constLabels区分
+func (e *Engine[KEY]) Metrics(registerer prometheus.Registerer, constLabels prometheus.Labels) error {
+	m := newMetrics(e, constLabels)
+	if err := registerer.Register(m); err != nil {
+		return err
+	}
+	e.metrics = m
+	e.enableTelemetry = true
+	return nil
+}
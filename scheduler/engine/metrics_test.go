@@ -0,0 +1,50 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/prometheus/client_golang/prometheus"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestEngineMetrics(t *testing.T) {
+	engine := NewEngine[int](2)
+	registry := prometheus.NewRegistry()
+	if err := engine.Metrics(registry, prometheus.Labels{"engine": "test"}); err != nil {
+		t.Fatal(err)
+	}
+	engine.AddTasks(NewTask[int](func(ctx context.Context) ([]*Task[int], error) {
+		return nil, nil
+	}).SetKey(1))
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var readyTasks float64 = -1
+	for _, family := range families {
+		if family.GetName() == "engine_ready_tasks" {
+			readyTasks = family.GetMetric()[0].GetGauge().GetValue()
+		}
+	}
+	if readyTasks != 1 {
+		t.Fatalf("unexpected engine_ready_tasks: %v", readyTasks)
+	}
+
+	recorder := httptest.NewRecorder()
+	engine.DebugHandler().ServeHTTP(recorder, httptest.NewRequest("GET", "/debug/engine", nil))
+	var state State[int]
+	if err = json.Unmarshal(recorder.Body.Bytes(), &state); err != nil {
+		t.Fatal(err)
+	}
+	if state.ReadyTasks != 1 || state.Statistics.Total != 1 || state.Workers.Max != 2 {
+		t.Fatalf("unexpected state: %+v", state)
+	}
+}
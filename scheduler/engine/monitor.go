@@ -0,0 +1,157 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package engine
+
+import (
+	"context"
+	"errors"
+	"github.com/hopeio/gox/log"
+	"sync/atomic"
+	"time"
+)
+
+var ErrTaskTimeout = errors.New("task execution timeout")
+
+// TaskTimeout 任务默认执行超时时间,超时的任务会被monitor取消context并通过OnStuck上报,任务可以通过SetTimeout单独设置
+func (e *Engine[KEY]) TaskTimeout(timeout time.Duration) *Engine[KEY] {
+	e.taskTimeout = timeout
+	return e
+}
+
+// OnStuck 任务执行超时时的回调,execLog为本次执行的记录,不设置则打印日志
+func (e *Engine[KEY]) OnStuck(callback func(task *Task[KEY], execLog ExecLog)) *Engine[KEY] {
+	e.onStuck = callback
+	return e
+}
+
+// OnPanic 任务执行panic时的回调,执行该任务的worker会被替换
+func (e *Engine[KEY]) OnPanic(callback func(task *Task[KEY], r any)) *Engine[KEY] {
+	e.onPanic = callback
+	return e
+}
+
+// beginExec 记录正在执行的任务,返回执行用的context
+func (e *Engine[KEY]) beginExec(task *Task[KEY]) context.Context {
+	ctx, cancel := context.WithCancelCause(task.ctx)
+	timeout := task.timeout
+	if timeout == 0 {
+		timeout = e.taskTimeout
+	}
+	e.execMu.Lock()
+	task.cancel = cancel
+	task.stuck = false
+	if timeout > 0 {
+		task.deadline = time.Now().Add(timeout)
+	} else {
+		task.deadline = time.Time{}
+	}
+	e.executing[task.id] = task
+	e.execMu.Unlock()
+	return ctx
+}
+
+func (e *Engine[KEY]) endExec(task *Task[KEY]) {
+	e.execMu.Lock()
+	execLog := task.lastExecLog()
+	execLog.execEndAt = time.Now()
+	if e.metrics != nil {
+		e.metrics.observe(task.Kind, execLog.execEndAt.Sub(execLog.execBeginAt))
+	}
+	if task.cancel != nil {
+		task.cancel(context.Canceled)
+		task.cancel = nil
+	}
+	delete(e.executing, task.id)
+	e.execMu.Unlock()
+}
+
+// ExecutingTasks 正在执行的任务
+func (e *Engine[KEY]) ExecutingTasks() []*Task[KEY] {
+	e.execMu.Lock()
+	defer e.execMu.Unlock()
+	tasks := make([]*Task[KEY], 0, len(e.executing))
+	for _, task := range e.executing {
+		tasks = append(tasks, task)
+	}
+	return tasks
+}
+
+// runMonitor 全局检测,任务的卡住检测,worker panic后的补充
+func (e *Engine[KEY]) runMonitor() {
+	if e.monitorRunning {
+		return
+	}
+	e.monitorRunning = true
+	go func() {
+		ticker := time.NewTicker(e.monitorInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-e.ctx.Done():
+				return
+			case now := <-ticker.C:
+				e.checkStuck(now)
+				e.checkWorkers()
+				e.checkDAG()
+			}
+		}
+	}()
+}
+
+func (e *Engine[KEY]) checkStuck(now time.Time) {
+	var stuckTasks []*Task[KEY]
+	var execLogs []ExecLog
+	e.execMu.Lock()
+	for _, task := range e.executing {
+		if task.stuck || task.deadline.IsZero() || now.Before(task.deadline) {
+			continue
+		}
+		task.stuck = true
+		if task.cancel != nil {
+			task.cancel(ErrTaskTimeout)
+		}
+		stuckTasks = append(stuckTasks, task)
+		execLog := task.lastExecLog()
+		execLogs = append(execLogs, ExecLog{BeginAt: execLog.execBeginAt, EndAt: execLog.execEndAt, Err: execLog.err})
+	}
+	e.execMu.Unlock()
+	for i, task := range stuckTasks {
+		atomic.AddUint64(&e.taskTimeoutTimes, 1)
+		if kindHandler := e.getKindHandler(task.Kind); kindHandler != nil && kindHandler.group != nil {
+			atomic.AddUint64(&kindHandler.group.taskTimeoutTimes, 1)
+		}
+		if e.onStuck != nil {
+			e.onStuck(task, execLogs[i])
+			continue
+		}
+		log.Warnf("task %v(%s) stuck, begin at %s, running %s", task.Key, task.Describe, execLogs[i].BeginAt.Format(time.DateTime), now.Sub(execLogs[i].BeginAt))
+	}
+}
+
+// checkWorkers 有待执行的任务但worker不足时(如worker panic退出)补充worker
+func (e *Engine[KEY]) checkWorkers() {
+	e.mu.Lock()
+	ready := len(e.readyTaskHeap) > 0 && e.isRunning
+	e.mu.Unlock()
+	if ready && atomic.LoadUint64(&e.currentWorkerCount) < atomic.LoadUint64(&e.workerCount) {
+		e.addWorker()
+	}
+}
+
+func (e *Engine[KEY]) recoverTask(task *Task[KEY], r any) {
+	atomic.AddUint64(&e.taskFailedCount, 1)
+	if task == nil {
+		return
+	}
+	if kindHandler := e.getKindHandler(task.Kind); kindHandler != nil && kindHandler.group != nil {
+		atomic.AddUint64(&kindHandler.group.taskFailedCount, 1)
+	}
+	if e.onPanic != nil {
+		e.onPanic(task, r)
+	}
+	e.dagFail(task)
+}
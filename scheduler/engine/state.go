@@ -0,0 +1,138 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package engine
+
+import (
+	"encoding/json"
+	"github.com/hopeio/gox/net/http/consts"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// State 引擎运行状态快照
+type State[KEY Key] struct {
+	Running        bool             `json:"running"`
+	Stopped        bool             `json:"stopped"`
+	ReadyTasks     int              `json:"readyTasks"`
+	Workers        WorkerState      `json:"workers"`
+	Statistics     StatisticsState  `json:"statistics"`
+	Groups         []GroupState     `json:"groups,omitempty"`
+	ExecutingTasks []TaskState[KEY] `json:"executingTasks,omitempty"`
+}
+
+type WorkerState struct {
+	Max     uint64 `json:"max"`
+	Current uint64 `json:"current"`
+	Working uint64 `json:"working"`
+}
+
+type StatisticsState struct {
+	Total     uint64 `json:"total"`
+	Done      uint64 `json:"done"`
+	Skip      uint64 `json:"skip"`
+	ErrHandle uint64 `json:"errHandle"`
+	Failed    uint64 `json:"failed"`
+	Errors    uint64 `json:"errors"`
+	Retries   uint64 `json:"retries"`
+	Timeouts  uint64 `json:"timeouts"`
+}
+
+type GroupState struct {
+	Name       string          `json:"name"`
+	ReadyTasks uint64          `json:"readyTasks"`
+	Workers    WorkerState     `json:"workers"`
+	Statistics StatisticsState `json:"statistics"`
+}
+
+type TaskState[KEY Key] struct {
+	Id          uint64        `json:"id"`
+	Key         KEY           `json:"key"`
+	Kind        Kind          `json:"kind"`
+	Describe    string        `json:"describe,omitempty"`
+	ReExecTimes int           `json:"reExecTimes"`
+	BeginAt     time.Time     `json:"beginAt"`
+	Running     time.Duration `json:"running"`
+	Deadline    *time.Time    `json:"deadline,omitempty"`
+	Stuck       bool          `json:"stuck,omitempty"`
+}
+
+func (s *workStatistics) state() StatisticsState {
+	return StatisticsState{
+		Total:     s.TaskTotalCount(),
+		Done:      s.TaskDoneCount(),
+		Skip:      s.TaskSkipCount(),
+		ErrHandle: s.TaskErrHandleCount(),
+		Failed:    s.TaskFailedCount(),
+		Errors:    s.TaskErrorTimes(),
+		Retries:   s.TaskRepeatTimes(),
+		Timeouts:  s.TaskTimeoutTimes(),
+	}
+}
+
+// State 获取引擎当前的运行状态
+func (e *Engine[KEY]) State() *State[KEY] {
+	e.mu.Lock()
+	state := &State[KEY]{
+		Running:    e.isRunning,
+		Stopped:    e.isStopped,
+		ReadyTasks: len(e.readyTaskHeap),
+	}
+	e.mu.Unlock()
+	state.Workers = WorkerState{
+		Max:     atomic.LoadUint64(&e.workerCount),
+		Current: atomic.LoadUint64(&e.currentWorkerCount),
+		Working: atomic.LoadUint64(&e.workingWorkerCount),
+	}
+	state.Statistics = e.workStatistics.state()
+	for _, group := range e.workerGroups {
+		state.Groups = append(state.Groups, GroupState{
+			Name:       group.Name,
+			ReadyTasks: group.WaitTaskCount(),
+			Workers: WorkerState{
+				Max:     group.WorkerCount(),
+				Current: group.CurrentWorkerCount(),
+				Working: group.WorkingWorkerCount(),
+			},
+			Statistics: group.workStatistics.state(),
+		})
+	}
+	now := time.Now()
+	e.execMu.Lock()
+	for _, task := range e.executing {
+		beginAt := task.lastExecLog().execBeginAt
+		taskState := TaskState[KEY]{
+			Id:          task.id,
+			Key:         task.Key,
+			Kind:        task.Kind,
+			Describe:    task.Describe,
+			ReExecTimes: task.reExecTimes,
+			BeginAt:     beginAt,
+			Running:     now.Sub(beginAt),
+			Stuck:       task.stuck,
+		}
+		if !task.deadline.IsZero() {
+			deadline := task.deadline
+			taskState.Deadline = &deadline
+		}
+		state.ExecutingTasks = append(state.ExecutingTasks, taskState)
+	}
+	e.execMu.Unlock()
+	return state
+}
+
+// DebugHandler 以json格式输出引擎当前的运行状态
+func (e *Engine[KEY]) DebugHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(consts.HeaderContentType, consts.ContentTypeJsonUtf8)
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(e.State()); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
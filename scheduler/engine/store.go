@@ -0,0 +1,309 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package engine
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"github.com/hopeio/gox/os/fs"
+	"os"
+	"sync"
+)
+
+// TaskRecord 任务中可持久化的部分,Run无法序列化,恢复时由TaskRestore根据记录重建任务
+type TaskRecord[KEY Key] struct {
+	Kind     Kind   `json:"kind"`
+	Key      KEY    `json:"key"`
+	Priority int    `json:"priority"`
+	Describe string `json:"describe,omitempty"`
+	Deps     []KEY  `json:"deps,omitempty"`
+	Err      string `json:"err,omitempty"`
+}
+
+// TaskRestore 根据持久化的记录重建任务,返回nil则丢弃该任务
+type TaskRestore[KEY Key] func(record *TaskRecord[KEY]) *Task[KEY]
+
+// TaskStore 任务队列的持久化存储,记录入队的任务,完成标记及错误任务,使得引擎重启后可以从中断处继续执行
+// 只有Key不为零值的任务会被记录
+type TaskStore[KEY Key] interface {
+	// Enqueue 记录入队的任务
+	Enqueue(records ...*TaskRecord[KEY]) error
+	// Done 标记任务完成
+	Done(key KEY) error
+	// IsDone 任务是否已完成
+	IsDone(key KEY) bool
+	// Failed 记录经过错误处理的任务,任务仍视为未完成
+	Failed(record *TaskRecord[KEY]) error
+	// Pending 所有已入队但未完成的任务,包括失败的任务
+	Pending() ([]*TaskRecord[KEY], error)
+	// Failures 所有失败且尚未完成的任务
+	Failures() ([]*TaskRecord[KEY], error)
+	Close() error
+}
+
+type journalOp uint8
+
+const (
+	journalEnqueue journalOp = iota + 1
+	journalDone
+	journalFailed
+)
+
+type journalEntry[KEY Key] struct {
+	Op   journalOp        `json:"op"`
+	Task *TaskRecord[KEY] `json:"task"`
+}
+
+// FileTaskStore 基于本地文件的TaskStore实现,以追加写日志(json lines)的方式记录,打开时回放日志并压缩
+type FileTaskStore[KEY Key] struct {
+	path    string
+	file    *os.File
+	mu      sync.Mutex
+	order   []KEY
+	pending map[KEY]*TaskRecord[KEY]
+	failed  map[KEY]*TaskRecord[KEY]
+	done    map[KEY]struct{}
+}
+
+func NewFileTaskStore[KEY Key](path string) (*FileTaskStore[KEY], error) {
+	s := &FileTaskStore[KEY]{
+		path:    path,
+		pending: make(map[KEY]*TaskRecord[KEY]),
+		failed:  make(map[KEY]*TaskRecord[KEY]),
+		done:    make(map[KEY]struct{}),
+	}
+	if err := s.replay(); err != nil {
+		return nil, err
+	}
+	if err := s.compact(); err != nil {
+		return nil, err
+	}
+	file, err := fs.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		return nil, err
+	}
+	s.file = file
+	return s, nil
+}
+
+func (s *FileTaskStore[KEY]) replay() error {
+	file, err := os.Open(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var entry journalEntry[KEY]
+		if err = json.Unmarshal(line, &entry); err != nil {
+			// 崩溃时最后一行可能只写了一半
+			break
+		}
+		if entry.Task == nil {
+			continue
+		}
+		s.apply(&entry)
+	}
+	return scanner.Err()
+}
+
+func (s *FileTaskStore[KEY]) apply(entry *journalEntry[KEY]) {
+	key := entry.Task.Key
+	switch entry.Op {
+	case journalEnqueue:
+		if _, ok := s.done[key]; ok {
+			return
+		}
+		if _, ok := s.pending[key]; !ok {
+			s.order = append(s.order, key)
+		}
+		s.pending[key] = entry.Task
+	case journalDone:
+		s.done[key] = struct{}{}
+		delete(s.pending, key)
+		delete(s.failed, key)
+	case journalFailed:
+		if _, ok := s.done[key]; ok {
+			return
+		}
+		if _, ok := s.pending[key]; !ok {
+			s.order = append(s.order, key)
+			s.pending[key] = entry.Task
+		}
+		s.failed[key] = entry.Task
+	}
+}
+
+// compact 用当前状态重写日志,避免日志无限增长
+func (s *FileTaskStore[KEY]) compact() error {
+	tmpPath := s.path + ".compact"
+	file, err := fs.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	var order []KEY
+	for key := range s.done {
+		if err = encoder.Encode(&journalEntry[KEY]{Op: journalDone, Task: &TaskRecord[KEY]{Key: key}}); err != nil {
+			file.Close()
+			return err
+		}
+	}
+	for _, key := range s.order {
+		record, ok := s.pending[key]
+		if !ok {
+			continue
+		}
+		order = append(order, key)
+		if err = encoder.Encode(&journalEntry[KEY]{Op: journalEnqueue, Task: record}); err != nil {
+			file.Close()
+			return err
+		}
+		if record, ok = s.failed[key]; ok {
+			if err = encoder.Encode(&journalEntry[KEY]{Op: journalFailed, Task: record}); err != nil {
+				file.Close()
+				return err
+			}
+		}
+	}
+	if err = writer.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	if err = file.Close(); err != nil {
+		return err
+	}
+	s.order = order
+	return os.Rename(tmpPath, s.path)
+}
+
+func (s *FileTaskStore[KEY]) write(entries ...*journalEntry[KEY]) error {
+	if s.file == nil {
+		return os.ErrClosed
+	}
+	var data []byte
+	for _, entry := range entries {
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		data = append(data, line...)
+		data = append(data, '\n')
+	}
+	_, err := s.file.Write(data)
+	return err
+}
+
+func (s *FileTaskStore[KEY]) Enqueue(records ...*TaskRecord[KEY]) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entries := make([]*journalEntry[KEY], 0, len(records))
+	for _, record := range records {
+		if _, ok := s.done[record.Key]; ok {
+			continue
+		}
+		entries = append(entries, &journalEntry[KEY]{Op: journalEnqueue, Task: record})
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+	if err := s.write(entries...); err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		s.apply(entry)
+	}
+	return nil
+}
+
+func (s *FileTaskStore[KEY]) Done(key KEY) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry := &journalEntry[KEY]{Op: journalDone, Task: &TaskRecord[KEY]{Key: key}}
+	if err := s.write(entry); err != nil {
+		return err
+	}
+	s.apply(entry)
+	return nil
+}
+
+func (s *FileTaskStore[KEY]) IsDone(key KEY) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.done[key]
+	return ok
+}
+
+func (s *FileTaskStore[KEY]) Failed(record *TaskRecord[KEY]) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry := &journalEntry[KEY]{Op: journalFailed, Task: record}
+	if err := s.write(entry); err != nil {
+		return err
+	}
+	s.apply(entry)
+	return nil
+}
+
+func (s *FileTaskStore[KEY]) Pending() ([]*TaskRecord[KEY], error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	records := make([]*TaskRecord[KEY], 0, len(s.pending))
+	for _, key := range s.order {
+		if record, ok := s.pending[key]; ok {
+			records = append(records, record)
+		}
+	}
+	return records, nil
+}
+
+func (s *FileTaskStore[KEY]) Failures() ([]*TaskRecord[KEY], error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	records := make([]*TaskRecord[KEY], 0, len(s.failed))
+	for _, key := range s.order {
+		if record, ok := s.failed[key]; ok {
+			records = append(records, record)
+		}
+	}
+	return records, nil
+}
+
+func (s *FileTaskStore[KEY]) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
+
+func (t *Task[KEY]) record() *TaskRecord[KEY] {
+	record := &TaskRecord[KEY]{
+		Kind:     t.Kind,
+		Key:      t.Key,
+		Priority: t.Priority,
+		Describe: t.Describe,
+		Deps:     t.deps,
+	}
+	if err := errors.Join(t.Errs()...); err != nil {
+		record.Err = err.Error()
+	}
+	return record
+}
@@ -0,0 +1,103 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package engine
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+func TestFileTaskStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.journal")
+	store, err := NewFileTaskStore[string](path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = store.Enqueue(&TaskRecord[string]{Key: "a", Priority: 1}, &TaskRecord[string]{Key: "b", Kind: 2}, &TaskRecord[string]{Key: "c"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = store.Done("a"); err != nil {
+		t.Fatal(err)
+	}
+	if err = store.Failed(&TaskRecord[string]{Key: "c", Err: "timeout"}); err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	// 模拟崩溃时写了一半的行
+	file, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0666)
+	file.WriteString(`{"op":2,"task":{"key":"b"`)
+	file.Close()
+
+	store, err = NewFileTaskStore[string](path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	if !store.IsDone("a") || store.IsDone("b") {
+		t.Fatal("done state not restored")
+	}
+	pending, _ := store.Pending()
+	if len(pending) != 2 || pending[0].Key != "b" || pending[0].Kind != 2 || pending[1].Key != "c" {
+		t.Fatalf("unexpected pending: %+v", pending)
+	}
+	failures, _ := store.Failures()
+	if len(failures) != 1 || failures[0].Err != "timeout" {
+		t.Fatalf("unexpected failures: %+v", failures)
+	}
+	// 已完成的任务不会再次入队
+	store.Enqueue(&TaskRecord[string]{Key: "a"})
+	pending, _ = store.Pending()
+	if len(pending) != 2 {
+		t.Fatalf("done task enqueued again: %+v", pending)
+	}
+}
+
+type countTaskStore struct {
+	*FileTaskStore[string]
+	enqueued atomic.Int64
+}
+
+func (s *countTaskStore) Enqueue(records ...*TaskRecord[string]) error {
+	s.enqueued.Add(int64(len(records)))
+	return s.FileTaskStore.Enqueue(records...)
+}
+
+func TestEngineResume(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.journal")
+	fileStore, err := NewFileTaskStore[string](path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fileStore.Enqueue(&TaskRecord[string]{Key: "a"}, &TaskRecord[string]{Key: "b"})
+	store := &countTaskStore{FileTaskStore: fileStore}
+	defer store.Close()
+
+	var ran atomic.Int64
+	engine := NewEngine[string](2)
+	engine.Store(store, func(record *TaskRecord[string]) *Task[string] {
+		return &Task[string]{Run: func(ctx context.Context) ([]*Task[string], error) {
+			ran.Add(1)
+			return nil, nil
+		}}
+	})
+	engine.Run()
+	if ran.Load() != 2 {
+		t.Fatalf("unexpected resumed tasks: %d", ran.Load())
+	}
+	// 恢复的任务不再重复记录
+	if store.enqueued.Load() != 0 {
+		t.Fatalf("resumed tasks enqueued again: %d", store.enqueued.Load())
+	}
+	if pending, _ := store.Pending(); len(pending) != 0 {
+		t.Fatalf("unexpected pending: %+v", pending)
+	}
+}
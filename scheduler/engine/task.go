@@ -30,6 +30,13 @@ type execLog struct {
 	err         error
 }
 
+// ExecLog 任务单次执行记录
+type ExecLog struct {
+	BeginAt time.Time
+	EndAt   time.Time
+	Err     error
+}
+
 type TaskStatistics struct {
 	reExecTimes int
 	errTimes    int
@@ -57,6 +64,12 @@ type Task[KEY Key] struct {
 	reExecLogs []*execLog // 多数任务只会执行一次
 	deadline   time.Time
 	timeout    time.Duration
+	cancel     context.CancelCauseFunc
+	stuck      bool
+	deps       []KEY
+	// 按重试策略等待重新执行
+	retrying   bool
+	retryDelay time.Duration
 }
 
 func NewTask[KEY Key](task TaskFunc[KEY]) *Task[KEY] {
@@ -90,6 +103,22 @@ func (t *Task[KEY]) SetDescribe(describe string) *Task[KEY] {
 	return t
 }
 
+// DependsOn 声明依赖的任务Key,需要Engine开启DAG模式,所有依赖完成后才会执行
+func (t *Task[KEY]) DependsOn(keys ...KEY) *Task[KEY] {
+	t.deps = append(t.deps, keys...)
+	return t
+}
+
+func (t *Task[KEY]) Deps() []KEY {
+	return t.deps
+}
+
+// SetTimeout 任务单次执行的超时时间,覆盖引擎的TaskTimeout
+func (t *Task[KEY]) SetTimeout(timeout time.Duration) *Task[KEY] {
+	t.timeout = timeout
+	return t
+}
+
 func (t *Task[KEY]) Id() uint64 {
 	return t.id
 }
@@ -98,6 +127,26 @@ func (t *Task[KEY]) Compare(t2 *Task[KEY]) int {
 	return t.Priority - t2.Priority
 }
 
+// ExecLogs 任务的所有执行记录
+func (t *Task[KEY]) ExecLogs() []ExecLog {
+	if t.execBeginAt.IsZero() {
+		return nil
+	}
+	logs := make([]ExecLog, 0, len(t.reExecLogs)+1)
+	logs = append(logs, ExecLog{BeginAt: t.execBeginAt, EndAt: t.execEndAt, Err: t.err})
+	for _, log := range t.reExecLogs {
+		logs = append(logs, ExecLog{BeginAt: log.execBeginAt, EndAt: log.execEndAt, Err: log.err})
+	}
+	return logs
+}
+
+func (t *Task[KEY]) lastExecLog() *execLog {
+	if len(t.reExecLogs) > 0 {
+		return t.reExecLogs[len(t.reExecLogs)-1]
+	}
+	return &t.execLog
+}
+
 func (t *Task[KEY]) Errs() []error {
 	var errs []error
 	if t.err != nil {
@@ -150,6 +199,9 @@ type ErrHandle func(context.Context, error)
 
 type TaskFunc[KEY Key] func(ctx context.Context) ([]*Task[KEY], error)
 
+// TaskHandler 按Kind或工作组处理任务的函数,可以访问任务本身的Key等属性
+type TaskHandler[KEY Key] func(ctx context.Context, task *Task[KEY]) ([]*Task[KEY], error)
+
 func (t TaskFunc[KEY]) Run(ctx context.Context) ([]*Task[KEY], error) {
 	return t(ctx)
 }
@@ -7,6 +7,7 @@
 package engine
 
 import (
+	"sync/atomic"
 	"time"
 )
 
@@ -15,6 +16,7 @@ type Type uint32
 const (
 	normalType Type = iota
 	fixedType
+	groupType
 )
 
 type Worker[KEY Key] struct {
@@ -34,7 +36,71 @@ type workStatistics struct {
 	taskRepeatTimes, taskErrorTimes, taskTimeoutTimes                                 uint64
 }
 
+func (s *workStatistics) TaskTotalCount() uint64 {
+	return atomic.LoadUint64(&s.taskTotalCount)
+}
+
+func (s *workStatistics) TaskDoneCount() uint64 {
+	return atomic.LoadUint64(&s.taskDoneCount)
+}
+
+func (s *workStatistics) TaskSkipCount() uint64 {
+	return atomic.LoadUint64(&s.taskSkipCount)
+}
+
+func (s *workStatistics) TaskErrHandleCount() uint64 {
+	return atomic.LoadUint64(&s.taskErrHandleCount)
+}
+
+func (s *workStatistics) TaskFailedCount() uint64 {
+	return atomic.LoadUint64(&s.taskFailedCount)
+}
+
+func (s *workStatistics) TaskErrorTimes() uint64 {
+	return atomic.LoadUint64(&s.taskErrorTimes)
+}
+
+// TaskRepeatTimes 任务出错后重新执行的次数
+func (s *workStatistics) TaskRepeatTimes() uint64 {
+	return atomic.LoadUint64(&s.taskRepeatTimes)
+}
+
+func (s *workStatistics) TaskTimeoutTimes() uint64 {
+	return atomic.LoadUint64(&s.taskTimeoutTimes)
+}
+
+// GroupStatistics 工作组统计数据
+type GroupStatistics struct {
+	Name string
+	workStatistics
+	workerCount, currentWorkerCount, workingWorkerCount uint64
+	waitTaskCount                                       uint64
+}
+
+func (s *GroupStatistics) WorkerCount() uint64 {
+	return s.workerCount
+}
+
+func (s *GroupStatistics) CurrentWorkerCount() uint64 {
+	return atomic.LoadUint64(&s.currentWorkerCount)
+}
+
+func (s *GroupStatistics) WorkingWorkerCount() uint64 {
+	return atomic.LoadUint64(&s.workingWorkerCount)
+}
+
+// WaitTaskCount 工作组中等待执行的任务数
+func (s *GroupStatistics) WaitTaskCount() uint64 {
+	return atomic.LoadUint64(&s.waitTaskCount)
+}
+
 // EngineStatistics 基本引擎统计数据
 type EngineStatistics struct {
 	workStatistics
+	groupStatistics []*GroupStatistics
+}
+
+// GroupStatistics 各工作组的统计数据
+func (s *EngineStatistics) GroupStatistics() []*GroupStatistics {
+	return s.groupStatistics
 }
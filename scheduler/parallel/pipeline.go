@@ -0,0 +1,220 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package parallel
+
+import (
+	"context"
+	"fmt"
+	"github.com/hopeio/gox/errors/multierr"
+	"iter"
+	"runtime"
+	"sync"
+)
+
+// ErrorMode 处理出错时的策略
+type ErrorMode uint8
+
+const (
+	// FailFast 第一个错误取消整个流水线并返回该错误
+	FailFast ErrorMode = iota
+	// CollectErrors 出错的元素被丢弃,其余继续处理,返回所有错误
+	CollectErrors
+)
+
+type pipelineConfig struct {
+	workers   int
+	buffer    int
+	errorMode ErrorMode
+}
+
+type PipelineOption func(c *pipelineConfig)
+
+// WithWorkers 每个阶段的最大并发数,默认runtime.NumCPU()
+func WithWorkers(workers int) PipelineOption {
+	return func(c *pipelineConfig) {
+		if workers > 0 {
+			c.workers = workers
+		}
+	}
+}
+
+// WithBuffer 阶段输出channel的容量,默认等于并发数
+func WithBuffer(buffer int) PipelineOption {
+	return func(c *pipelineConfig) {
+		if buffer >= 0 {
+			c.buffer = buffer
+		}
+	}
+}
+
+func WithErrorMode(mode ErrorMode) PipelineOption {
+	return func(c *pipelineConfig) {
+		c.errorMode = mode
+	}
+}
+
+// Pipeline 多阶段流水线,各阶段之间通过有界channel连接,每个阶段按输入顺序输出
+type Pipeline struct {
+	parent context.Context
+	ctx    context.Context
+	cancel context.CancelFunc
+	conf   pipelineConfig
+	wg     sync.WaitGroup
+	mu     sync.Mutex
+	err    error
+}
+
+func NewPipeline(ctx context.Context, opts ...PipelineOption) *Pipeline {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	p := &Pipeline{parent: ctx, conf: pipelineConfig{workers: runtime.NumCPU(), buffer: -1}}
+	for _, opt := range opts {
+		opt(&p.conf)
+	}
+	p.ctx, p.cancel = context.WithCancel(ctx)
+	return p
+}
+
+func (p *Pipeline) Context() context.Context {
+	return p.ctx
+}
+
+func (p *Pipeline) fail(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.conf.errorMode == FailFast {
+		if p.err == nil {
+			p.err = err
+			p.cancel()
+		}
+		return
+	}
+	p.err = multierr.Append(p.err, err)
+}
+
+// Wait 等待所有阶段结束,返回FailFast模式下的第一个错误或CollectErrors模式下的所有错误
+func (p *Pipeline) Wait() error {
+	p.wg.Wait()
+	p.cancel()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err == nil {
+		return p.parent.Err()
+	}
+	return p.err
+}
+
+// Source 将seq作为流水线的输入
+func Source[T any](p *Pipeline, seq iter.Seq[T]) <-chan T {
+	out := make(chan T, p.conf.outBuffer())
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		defer close(out)
+		for v := range seq {
+			select {
+			case out <- v:
+			case <-p.ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+type result[R any] struct {
+	v   R
+	err error
+}
+
+// Stage 以最多workers个并发处理in中的元素,按输入顺序输出到返回的channel,opts可覆盖流水线的并发数及缓冲
+func Stage[T, R any](p *Pipeline, in <-chan T, f func(ctx context.Context, v T) (R, error), opts ...PipelineOption) <-chan R {
+	conf := p.conf
+	for _, opt := range opts {
+		opt(&conf)
+	}
+	out := make(chan R, conf.outBuffer())
+	// 按输入顺序排队的结果,容量限制了处理中的元素数
+	slots := make(chan chan result[R], conf.workers)
+	sem := make(chan struct{}, conf.workers)
+	p.wg.Add(2)
+	go func() {
+		defer p.wg.Done()
+		defer close(slots)
+		for v := range in {
+			select {
+			case sem <- struct{}{}:
+			case <-p.ctx.Done():
+				return
+			}
+			slot := make(chan result[R], 1)
+			select {
+			case slots <- slot:
+			case <-p.ctx.Done():
+				<-sem
+				return
+			}
+			p.wg.Add(1)
+			go func() {
+				defer p.wg.Done()
+				r, err := call(p.ctx, f, v)
+				<-sem
+				slot <- result[R]{r, err}
+			}()
+		}
+	}()
+	go func() {
+		defer p.wg.Done()
+		defer close(out)
+		for slot := range slots {
+			res := <-slot
+			if res.err != nil {
+				p.fail(res.err)
+				continue
+			}
+			select {
+			case out <- res.v:
+			case <-p.ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+func call[T, R any](ctx context.Context, f func(ctx context.Context, v T) (R, error), v T) (r R, err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			err = fmt.Errorf("panic: %v", rec)
+		}
+	}()
+	return f(ctx, v)
+}
+
+// Collect 收集in中的所有元素并等待流水线结束
+func Collect[T any](p *Pipeline, in <-chan T) ([]T, error) {
+	var ret []T
+	for v := range in {
+		ret = append(ret, v)
+	}
+	return ret, p.Wait()
+}
+
+func (c *pipelineConfig) outBuffer() int {
+	if c.buffer < 0 {
+		return c.workers
+	}
+	return c.buffer
+}
+
+// Map 以最多workers个并发对seq中的每个元素执行f,结果保持输入顺序
+// CollectErrors模式下出错的元素不会出现在结果中
+func Map[T, R any](ctx context.Context, seq iter.Seq[T], f func(ctx context.Context, v T) (R, error), opts ...PipelineOption) ([]R, error) {
+	p := NewPipeline(ctx, opts...)
+	return Collect(p, Stage(p, Source(p, seq), f))
+}
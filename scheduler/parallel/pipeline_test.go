@@ -0,0 +1,91 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package parallel
+
+import (
+	"context"
+	"errors"
+	"github.com/hopeio/gox/errors/multierr"
+	"slices"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMap(t *testing.T) {
+	var running, maxRunning int32
+	input := []int{5, 4, 3, 2, 1, 0, 9, 8, 7, 6}
+	ret, err := Map(context.Background(), slices.Values(input), func(ctx context.Context, v int) (int, error) {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			m := atomic.LoadInt32(&maxRunning)
+			if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+				break
+			}
+		}
+		time.Sleep(time.Duration(v) * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		return v * 2, nil
+	}, WithWorkers(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, v := range input {
+		if ret[i] != v*2 {
+			t.Fatalf("unordered result: %v", ret)
+		}
+	}
+	if maxRunning > 3 {
+		t.Fatalf("workers exceed limit: %d", maxRunning)
+	}
+
+	errOdd := errors.New("odd")
+	ret, err = Map(context.Background(), slices.Values(input), func(ctx context.Context, v int) (int, error) {
+		if v%2 == 1 {
+			return 0, errOdd
+		}
+		return v, nil
+	}, WithErrorMode(CollectErrors))
+	if len(ret) != 5 || len(multierr.Errors(err)) != 5 || !errors.Is(err, errOdd) {
+		t.Fatalf("unexpected result: %v %v", ret, err)
+	}
+
+	_, err = Map(context.Background(), slices.Values(input), func(ctx context.Context, v int) (int, error) {
+		if v == 3 {
+			return 0, errOdd
+		}
+		return v, nil
+	})
+	if !errors.Is(err, errOdd) {
+		t.Fatal(err)
+	}
+}
+
+func TestPipeline(t *testing.T) {
+	p := NewPipeline(context.Background(), WithWorkers(4), WithBuffer(1))
+	numbers := Source(p, slices.Values([]int{1, 2, 3, 4, 5}))
+	squares := Stage(p, numbers, func(ctx context.Context, v int) (int, error) {
+		return v * v, nil
+	})
+	strs := Stage(p, squares, func(ctx context.Context, v int) (string, error) {
+		return strconv.Itoa(v), nil
+	}, WithWorkers(1))
+	ret, err := Collect(p, strs)
+	if err != nil || !slices.Equal(ret, []string{"1", "4", "9", "16", "25"}) {
+		t.Fatalf("unexpected result: %v %v", ret, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = Map(ctx, slices.Values([]int{1, 2, 3}), func(ctx context.Context, v int) (int, error) {
+		return v, nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,252 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package poller
+
+import (
+	"context"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// Signal 一次轮询的结果
+type Signal uint8
+
+const (
+	// Unchanged 没有新数据,间隔逐渐增大
+	Unchanged Signal = iota
+	// Changed 有新数据,间隔重置为最小值
+	Changed
+	// Failed 出错,间隔按ErrorFactor增大
+	Failed
+)
+
+func (s Signal) String() string {
+	switch s {
+	case Unchanged:
+		return "unchanged"
+	case Changed:
+		return "changed"
+	case Failed:
+		return "failed"
+	}
+	return "unknown"
+}
+
+func (s Signal) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// AdaptiveTaskFunc 返回的err不为nil时视为Failed
+type AdaptiveTaskFunc = func(context.Context) (Signal, error)
+
+type AdaptiveConfig struct {
+	MinInterval time.Duration
+	MaxInterval time.Duration
+	// 没有变化时间隔的增长倍数,默认2
+	IdleFactor float64
+	// 出错时间隔的增长倍数,默认2
+	ErrorFactor float64
+	// 出错时的最大间隔,默认MaxInterval
+	MaxErrorInterval time.Duration
+	// 随机抖动比例,取值[0,1),实际间隔在interval*(1±Jitter)之间
+	Jitter float64
+}
+
+func (c *AdaptiveConfig) init() {
+	if c.MinInterval <= 0 {
+		c.MinInterval = time.Second
+	}
+	if c.MaxInterval < c.MinInterval {
+		c.MaxInterval = c.MinInterval
+	}
+	if c.IdleFactor < 1 {
+		c.IdleFactor = 2
+	}
+	if c.ErrorFactor < 1 {
+		c.ErrorFactor = 2
+	}
+	if c.MaxErrorInterval <= 0 {
+		c.MaxErrorInterval = c.MaxInterval
+	}
+	if c.Jitter < 0 || c.Jitter >= 1 {
+		c.Jitter = 0
+	}
+}
+
+// AdaptiveState 轮询器当前状态
+type AdaptiveState struct {
+	Times             uint          `json:"times"`
+	Changes           uint          `json:"changes"`
+	Errors            uint          `json:"errors"`
+	ConsecutiveIdle   uint          `json:"consecutiveIdle"`
+	ConsecutiveErrors uint          `json:"consecutiveErrors"`
+	Interval          time.Duration `json:"interval"`
+	Paused            bool          `json:"paused"`
+	LastSignal        Signal        `json:"lastSignal"`
+	LastErr           string        `json:"lastErr,omitempty"`
+	LastRun           time.Time     `json:"lastRun"`
+	NextRun           time.Time     `json:"nextRun"`
+}
+
+// AdaptivePoller 根据轮询结果自适应调整间隔的轮询器,有变化时加快,空闲或出错时指数退避
+type AdaptivePoller struct {
+	conf    AdaptiveConfig
+	do      AdaptiveTaskFunc
+	mu      sync.Mutex
+	state   AdaptiveState
+	trigger chan struct{}
+	notify  chan struct{}
+}
+
+func NewAdaptivePoller(conf AdaptiveConfig, do AdaptiveTaskFunc) *AdaptivePoller {
+	conf.init()
+	return &AdaptivePoller{
+		conf:    conf,
+		do:      do,
+		state:   AdaptiveState{Interval: conf.MinInterval},
+		trigger: make(chan struct{}, 1),
+		notify:  make(chan struct{}, 1),
+	}
+}
+
+// Run 立即执行一次,之后按自适应间隔执行直到ctx取消
+func (p *AdaptivePoller) Run(ctx context.Context) {
+	p.exec(ctx)
+	for {
+		p.mu.Lock()
+		paused := p.state.Paused
+		var timer *time.Timer
+		var timerC <-chan time.Time
+		if !paused {
+			interval := p.jitter(p.state.Interval)
+			p.state.NextRun = time.Now().Add(interval)
+			timer = time.NewTimer(interval)
+			timerC = timer.C
+		} else {
+			p.state.NextRun = time.Time{}
+		}
+		p.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			if timer != nil {
+				timer.Stop()
+			}
+			return
+		case <-timerC:
+			p.exec(ctx)
+		case <-p.trigger:
+			if timer != nil {
+				timer.Stop()
+			}
+			p.exec(ctx)
+		case <-p.notify:
+			// 暂停或恢复,重新计算下次执行时间
+			if timer != nil {
+				timer.Stop()
+			}
+		}
+	}
+}
+
+func (p *AdaptivePoller) exec(ctx context.Context) {
+	signal, err := p.do(ctx)
+	if err != nil {
+		signal = Failed
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	state := &p.state
+	state.Times++
+	state.LastRun = time.Now()
+	state.LastSignal = signal
+	state.LastErr = ""
+	switch signal {
+	case Changed:
+		state.Changes++
+		state.ConsecutiveIdle = 0
+		state.ConsecutiveErrors = 0
+		state.Interval = p.conf.MinInterval
+	case Failed:
+		state.Errors++
+		state.ConsecutiveErrors++
+		if err != nil {
+			state.LastErr = err.Error()
+		}
+		state.Interval = grow(state.Interval, p.conf.ErrorFactor, p.conf.MaxErrorInterval)
+	default:
+		state.ConsecutiveIdle++
+		state.ConsecutiveErrors = 0
+		// 从出错状态恢复时,间隔不超过MaxInterval
+		state.Interval = grow(min(state.Interval, p.conf.MaxInterval), p.conf.IdleFactor, p.conf.MaxInterval)
+	}
+}
+
+func grow(interval time.Duration, factor float64, max time.Duration) time.Duration {
+	next := time.Duration(float64(interval) * factor)
+	// 溢出
+	if next > max || next <= 0 {
+		return max
+	}
+	return next
+}
+
+func (p *AdaptivePoller) jitter(interval time.Duration) time.Duration {
+	if p.conf.Jitter == 0 {
+		return interval
+	}
+	return time.Duration(float64(interval) * (1 + p.conf.Jitter*(2*rand.Float64()-1)))
+}
+
+// Trigger 立即执行一次,暂停时也会执行,不影响暂停状态
+func (p *AdaptivePoller) Trigger() {
+	select {
+	case p.trigger <- struct{}{}:
+	default:
+	}
+}
+
+func (p *AdaptivePoller) Pause() {
+	p.setPaused(true)
+}
+
+// Resume 恢复执行,从恢复时开始计算间隔
+func (p *AdaptivePoller) Resume() {
+	p.setPaused(false)
+}
+
+func (p *AdaptivePoller) setPaused(paused bool) {
+	p.mu.Lock()
+	p.state.Paused = paused
+	p.mu.Unlock()
+	select {
+	case p.notify <- struct{}{}:
+	default:
+	}
+}
+
+// Reset 将间隔重置为最小值
+func (p *AdaptivePoller) Reset() {
+	p.mu.Lock()
+	p.state.Interval = p.conf.MinInterval
+	p.mu.Unlock()
+	select {
+	case p.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (p *AdaptivePoller) State() AdaptiveState {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.state
+}
+
+func AdaptiveRun(ctx context.Context, conf AdaptiveConfig, do AdaptiveTaskFunc) {
+	NewAdaptivePoller(conf, do).Run(ctx)
+}
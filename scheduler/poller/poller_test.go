@@ -8,6 +8,7 @@ package poller
 
 import (
 	"context"
+	"errors"
 	"testing"
 	"time"
 )
@@ -29,3 +30,36 @@ func TestPoller(t *testing.T) {
 		t.Log("hello")
 	})
 }
+
+func TestAdaptivePoller(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var times int
+	poller := NewAdaptivePoller(AdaptiveConfig{MinInterval: 10 * time.Millisecond, MaxInterval: 40 * time.Millisecond}, func(ctx context.Context) (Signal, error) {
+		times++
+		if times == 1 {
+			return Changed, nil
+		}
+		if times == 5 {
+			return Unchanged, errors.New("upstream error")
+		}
+		return Unchanged, nil
+	})
+	go poller.Run(ctx)
+	time.Sleep(150 * time.Millisecond)
+	poller.Pause()
+	time.Sleep(20 * time.Millisecond)
+	state := poller.State()
+	if state.Times < 4 || state.Changes != 1 || state.Interval != 40*time.Millisecond || !state.Paused {
+		t.Fatalf("unexpected state: %+v", state)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if poller.State().Times != state.Times {
+		t.Fatal("poller run while paused")
+	}
+	poller.Trigger()
+	time.Sleep(20 * time.Millisecond)
+	if poller.State().Times != state.Times+1 {
+		t.Fatal("trigger not executed")
+	}
+}
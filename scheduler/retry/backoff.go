@@ -0,0 +1,80 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package retry
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// Backoff 计算第attempt(从1开始)次重试前的等待时间,last为上一次的等待时间
+type Backoff func(attempt int, last time.Duration) time.Duration
+
+// Constant 固定间隔
+func Constant(interval time.Duration) Backoff {
+	return func(int, time.Duration) time.Duration {
+		return interval
+	}
+}
+
+// Exponential 指数退避,base*2^(attempt-1),不超过max
+func Exponential(base, max time.Duration) Backoff {
+	return func(attempt int, _ time.Duration) time.Duration {
+		return exponential(base, max, attempt)
+	}
+}
+
+// ExponentialJitter 指数退避加全抖动,在[0,base*2^(attempt-1))中随机,不超过max
+func ExponentialJitter(base, max time.Duration) Backoff {
+	return func(attempt int, _ time.Duration) time.Duration {
+		d := exponential(base, max, attempt)
+		if d <= 0 {
+			return 0
+		}
+		return rand.N(d)
+	}
+}
+
+// DecorrelatedJitter 去相关抖动,在[base,last*3)中随机,不超过max
+// https://aws.amazon.com/blogs/architecture/exponential-backoff-and-jitter/
+func DecorrelatedJitter(base, max time.Duration) Backoff {
+	return func(attempt int, last time.Duration) time.Duration {
+		if last < base {
+			last = base
+		}
+		upper := last * 3
+		if upper <= base {
+			return base
+		}
+		d := base + rand.N(upper-base)
+		if max > 0 && d > max {
+			d = max
+		}
+		return d
+	}
+}
+
+func exponential(base, max time.Duration, attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	d := base
+	for i := 1; i < attempt; i++ {
+		// 溢出
+		if d*2 <= 0 {
+			break
+		}
+		d *= 2
+		if max > 0 && d >= max {
+			return max
+		}
+	}
+	if max > 0 && d > max {
+		d = max
+	}
+	return d
+}
@@ -0,0 +1,224 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package retry
+
+import (
+	"context"
+	"errors"
+	"github.com/hopeio/gox/errors/errcode"
+	"github.com/hopeio/gox/errors/multierr"
+	"time"
+)
+
+// Policy 重试策略,零值表示不限次数,无间隔,所有错误都重试
+type Policy struct {
+	// 最大尝试次数(包含第一次),为0不限制
+	MaxAttempts int
+	// 从第一次尝试开始的最长时间,为0不限制
+	MaxElapsedTime time.Duration
+	// 重试间隔,为nil不等待
+	Backoff Backoff
+	// 错误是否可重试,为nil则除Unrecoverable外都重试
+	Retryable func(err error) bool
+	// 每次重试前调用,attempt为已尝试次数,delay为即将等待的时间
+	OnRetry func(attempt int, err error, delay time.Duration)
+}
+
+type Option func(p *Policy)
+
+func NewPolicy(opts ...Option) *Policy {
+	p := &Policy{}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+func WithMaxAttempts(attempts int) Option {
+	return func(p *Policy) {
+		p.MaxAttempts = attempts
+	}
+}
+
+func WithMaxElapsedTime(d time.Duration) Option {
+	return func(p *Policy) {
+		p.MaxElapsedTime = d
+	}
+}
+
+func WithBackoff(backoff Backoff) Option {
+	return func(p *Policy) {
+		p.Backoff = backoff
+	}
+}
+
+// WithRetryIf 满足predicate的错误才重试,多次设置时满足任一即可
+func WithRetryIf(predicate func(err error) bool) Option {
+	return func(p *Policy) {
+		if p.Retryable == nil {
+			p.Retryable = predicate
+			return
+		}
+		retryable := p.Retryable
+		p.Retryable = func(err error) bool {
+			return retryable(err) || predicate(err)
+		}
+	}
+}
+
+// WithRetryOn errors.Is匹配任一target的错误才重试
+func WithRetryOn(targets ...error) Option {
+	return WithRetryIf(func(err error) bool {
+		for _, target := range targets {
+			if errors.Is(err, target) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// WithRetryOnCodes 错误码在[min,max]范围内的错误才重试
+func WithRetryOnCodes(min, max errcode.ErrCode) Option {
+	return WithRetryIf(func(err error) bool {
+		code, ok := ErrCode(err)
+		return ok && code >= min && code <= max
+	})
+}
+
+func WithOnRetry(hook func(attempt int, err error, delay time.Duration)) Option {
+	return func(p *Policy) {
+		p.OnRetry = hook
+	}
+}
+
+// ErrCode 从错误链中提取错误码
+func ErrCode(err error) (errcode.ErrCode, bool) {
+	var code errcode.ErrCode
+	if errors.As(err, &code) {
+		return code, true
+	}
+	var rep *errcode.ErrRep
+	if errors.As(err, &rep) {
+		return rep.Code, true
+	}
+	var irep errcode.IErrRep
+	if errors.As(err, &irep) {
+		return irep.ErrRep().Code, true
+	}
+	return 0, false
+}
+
+type unrecoverableError struct {
+	error
+}
+
+func (e *unrecoverableError) Unwrap() error {
+	return e.error
+}
+
+// Unrecoverable 包装的错误不会被重试
+func Unrecoverable(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &unrecoverableError{err}
+}
+
+func IsUnrecoverable(err error) bool {
+	var ue *unrecoverableError
+	return errors.As(err, &ue)
+}
+
+// ShouldRetry 错误是否应该重试
+func (p *Policy) ShouldRetry(err error) bool {
+	if err == nil || IsUnrecoverable(err) {
+		return false
+	}
+	return p.Retryable == nil || p.Retryable(err)
+}
+
+// Delay 第attempt(从1开始)次重试前需要等待的时间
+func (p *Policy) Delay(attempt int, last time.Duration) time.Duration {
+	if p.Backoff == nil {
+		return 0
+	}
+	return p.Backoff(attempt, last)
+}
+
+// Next 已尝试attempt次并返回err后是否继续重试,以及重试前等待的时间
+func (p *Policy) Next(begin time.Time, attempt int, err error, last time.Duration) (time.Duration, bool) {
+	if !p.ShouldRetry(err) {
+		return 0, false
+	}
+	if p.MaxAttempts > 0 && attempt >= p.MaxAttempts {
+		return 0, false
+	}
+	delay := p.Delay(attempt, last)
+	if p.MaxElapsedTime > 0 && time.Since(begin)+delay > p.MaxElapsedTime {
+		return 0, false
+	}
+	return delay, true
+}
+
+// Do 按策略执行f直到成功,返回所有尝试的错误
+func (p *Policy) Do(ctx context.Context, f func(ctx context.Context) error) error {
+	_, err := Do(ctx, p, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, f(ctx)
+	})
+	return err
+}
+
+// Do 按策略执行f直到成功并返回结果,失败返回所有尝试的错误
+func Do[T any](ctx context.Context, p *Policy, f func(ctx context.Context) (T, error)) (T, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if p == nil {
+		p = &Policy{MaxAttempts: 1}
+	}
+	var errs error
+	var delay time.Duration
+	begin := time.Now()
+	for attempt := 1; ; attempt++ {
+		if err := ctx.Err(); err != nil {
+			var zero T
+			return zero, multierr.Append(errs, err)
+		}
+		v, err := f(ctx)
+		if err == nil {
+			return v, nil
+		}
+		errs = multierr.Append(errs, err)
+		var ok bool
+		delay, ok = p.Next(begin, attempt, err, delay)
+		if !ok {
+			return v, errs
+		}
+		if p.OnRetry != nil {
+			p.OnRetry(attempt, err, delay)
+		}
+		if err = Sleep(ctx, delay); err != nil {
+			return v, multierr.Append(errs, err)
+		}
+	}
+}
+
+// Sleep 可被ctx取消的等待
+func Sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
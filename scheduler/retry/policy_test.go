@@ -0,0 +1,79 @@
+/*
+ * Copyright 2024 hopeio. All rights reserved.
+ * Licensed under the MIT License that can be found in the LICENSE file.
+ * @Created by jyb
+ */
+
+package retry
+
+import (
+	"context"
+	"errors"
+	"github.com/hopeio/gox/errors/errcode"
+	"testing"
+	"time"
+)
+
+func TestPolicyDo(t *testing.T) {
+	var attempts, retries int
+	p := NewPolicy(WithMaxAttempts(3), WithBackoff(Constant(time.Millisecond)), WithOnRetry(func(attempt int, err error, delay time.Duration) {
+		retries++
+	}))
+	v, err := Do(context.Background(), p, func(ctx context.Context) (int, error) {
+		attempts++
+		if attempts < 3 {
+			return 0, errors.New("temporary")
+		}
+		return attempts, nil
+	})
+	if err != nil || v != 3 || retries != 2 {
+		t.Fatalf("unexpected result: %v %v %v", v, err, retries)
+	}
+
+	attempts = 0
+	err = p.Do(context.Background(), func(ctx context.Context) error {
+		attempts++
+		return Unrecoverable(errors.New("fatal"))
+	})
+	if attempts != 1 || !IsUnrecoverable(err) {
+		t.Fatalf("unrecoverable error retried: %v %v", attempts, err)
+	}
+
+	attempts = 0
+	p = NewPolicy(WithMaxAttempts(5), WithRetryOnCodes(errcode.Unavailable, errcode.Unavailable))
+	err = p.Do(context.Background(), func(ctx context.Context) error {
+		attempts++
+		if attempts == 1 {
+			return errcode.Unavailable
+		}
+		return errcode.InvalidArgument
+	})
+	if attempts != 2 || err == nil {
+		t.Fatalf("unexpected attempts: %v %v", attempts, err)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	backoff := Exponential(time.Millisecond, 10*time.Millisecond)
+	if d := backoff(1, 0); d != time.Millisecond {
+		t.Fatal(d)
+	}
+	if d := backoff(3, 0); d != 4*time.Millisecond {
+		t.Fatal(d)
+	}
+	if d := backoff(100, 0); d != 10*time.Millisecond {
+		t.Fatal(d)
+	}
+	jitter := ExponentialJitter(time.Millisecond, 10*time.Millisecond)
+	decorrelated := DecorrelatedJitter(time.Millisecond, 10*time.Millisecond)
+	var last time.Duration
+	for i := 1; i < 20; i++ {
+		if d := jitter(i, 0); d < 0 || d >= 10*time.Millisecond {
+			t.Fatal(d)
+		}
+		last = decorrelated(i, last)
+		if last < time.Millisecond || last > 10*time.Millisecond {
+			t.Fatal(last)
+		}
+	}
+}
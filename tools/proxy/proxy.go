@@ -1,7 +1,38 @@
 package main
 
-import "github.com/hopeio/gox/net/http/proxy"
+import (
+	"encoding/json"
+	"flag"
+	"net/http"
+	"os"
 
+	"github.com/hopeio/gox/log"
+	"github.com/hopeio/gox/net/http/proxy"
+	"github.com/hopeio/gox/net/http/tunnel"
+)
+
+// 按Target-Url头转发(默认): proxy -addr=:8080
+// 正向代理,认证及访问控制由配置文件指定: proxy -addr=:8080 -config=proxy.json
 func main() {
-	proxy.DirectorServer(":8080")
+	var addr, config string
+	flag.StringVar(&addr, "addr", ":8080", "listen address")
+	flag.StringVar(&config, "config", "", "forward proxy config json file, run Target-Url header director if not set")
+	flag.Parse()
+	if config == "" {
+		log.Fatal(proxy.DirectorServer(addr))
+	}
+	data, err := os.ReadFile(config)
+	if err != nil {
+		log.Fatal(err)
+	}
+	conf := new(tunnel.Config)
+	if err = json.Unmarshal(data, conf); err != nil {
+		log.Fatal(err)
+	}
+	p, err := tunnel.New(conf)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Info("forward proxy listen on ", addr)
+	log.Fatal(http.ListenAndServe(addr, p))
 }